@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"swiftcodes/internal/importjob"
+	"swiftcodes/internal/jobs"
+	"swiftcodes/internal/verify"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ADMIN_URI   = "/v" + API_VERSION + "/admin"
+	IMPORTS_URI = ADMIN_URI + "/imports"
+	JOBS_URI    = "/v" + API_VERSION + "/jobs"
+
+	// DEFAULT_JOBS and MAX_JOBS bound the limit query parameter of the job list
+	DEFAULT_JOBS = 100
+	MAX_JOBS     = 1000
+
+	// Form fields of an uploaded import file, its base64 signature as in a .sig file and the number of failed rows tolerated
+	FORM_FILE       = "file"
+	FORM_SIGNATURE  = "signature"
+	FORM_MAX_ERRORS = "maxErrors"
+)
+
+var (
+	// runner runs background jobs, such as the imports of uploaded files
+	runner *jobs.Runner
+	// jobsDir holds uploaded import files until their job finished
+	jobsDir string
+	// trustedKeys verify uploaded import files, which aren't verified if it is nil
+	trustedKeys verify.Keys
+)
+
+// RequireAdmin rejects requests that don't carry token as bearer token
+func RequireAdmin(token string) gin.HandlerFunc {
+	want := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), want) != 1 {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "401 unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Admin endpoint 1: Uploads a CSV, TSV or zipped import file and imports it in the background
+func PostImportHandler(c *gin.Context) {
+	upload, ok := ReadUpload(c)
+	if !ok {
+		return
+	}
+	status, err := importjob.Enqueue(c.Request.Context(), runner, jobsDir, upload)
+	if importjob.IsInvalid(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "400 " + err.Error()})
+		return
+	}
+	if err != nil {
+		AbortWithStoreError(c, "InsertJob", err, "", "")
+		return
+	}
+	c.Header("Location", IMPORTS_URI+"/"+status.ID)
+	c.JSON(http.StatusAccepted, status)
+}
+
+// Admin endpoint 2: Reports the progress of an import, its counts and the rows that failed
+func GetImportHandler(c *gin.Context) {
+	id, _ := c.Params.Get("id")
+	job, err := runner.Get(c.Request.Context(), id)
+	if err != nil && !errors.Is(err, jobs.ErrNotFound) {
+		AbortWithStoreError(c, "GetJob", err, "", "")
+		return
+	}
+	status, err := importjob.StatusOf(job)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "404 import job " + id + " not found"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// Admin endpoint 3: Cancels an import, keeping the codes imported until then
+func DeleteImportHandler(c *gin.Context) {
+	id, _ := c.Params.Get("id")
+	job, err := runner.Get(c.Request.Context(), id)
+	if err == nil && job.Kind != importjob.KIND {
+		err = jobs.ErrNotFound
+	}
+	if err == nil {
+		job, err = runner.Cancel(c.Request.Context(), id)
+	}
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "404 import job " + id + " not found"})
+	case errors.Is(err, jobs.ErrFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": "409 import job " + id + " already " + job.State})
+	case err != nil:
+		AbortWithStoreError(c, "CancelJob", err, "", "")
+	default:
+		status, _ := importjob.StatusOf(job)
+		c.JSON(http.StatusAccepted, status)
+	}
+}
+
+// Admin endpoint 4: Lists the newest background jobs of every kind
+func ListJobsHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(DEFAULT_JOBS)))
+	if err != nil || limit < 1 || limit > MAX_JOBS {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "400 invalid limit, want a number from 1 to " + strconv.Itoa(MAX_JOBS)})
+		return
+	}
+	list, err := runner.List(c.Request.Context(), limit)
+	if err != nil {
+		AbortWithStoreError(c, "ListJobs", err, "", "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": list})
+}
+
+// Admin endpoint 5: Reports the state of a background job
+func GetJobHandler(c *gin.Context) {
+	id, _ := c.Params.Get("id")
+	job, err := runner.Get(c.Request.Context(), id)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "404 job " + id + " not found"})
+	case err != nil:
+		AbortWithStoreError(c, "GetJob", err, "", "")
+	default:
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// ReadUpload reads the import file of a multipart request and verifies it if trusted keys are configured,
+// responding and returning false if it can't
+func ReadUpload(c *gin.Context) (importjob.Upload, bool) {
+	header, err := c.FormFile(FORM_FILE)
+	if err != nil {
+		if !abortWithTooLarge(c, err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "400 missing " + FORM_FILE})
+		}
+		return importjob.Upload{}, false
+	}
+	maxErrors, err := strconv.Atoi(c.DefaultPostForm(FORM_MAX_ERRORS, "0"))
+	if err != nil || maxErrors < -1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "400 invalid " + FORM_MAX_ERRORS + ", want a number of rows or -1 for no limit"})
+		return importjob.Upload{}, false
+	}
+	f, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "400 unreadable " + FORM_FILE})
+		return importjob.Upload{}, false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "400 unreadable " + FORM_FILE})
+		return importjob.Upload{}, false
+	}
+	upload := importjob.Upload{FileName: header.Filename, Data: data, MaxErrors: maxErrors}
+	if trustedKeys == nil {
+		return upload, true
+	}
+	encoded, ok := c.GetPostForm(FORM_SIGNATURE)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "403 " + verify.ErrUnsigned.Error() + ", want the " + FORM_SIGNATURE + " of the file"})
+		return importjob.Upload{}, false
+	}
+	signature, err := verify.DecodeSignature([]byte(encoded))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "400 " + err.Error()})
+		return importjob.Upload{}, false
+	}
+	if upload.Signer, err = trustedKeys.Signer(data, signature); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "403 " + err.Error()})
+		return importjob.Upload{}, false
+	}
+	return upload, true
+}
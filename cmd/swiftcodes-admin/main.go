@@ -0,0 +1,856 @@
+// Command swiftcodes-admin manages the swift codes database without going through the API.
+//
+// Usage:
+//
+//	swiftcodes-admin <command> [flags] [arguments]
+//
+// Every command but keygen and sign accepts the configuration flags of the server, see -h. Exit codes
+// are 0 on success, 1 when the command failed, 2 on invalid usage or configuration and 3 when diff found
+// differences or import -dry-run found issues.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"swiftcodes/internal/cache"
+	"swiftcodes/internal/config"
+	"swiftcodes/internal/initdb"
+	"swiftcodes/internal/migrate"
+	"swiftcodes/internal/store"
+	"swiftcodes/internal/verify"
+	"swiftcodes/sqlcout"
+)
+
+// Exit codes
+const (
+	EXIT_OK     = 0
+	EXIT_ERROR  = 1
+	EXIT_USAGE  = 2
+	EXIT_DIFF   = 3
+	EXIT_ISSUES = 3
+)
+
+type command struct {
+	args  string
+	usage string
+	run   func(flags *flag.FlagSet, args []string, out io.Writer) int
+}
+
+var commands = map[string]command{
+	"create":   {"", "create the database and apply the migrations", runCreate},
+	"import":   {"<file>", "insert the codes of a CSV or TSV file, or with -dry-run report its data quality issues and exit 3 if there are any", runImport},
+	"export":   {"", "write the stored codes in the import file format, as JSON Lines or as XLSX", runExport},
+	"diff":     {"<file>", "list the codes a file adds, changes and removes, exits 3 if there are any", runDiff},
+	"drop":     {"", "delete the database", runDrop},
+	"migrate":  {"up|down [steps]|status", "apply, roll back or list the schema migrations", runMigrate},
+	"keys":     {"", "list the stored swift codes, one per line", runKeys},
+	"imports":  {"", "list the import batches with the number of codes each one last wrote", runImports},
+	"rollback": {"<import-id>", "delete the codes inserted by an import batch and restore those it changed or removed, asking for confirmation unless -yes", runRollback},
+	"sync":     {"<file>", "make the database match a file in one transaction, asking for confirmation unless -yes", runSync},
+	"keygen":   {"<name>", "create the ed25519 key <name>.key for signing import files and print its trusted keys line", runKeygen},
+	"sign":     {"<file>...", "write the detached signature of each file, or with -manifest a signed SHA-256 manifest of them", runSign},
+}
+
+// stdin is read for confirmations
+var stdin io.Reader = os.Stdin
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+// run executes the command named by args[0] and returns the exit code
+func run(args []string, out io.Writer) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return EXIT_USAGE
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		usage(os.Stderr)
+		return EXIT_USAGE
+	}
+	flags := flag.NewFlagSet("swiftcodes-admin "+args[0], flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: swiftcodes-admin %s [flags] %s\n\n%s\n\n", args[0], cmd.args, cmd.usage)
+		flags.PrintDefaults()
+	}
+	return cmd.run(flags, args[1:], out)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: swiftcodes-admin <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].usage)
+	}
+}
+
+// aliasFlag collects -alias column=header flags, adding header names to the default aliases of the import columns
+type aliasFlag struct {
+	aliases initdb.Aliases
+}
+
+func newAliasFlag(flags *flag.FlagSet) *aliasFlag {
+	a := &aliasFlag{initdb.DefaultAliases()}
+	flags.Var(a, "alias", "another header name of an import column, as column=header, e.g. swift_code=Bank Identifier Code; may be repeated")
+	return a
+}
+
+func (a *aliasFlag) String() string {
+	return ""
+}
+
+func (a *aliasFlag) Set(value string) error {
+	column, header, ok := strings.Cut(value, "=")
+	if !ok || header == "" {
+		return fmt.Errorf("want column=header, got %q", value)
+	}
+	return a.aliases.Add(column, header)
+}
+
+// inputFlags are the -format and -alias flags describing an import file
+type inputFlags struct {
+	format  *formatFlag
+	aliases *aliasFlag
+}
+
+func newInputFlags(flags *flag.FlagSet) inputFlags {
+	format := &formatFlag{initdb.FORMAT_CSV}
+	flags.Var(format, "format", "import file format: "+strings.Join(initdb.FORMATS, ", ")+"; csv is this project's format, tab separated for .tsv files")
+	return inputFlags{format, newAliasFlag(flags)}
+}
+
+// open returns a reader of the import file at path and the file, which the caller closes
+func (input inputFlags) open(path string) (initdb.RecordReader, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := initdb.NewFormatReader(f, path, input.format.format, input.aliases.aliases)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return reader, f, nil
+}
+
+// readSource reads the import file at path, verifying it with its signature or the signed manifest if
+// trusted keys are configured. Without trusted keys the file is only digested and has no signer.
+func readSource(cfg config.Import, path string, manifest string, out io.Writer) ([]byte, verify.Result, bool) {
+	if cfg.TrustedKeys == "" {
+		if manifest != "" {
+			log.Print("Invalid configuration: -manifest needs SC_IMPORT_TRUSTED_KEYS to verify it with")
+			return nil, verify.Result{}, false
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Print("Couldn't read input file: ", err)
+			return nil, verify.Result{}, false
+		}
+		return data, verify.Result{FileName: filepath.Base(path), SHA256: verify.Digest(data)}, true
+	}
+	keys, err := verify.LoadKeys(cfg.TrustedKeys)
+	if err != nil {
+		log.Print("Invalid configuration: ", err)
+		return nil, verify.Result{}, false
+	}
+	data, result, err := verify.File(path, manifest, keys)
+	if err != nil {
+		log.Print("Couldn't verify input file: ", err)
+		return nil, verify.Result{}, false
+	}
+	fmt.Fprintf(out, "verified %s with SHA-256 %s signed by %s\n", result.FileName, result.SHA256, result.Signer)
+	return data, result, true
+}
+
+type formatFlag struct {
+	format string
+}
+
+func (f *formatFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.format
+}
+
+func (f *formatFlag) Set(value string) error {
+	if !slices.Contains(initdb.FORMATS, value) {
+		return fmt.Errorf("want one of %s, got %q", strings.Join(initdb.FORMATS, ", "), value)
+	}
+	f.format = value
+	return nil
+}
+
+// loadConfig parses args with flags and returns the configuration and the positional arguments, of which
+// there must be positional unless it is -1, or false after reporting an error
+func loadConfig(flags *flag.FlagSet, args []string, positional int) (config.Config, []string, bool) {
+	cfg, args, ok := readConfig(flags, args, positional)
+	if !ok {
+		return cfg, nil, false
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Print("Invalid configuration: ", err)
+		return cfg, nil, false
+	}
+	return cfg, args, true
+}
+
+// readConfig is loadConfig without checking that the database settings are complete
+func readConfig(flags *flag.FlagSet, args []string, positional int) (config.Config, []string, bool) {
+	cfg, err := config.ReadFlagSet(flags, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return cfg, nil, false
+	}
+	if err != nil {
+		log.Print("Invalid configuration: ", err)
+		return cfg, nil, false
+	}
+	if err := migrate.SetDir(cfg.DB.Migrations); err != nil {
+		log.Print("Invalid configuration: ", err)
+		return cfg, nil, false
+	}
+	if positional >= 0 && flags.NArg() != positional {
+		flags.Usage()
+		return cfg, nil, false
+	}
+	return cfg, flags.Args(), true
+}
+
+// openStore connects to a database at the schema version this binary expects
+func openStore(cfg config.DB) (*sql.DB, store.Store, error) {
+	db, err := store.Open(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := migrate.Check(context.Background(), db, cfg.Driver); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return db, store.New(cfg.Driver, db), nil
+}
+
+// clearCache has the API replicas drop the codes they cached, through the shared cache if there is one,
+// after a command changed codes. Without it they serve the changes once their entries expired.
+func clearCache(cfg config.Cache) {
+	if cfg.Redis == "" {
+		return
+	}
+	shared, err := cache.NewRedis(cfg.Redis, cfg.TTL, cfg.RedisPrefix)
+	if err == nil {
+		defer shared.Close()
+		err = shared.Invalidate(context.Background(), cache.ALL)
+	}
+	if err != nil {
+		log.Print("Failed to clear the shared cache, the API serves the previous codes until they expire: ", err)
+	}
+}
+
+func runCreate(flags *flag.FlagSet, args []string, out io.Writer) int {
+	cfg, _, ok := loadConfig(flags, args, 0)
+	if !ok {
+		return EXIT_USAGE
+	}
+	initdb.CreateDB(cfg.DB, false).Close()
+	fmt.Fprintf(out, "created database %s\n", cfg.DB.Name)
+	return EXIT_OK
+}
+
+func runImport(flags *flag.FlagSet, args []string, out io.Writer) int {
+	maxErrors := flags.Int("max-errors", 0, "number of failed rows tolerated before the import is aborted, -1 for no limit")
+	reportPath := flags.String("report", "", "file to write the JSON report with the errors of each failed row to, - for standard output")
+	bulk := flags.Bool("bulk", false, "load all rows in one transaction with multi-row inserts, rolling back on any failed insert")
+	dryRun := flags.Bool("dry-run", false, "only validate the file and report its data quality issues, -report writes them as JSON")
+	manifest := flags.String("manifest", "", "signed SHA-256 manifest listing the file, to verify instead of the file's own signature")
+	input := newInputFlags(flags)
+	// A dry run doesn't use the database, so it checks files without its settings
+	cfg, files, ok := readConfig(flags, args, 1)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if *dryRun {
+		return checkQuality(files[0], input, *reportPath, out)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Print("Invalid configuration: ", err)
+		return EXIT_USAGE
+	}
+	db, queries, err := openStore(cfg.DB)
+	if err != nil {
+		log.Print("Error opening DB: ", err)
+		return EXIT_ERROR
+	}
+	defer db.Close()
+
+	data, source, ok := readSource(cfg.Import, files[0], *manifest, out)
+	if !ok {
+		return EXIT_ERROR
+	}
+	reader, err := initdb.NewFormatReader(bytes.NewReader(data), files[0], input.format.format, input.aliases.aliases)
+	if err != nil {
+		log.Print("Couldn't read input file: ", err)
+		return EXIT_ERROR
+	}
+
+	batch := initdb.NewImport(source.FileName, source.SHA256, source.Signer)
+	var report initdb.Report
+	if *bulk {
+		report, err = initdb.BulkImport(context.Background(), db, cfg.DB.Driver, reader, *maxErrors, batch)
+	} else {
+		report, err = initdb.Import(context.Background(), queries, reader, *maxErrors, batch)
+	}
+	if *reportPath == "" {
+		for _, rowErr := range report.Errors {
+			fmt.Fprintln(out, rowErr.Error())
+		}
+	} else if err := writeReport(*reportPath, out, report); err != nil {
+		log.Print("Couldn't write report: ", err)
+	}
+	fmt.Fprintf(out, "imported %d of %d rows from %s, %d failed, in %.2fs (%.0f rows/s)\n", report.Imported, report.Rows, files[0], report.Failed, report.Seconds, report.RowsPerSecond)
+	if report.Imported > 0 {
+		fmt.Fprintf(out, "recorded as import %s\n", batch.ID)
+		clearCache(cfg.Cache)
+	}
+	if err != nil {
+		log.Print("Import aborted: ", err)
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+// checkQuality reports the data quality issues of an import file without touching the database
+func checkQuality(path string, input inputFlags, reportPath string, out io.Writer) int {
+	reader, f, err := input.open(path)
+	if err != nil {
+		log.Print("Couldn't read input file: ", err)
+		return EXIT_ERROR
+	}
+	defer f.Close()
+
+	report, err := initdb.CheckQuality(reader)
+	if err != nil {
+		log.Print("Couldn't check input file: ", err)
+		return EXIT_ERROR
+	}
+	if reportPath == "" {
+		err = report.WriteText(out)
+	} else {
+		err = writeReport(reportPath, out, report)
+	}
+	if err != nil {
+		log.Print("Couldn't write report: ", err)
+		return EXIT_ERROR
+	}
+	if report.Issues() > 0 {
+		return EXIT_ISSUES
+	}
+	return EXIT_OK
+}
+
+// writeReport writes report as JSON to path, or to out if path is -
+func writeReport(path string, out io.Writer, report any) error {
+	w := out
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// readFile reads all rows of an import file, failing if any row is invalid as that row's code would look removed
+func readFile(path string, input inputFlags, out io.Writer) ([]sqlcout.InsertSwiftCodeParams, []sqlcout.InsertCountryParams, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Print("Couldn't read input file: ", err)
+		return nil, nil, false
+	}
+	return parseFile(path, data, input, out)
+}
+
+// parseFile is readFile for the content of the file at path
+func parseFile(path string, data []byte, input inputFlags, out io.Writer) ([]sqlcout.InsertSwiftCodeParams, []sqlcout.InsertCountryParams, bool) {
+	reader, err := initdb.NewFormatReader(bytes.NewReader(data), path, input.format.format, input.aliases.aliases)
+	if err != nil {
+		log.Print("Couldn't read input file: ", err)
+		return nil, nil, false
+	}
+	records, report, err := initdb.ReadAll(reader, -1)
+	for _, rowErr := range report.Errors {
+		fmt.Fprintln(out, rowErr.Error())
+	}
+	if err != nil || report.Failed > 0 {
+		log.Printf("Couldn't read %s, %d invalid rows: %v", path, report.Failed, err)
+		return nil, nil, false
+	}
+	codes, countries := initdb.Split(records)
+	return codes, countries, true
+}
+
+func runExport(flags *flag.FlagSet, args []string, out io.Writer) int {
+	output := flags.String("o", "", "file to write, standard output if empty")
+	format := flags.String("format", initdb.EXPORT_TSV, "file format: "+strings.Join(initdb.EXPORT_FORMATS, ", "))
+	country := flags.String("country", "", "only export the codes of the country with this ISO2 code")
+	cfg, _, ok := loadConfig(flags, args, 0)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if !slices.Contains(initdb.EXPORT_FORMATS, *format) {
+		log.Printf("Unsupported format %q, want one of %s", *format, strings.Join(initdb.EXPORT_FORMATS, ", "))
+		return EXIT_USAGE
+	}
+	db, queries, err := openStore(cfg.DB)
+	if err != nil {
+		log.Print("Error opening DB: ", err)
+		return EXIT_ERROR
+	}
+	defer db.Close()
+
+	w := out
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Print("Couldn't create output file: ", err)
+			return EXIT_ERROR
+		}
+		defer f.Close()
+		w = f
+	}
+	writer, err := initdb.NewExportWriter(w, *format)
+	if err != nil {
+		log.Print("Failed to write export: ", err)
+		return EXIT_ERROR
+	}
+	if err := queries.EachSwiftCode(context.Background(), strings.ToUpper(*country), writer.Write); err != nil {
+		writer.Discard()
+		log.Print("Failed to export swift codes: ", err)
+		return EXIT_ERROR
+	}
+	if err := writer.Close(); err != nil {
+		log.Print("Failed to write export: ", err)
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+func runDiff(flags *flag.FlagSet, args []string, out io.Writer) int {
+	input := newInputFlags(flags)
+	cfg, files, ok := loadConfig(flags, args, 1)
+	if !ok {
+		return EXIT_USAGE
+	}
+	db, queries, err := openStore(cfg.DB)
+	if err != nil {
+		log.Print("Error opening DB: ", err)
+		return EXIT_ERROR
+	}
+	defer db.Close()
+
+	stored, err := queries.ListSwiftCodes(context.Background())
+	if err != nil {
+		log.Print("Failed to list swift codes: ", err)
+		return EXIT_ERROR
+	}
+	codes, _, ok := readFile(files[0], input, out)
+	if !ok {
+		return EXIT_ERROR
+	}
+	diff := initdb.DiffCodes(stored, codes)
+	printDiff(out, diff)
+	if !diff.Empty() {
+		return EXIT_DIFF
+	}
+	return EXIT_OK
+}
+
+// printDiff lists the codes added (+), changed (~) and removed (-) with their new details and the values
+// corrected through the API that the file changes or removes (!), then the totals
+func printDiff(out io.Writer, diff initdb.Diff) {
+	for _, code := range diff.Added {
+		fmt.Fprintf(out, "+ %s\t%s\t%s\t%s\n", code.SwiftCode, code.CountryISO2, code.BankName, code.Address)
+	}
+	for _, change := range diff.Changed {
+		fmt.Fprintf(out, "~ %s\t%s\t%s\t%s\n", change.New.SwiftCode, change.New.CountryISO2, change.New.BankName, change.New.Address)
+	}
+	for _, code := range diff.Removed {
+		fmt.Fprintf(out, "- %s\t%s\t%s\t%s\n", code.SwiftCode, code.CountryISO2, code.BankName, code.Address)
+	}
+	for _, conflict := range diff.Conflicts {
+		if conflict.Removed {
+			fmt.Fprintf(out, "! %s\t%s\tmanual %q\tremoved\n", conflict.SwiftCode, conflict.Field, conflict.Manual)
+			continue
+		}
+		fmt.Fprintf(out, "! %s\t%s\tmanual %q\timport %q\n", conflict.SwiftCode, conflict.Field, conflict.Manual, conflict.Import)
+	}
+	fmt.Fprintf(out, "%d added, %d changed, %d removed\n", len(diff.Added), len(diff.Changed), len(diff.Removed))
+	if len(diff.Conflicts) > 0 {
+		fmt.Fprintf(out, "%d conflicts (!) with values corrected through the API\n", len(diff.Conflicts))
+	}
+}
+
+func runSync(flags *flag.FlagSet, args []string, out io.Writer) int {
+	yes := flags.Bool("yes", false, "apply the changes without asking for confirmation")
+	manifest := flags.String("manifest", "", "signed SHA-256 manifest listing the file, to verify instead of the file's own signature")
+	policy := flags.String("conflicts", initdb.POLICY_KEEP_MANUAL, "how to resolve values corrected through the API that the file changes: "+strings.Join(initdb.POLICIES, ", "))
+	input := newInputFlags(flags)
+	cfg, files, ok := loadConfig(flags, args, 1)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if !slices.Contains(initdb.POLICIES, *policy) {
+		log.Printf("Unsupported conflict policy %q, want one of %s", *policy, strings.Join(initdb.POLICIES, ", "))
+		return EXIT_USAGE
+	}
+	db, queries, err := openStore(cfg.DB)
+	if err != nil {
+		log.Print("Error opening DB: ", err)
+		return EXIT_ERROR
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	stored, err := queries.ListSwiftCodes(ctx)
+	if err != nil {
+		log.Print("Failed to list swift codes: ", err)
+		return EXIT_ERROR
+	}
+	data, source, ok := readSource(cfg.Import, files[0], *manifest, out)
+	if !ok {
+		return EXIT_ERROR
+	}
+	codes, countries, ok := parseFile(files[0], data, input, out)
+	if !ok {
+		return EXIT_ERROR
+	}
+	diff, err := initdb.DiffCodes(stored, codes).Resolve(*policy)
+	printDiff(out, diff)
+	if err != nil {
+		log.Print("Sync refused, nothing changed: ", err)
+		return EXIT_ERROR
+	}
+	if len(diff.Conflicts) > 0 {
+		fmt.Fprintf(out, "conflicts resolved with %s\n", *policy)
+	}
+	if diff.Empty() {
+		return EXIT_OK
+	}
+	if !*yes && !confirm(out, "Apply these changes?") {
+		fmt.Fprintln(out, "sync cancelled, nothing changed")
+		return EXIT_ERROR
+	}
+	batch := initdb.NewImport(source.FileName, source.SHA256, source.Signer)
+	if err := initdb.Sync(ctx, db, cfg.DB.Driver, diff, countries, batch); err != nil {
+		log.Print("Sync failed, nothing changed: ", err)
+		return EXIT_ERROR
+	}
+	clearCache(cfg.Cache)
+	fmt.Fprintf(out, "sync applied as import %s\n", batch.ID)
+	return EXIT_OK
+}
+
+// confirm asks question on out and reports whether it was answered yes on stdin
+func confirm(out io.Writer, question string) bool {
+	fmt.Fprint(out, question+" [y/N] ")
+	answer, _ := bufio.NewReader(stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func runImports(flags *flag.FlagSet, args []string, out io.Writer) int {
+	cfg, _, ok := loadConfig(flags, args, 0)
+	if !ok {
+		return EXIT_USAGE
+	}
+	db, queries, err := openStore(cfg.DB)
+	if err != nil {
+		log.Print("Error opening DB: ", err)
+		return EXIT_ERROR
+	}
+	defer db.Close()
+
+	imports, err := queries.ListImports(context.Background())
+	if err != nil {
+		log.Print("Failed to list imports: ", err)
+		return EXIT_ERROR
+	}
+	for _, i := range imports {
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%d codes\n", i.ID, i.ImportedAt, i.FileName, i.Sha256, i.Signer, i.Records)
+	}
+	return EXIT_OK
+}
+
+func runRollback(flags *flag.FlagSet, args []string, out io.Writer) int {
+	yes := flags.Bool("yes", false, "delete the codes without asking for confirmation")
+	cfg, ids, ok := loadConfig(flags, args, 1)
+	if !ok {
+		return EXIT_USAGE
+	}
+	db, queries, err := openStore(cfg.DB)
+	if err != nil {
+		log.Print("Error opening DB: ", err)
+		return EXIT_ERROR
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	imports, err := queries.ListImports(ctx)
+	if err != nil {
+		log.Print("Failed to list imports: ", err)
+		return EXIT_ERROR
+	}
+	if !slices.ContainsFunc(imports, func(i sqlcout.ListImportsRow) bool { return i.ID == ids[0] }) {
+		log.Printf("Unknown import %q, see the imports command", ids[0])
+		return EXIT_ERROR
+	}
+	plan, err := initdb.PlanRollback(ctx, queries, ids[0])
+	if err != nil {
+		log.Print("Failed to list swift codes: ", err)
+		return EXIT_ERROR
+	}
+	for _, code := range plan.Deleted {
+		fmt.Fprintf(out, "- %s\n", code)
+	}
+	for _, code := range plan.Restored {
+		fmt.Fprintf(out, "~ %s\n", code)
+	}
+	fmt.Fprintf(out, "%d codes from import %s to delete, %d to restore\n", len(plan.Deleted), ids[0], len(plan.Restored))
+	if len(plan.Deleted) == 0 && len(plan.Restored) == 0 {
+		return EXIT_OK
+	}
+	if !*yes && !confirm(out, "Delete and restore these codes?") {
+		fmt.Fprintln(out, "rollback cancelled, nothing changed")
+		return EXIT_ERROR
+	}
+	done, err := initdb.RollbackImport(ctx, db, cfg.DB.Driver, ids[0])
+	if err != nil {
+		log.Print("Rollback failed, nothing changed: ", err)
+		return EXIT_ERROR
+	}
+	clearCache(cfg.Cache)
+	fmt.Fprintf(out, "rolled back import %s, %d codes deleted, %d restored\n", ids[0], len(done.Deleted), len(done.Restored))
+	return EXIT_OK
+}
+
+func runDrop(flags *flag.FlagSet, args []string, out io.Writer) int {
+	yes := flags.Bool("yes", false, "confirm deleting the database and all its data")
+	cfg, _, ok := loadConfig(flags, args, 0)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if !*yes {
+		log.Print("Refusing to drop database ", cfg.DB.Name, " without -yes")
+		return EXIT_USAGE
+	}
+	if err := initdb.DropDB(cfg.DB); err != nil {
+		log.Print("Failed to drop database: ", err)
+		return EXIT_ERROR
+	}
+	fmt.Fprintf(out, "dropped database %s\n", cfg.DB.Name)
+	return EXIT_OK
+}
+
+func runMigrate(flags *flag.FlagSet, args []string, out io.Writer) int {
+	cfg, positional, ok := loadConfig(flags, args, -1)
+	if !ok {
+		return EXIT_USAGE
+	}
+	if len(positional) == 0 {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+	action := positional[0]
+	steps := 1
+	switch {
+	case action == "down" && len(positional) == 2:
+		n, err := strconv.Atoi(positional[1])
+		if err != nil || n <= 0 {
+			flags.Usage()
+			return EXIT_USAGE
+		}
+		steps = n
+	case (action == "up" || action == "down" || action == "status") && len(positional) == 1:
+	default:
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	db, err := store.Open(cfg.DB)
+	if err != nil {
+		log.Print("Error opening DB: ", err)
+		return EXIT_ERROR
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	var done []migrate.Migration
+	switch action {
+	case "up":
+		done, err = migrate.Up(ctx, db, cfg.DB.Driver)
+	case "down":
+		done, err = migrate.Down(ctx, db, cfg.DB.Driver, steps)
+	case "status":
+		var statuses []migrate.Status
+		statuses, err = migrate.Statuses(ctx, db, cfg.DB.Driver)
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt
+			}
+			if !s.Known {
+				state += " (unknown to this version)"
+			}
+			fmt.Fprintf(out, "%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+	}
+	for _, m := range done {
+		fmt.Fprintf(out, "%s %04d_%s\n", action, m.Version, m.Name)
+	}
+	if err != nil {
+		log.Print("Migration failed: ", err)
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+func runKeys(flags *flag.FlagSet, args []string, out io.Writer) int {
+	country := flags.String("country", "", "only list the codes of the country with this ISO2 code")
+	cfg, _, ok := loadConfig(flags, args, 0)
+	if !ok {
+		return EXIT_USAGE
+	}
+	db, queries, err := openStore(cfg.DB)
+	if err != nil {
+		log.Print("Error opening DB: ", err)
+		return EXIT_ERROR
+	}
+	defer db.Close()
+
+	err = queries.EachSwiftCode(context.Background(), strings.ToUpper(*country), func(code sqlcout.ListSwiftCodesRow) error {
+		_, err := fmt.Fprintln(out, code.SwiftCode)
+		return err
+	})
+	if err != nil {
+		log.Print("Failed to list swift codes: ", err)
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+// parseFlags parses the flags of commands that don't use the database, which take no configuration
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, bool) {
+	if err := flags.Parse(args); err != nil {
+		return nil, false
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return nil, false
+	}
+	return flags.Args(), true
+}
+
+func runKeygen(flags *flag.FlagSet, args []string, out io.Writer) int {
+	names, ok := parseFlags(flags, args)
+	if !ok || len(names) != 1 {
+		return EXIT_USAGE
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Print("Failed to generate key: ", err)
+		return EXIT_ERROR
+	}
+	path := names[0] + ".key"
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		log.Print("Couldn't write private key: ", err)
+		return EXIT_ERROR
+	}
+	_, err = fmt.Fprintln(f, base64.StdEncoding.EncodeToString(private.Seed()))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Print("Couldn't write private key: ", err)
+		return EXIT_ERROR
+	}
+	fmt.Fprintf(out, "%s %s\n", filepath.Base(names[0]), base64.StdEncoding.EncodeToString(public))
+	return EXIT_OK
+}
+
+func runSign(flags *flag.FlagSet, args []string, out io.Writer) int {
+	keyPath := flags.String("key", "", "private key file written by keygen")
+	manifest := flags.String("manifest", "", "manifest to write listing the digests of the files, signed instead of each file")
+	files, ok := parseFlags(flags, args)
+	if !ok {
+		return EXIT_USAGE
+	}
+	encoded, err := os.ReadFile(*keyPath)
+	if err != nil {
+		log.Print("Couldn't read private key: ", err)
+		return EXIT_USAGE
+	}
+	key, err := verify.ParsePrivateKey(encoded)
+	if err != nil {
+		log.Print("Invalid private key: ", err)
+		return EXIT_USAGE
+	}
+
+	contents := make(map[string][]byte)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Print("Couldn't read input file: ", err)
+			return EXIT_ERROR
+		}
+		contents[path] = data
+	}
+	signed := files
+	if *manifest != "" {
+		listing := make(map[string][]byte)
+		for path, data := range contents {
+			listing[filepath.Base(path)] = data
+		}
+		contents = map[string][]byte{*manifest: verify.Manifest(listing)}
+		if err := os.WriteFile(*manifest, contents[*manifest], 0o644); err != nil {
+			log.Print("Couldn't write manifest: ", err)
+			return EXIT_ERROR
+		}
+		signed = []string{*manifest}
+	}
+	for _, path := range signed {
+		if err := os.WriteFile(path+verify.SIGNATURE_SUFFIX, verify.Sign(key, contents[path]), 0o644); err != nil {
+			log.Print("Couldn't write signature: ", err)
+			return EXIT_ERROR
+		}
+		fmt.Fprintf(out, "signed %s\n", path)
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"swiftcodes/internal/config"
+	"swiftcodes/internal/initdb"
+	"swiftcodes/internal/store"
+	"swiftcodes/internal/verify"
+	"swiftcodes/sqlcout"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+const TEST_FILE = "COUNTRY ISO2 CODE\tSWIFT CODE\tCODE TYPE\tNAME\tADDRESS\tTOWN NAME\tCOUNTRY NAME\tTIME ZONE\r\n" +
+	"PL\tBIGBPLPWXXX\tBIC11\tBANK MILLENNIUM S.A.\tHARMONY CENTER UL. STANISLAWA ZARYNA 2A WARSZAWA\tWARSZAWA\tPOLAND\tEurope/Warsaw\r\n" +
+	"PL\tBIGBPLPWCUS\tBIC11\tBANK MILLENNIUM S.A.\tHARMONY CENTER UL. STANISLAWA ZARYNA 2A WARSZAWA\tWARSZAWA\tPOLAND\tEurope/Warsaw\r\n" +
+	"MT\tAKBKMTMTXXX\tBIC11\tAKBANK T.A.S. (MALTA BRANCH)\tPORTOMASO BUSINESS TOWER\tST. JULIAN'S\tMALTA\tEurope/Malta\r\n"
+
+func runCommand(t *testing.T, args ...string) (int, string) {
+	var out bytes.Buffer
+	code := run(args, &out)
+	return code, out.String()
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	dbFlags := []string{"-db-driver", "sqlite", "-db-name", filepath.Join(dir, "admin.db")}
+	input := filepath.Join(dir, "input.tsv")
+	if err := os.WriteFile(input, []byte(TEST_FILE), 0o600); err != nil {
+		t.Fatalf("error writing input file: %v", err)
+	}
+	changed := filepath.Join(dir, "changed.tsv")
+	if err := os.WriteFile(changed, []byte(strings.Replace(TEST_FILE, "PORTOMASO BUSINESS TOWER", "PORTOMASO", 1)), 0o600); err != nil {
+		t.Fatalf("error writing changed file: %v", err)
+	}
+	synced := filepath.Join(dir, "synced.tsv")
+	syncedFile := strings.Replace(TEST_FILE, "PORTOMASO BUSINESS TOWER", "PORTOMASO", 1)
+	syncedFile = strings.Replace(syncedFile, "PL\tBIGBPLPWCUS", "DE\tDEUTDEFFXXX", 1)
+	if err := os.WriteFile(synced, []byte(syncedFile), 0o600); err != nil {
+		t.Fatalf("error writing synced file: %v", err)
+	}
+	exported := filepath.Join(dir, "export.tsv")
+	stdin = strings.NewReader("n\n")
+
+	tt := []struct {
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{nil, EXIT_USAGE, ""},
+		{[]string{"unknown"}, EXIT_USAGE, ""},
+		{append([]string{"keys"}, dbFlags...), EXIT_ERROR, ""},
+		{append([]string{"create"}, dbFlags...), EXIT_OK, "created database"},
+		{append([]string{"migrate"}, append(dbFlags, "status")...), EXIT_OK, "0001_create_tables\tapplied"},
+		{append([]string{"migrate"}, append(dbFlags, "sideways")...), EXIT_USAGE, ""},
+		{append([]string{"import"}, dbFlags...), EXIT_USAGE, ""},
+		{append([]string{"import"}, append(dbFlags, input)...), EXIT_OK, "imported 3 of 3 rows"},
+		{append([]string{"import"}, append(dbFlags, input)...), EXIT_ERROR, "imported 0 of 1 rows from " + input + ", 1 failed"},
+		{append([]string{"import", "-max-errors", "-1"}, append(dbFlags, input)...), EXIT_OK, "imported 0 of 3 rows from " + input + ", 3 failed"},
+		{append([]string{"keys"}, dbFlags...), EXIT_OK, "AKBKMTMTXXX\nBIGBPLPWCUS\nBIGBPLPWXXX\n"},
+		{append([]string{"keys", "-country", "mt"}, dbFlags...), EXIT_OK, "AKBKMTMTXXX\n"},
+		{append([]string{"diff"}, append(dbFlags, input)...), EXIT_OK, "0 added, 0 changed, 0 removed\n"},
+		{append([]string{"diff"}, append(dbFlags, changed)...), EXIT_DIFF, "~ AKBKMTMTXXX\tMT\tAKBANK T.A.S. (MALTA BRANCH)\tPORTOMASO\n0 added, 1 changed, 0 removed\n"},
+		{append([]string{"export", "-format", "xml"}, dbFlags...), EXIT_USAGE, ""},
+		{append([]string{"export", "-o", exported}, dbFlags...), EXIT_OK, ""},
+		{append([]string{"export", "-format", "jsonl", "-country", "mt"}, dbFlags...), EXIT_OK, `{"swiftCode":"AKBKMTMTXXX","bankName":"AKBANK T.A.S. (MALTA BRANCH)"`},
+		{append([]string{"sync"}, append(dbFlags, input)...), EXIT_OK, "0 added, 0 changed, 0 removed\n"},
+		{append([]string{"sync"}, append(dbFlags, synced)...), EXIT_ERROR, "1 added, 1 changed, 1 removed\nApply these changes? [y/N] sync cancelled"},
+		{append([]string{"sync", "-yes"}, append(dbFlags, synced)...), EXIT_OK, "+ DEUTDEFFXXX\tDE\tBANK MILLENNIUM S.A."},
+		{append([]string{"diff"}, append(dbFlags, synced)...), EXIT_OK, "0 added, 0 changed, 0 removed\n"},
+		{append([]string{"keys", "-country", "de"}, dbFlags...), EXIT_OK, "DEUTDEFFXXX\n"},
+		{append([]string{"drop"}, dbFlags...), EXIT_USAGE, ""},
+		{append([]string{"drop", "-yes"}, dbFlags...), EXIT_OK, "dropped database"},
+	}
+
+	for i := 0; i < len(tt); i++ {
+		code, out := runCommand(t, tt[i].args...)
+		if code != tt[i].wantCode || !strings.Contains(out, tt[i].wantOut) {
+			t.Errorf("run(%v) = %v, %q, want %v, %q", tt[i].args, code, out, tt[i].wantCode, tt[i].wantOut)
+		}
+	}
+
+	// The export is read back as the same rows it was imported from, in code order
+	inputRows, err := initdb.ReadCSV(input)
+	if err != nil {
+		t.Fatalf("ReadCSV() error: %v", err)
+	}
+	exportedRows, err := initdb.ReadCSV(exported)
+	if err != nil {
+		t.Fatalf("ReadCSV() error: %v", err)
+	}
+	wantCodes, wantCountries, err := initdb.ParseData(inputRows)
+	if err != nil {
+		t.Fatalf("ParseData() error: %v", err)
+	}
+	gotCodes, gotCountries, err := initdb.ParseData(exportedRows)
+	if err != nil {
+		t.Fatalf("ParseData() error: %v", err)
+	}
+	sort.Slice(wantCodes, func(i, j int) bool { return wantCodes[i].SwiftCode < wantCodes[j].SwiftCode })
+	sort.Slice(wantCountries, func(i, j int) bool { return wantCountries[i].CountryISO2 < wantCountries[j].CountryISO2 })
+	if !reflect.DeepEqual(gotCodes, wantCodes) || !reflect.DeepEqual(gotCountries, wantCountries) {
+		t.Errorf("export = %v, %v, want %v, %v", gotCodes, gotCountries, wantCodes, wantCountries)
+	}
+}
+
+func TestImportReport(t *testing.T) {
+	dir := t.TempDir()
+	dbFlags := []string{"-db-driver", "sqlite", "-db-name", filepath.Join(dir, "admin.db")}
+	input := filepath.Join(dir, "input.tsv")
+	content := TEST_FILE + "PL\tBIGBPLPW\r\n" + "PL\tBIGBPLPWXX\tBIC11\tBANK\tADDRESS\tWARSZAWA\tPOLAND\tEurope/Warsaw\r\n"
+	if err := os.WriteFile(input, []byte(content), 0o600); err != nil {
+		t.Fatalf("error writing input file: %v", err)
+	}
+	if code, out := runCommand(t, append([]string{"create"}, dbFlags...)...); code != EXIT_OK {
+		t.Fatalf("create = %v, %q", code, out)
+	}
+
+	code, out := runCommand(t, append([]string{"import", "-max-errors", "2", "-report", "-"}, append(dbFlags, input)...)...)
+	if code != EXIT_OK {
+		t.Errorf("import = %v, %q, want %v", code, out, EXIT_OK)
+	}
+	var report initdb.Report
+	if err := json.NewDecoder(strings.NewReader(out)).Decode(&report); err != nil {
+		t.Fatalf("error decoding report %q: %v", out, err)
+	}
+	report.Seconds, report.RowsPerSecond = 0, 0
+	want := initdb.Report{Rows: 5, Imported: 3, Failed: 2, Errors: []initdb.RowError{
+		{Line: 5, Message: "has 2 columns, want at least 7"},
+		{Line: 6, SwiftCode: "BIGBPLPWXX", Message: `invalid BIC "BIGBPLPWXX": must be 8 or 11 characters long`},
+	}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("import report = %+v, want %+v", report, want)
+	}
+}
+
+func TestImportColumns(t *testing.T) {
+	dir := t.TempDir()
+	dbFlags := []string{"-db-driver", "sqlite", "-db-name", filepath.Join(dir, "admin.db")}
+	vendor := filepath.Join(dir, "vendor.csv")
+	if err := os.WriteFile(vendor, []byte("Institution,Bank Identifier Code,Country,ISO2\nBANK MILLENNIUM S.A.,BIGBPLPWXXX,Poland,PL\n"), 0o600); err != nil {
+		t.Fatalf("error writing vendor file: %v", err)
+	}
+	if code, out := runCommand(t, append([]string{"create"}, dbFlags...)...); code != EXIT_OK {
+		t.Fatalf("create = %v, %q", code, out)
+	}
+
+	tt := []struct {
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{append([]string{"import"}, append(dbFlags, vendor)...), EXIT_ERROR, "imported 0 of 0 rows"},
+		{append([]string{"import", "-alias", "bic=Bank Identifier Code"}, append(dbFlags, vendor)...), EXIT_USAGE, ""},
+		{append([]string{"import", "-alias", "swift_code=Bank Identifier Code"}, append(dbFlags, vendor)...), EXIT_OK, "imported 1 of 1 rows"},
+		{append([]string{"keys"}, dbFlags...), EXIT_OK, "BIGBPLPWXXX\n"},
+		{append([]string{"import", "-bulk", "-alias", "swift_code=Bank Identifier Code"}, append(dbFlags, vendor)...), EXIT_ERROR, "imported 0 of 1 rows"},
+	}
+	for i := 0; i < len(tt); i++ {
+		code, out := runCommand(t, tt[i].args...)
+		if code != tt[i].wantCode || !strings.Contains(out, tt[i].wantOut) {
+			t.Errorf("run(%v) = %v, %q, want %v, %q", tt[i].args, code, out, tt[i].wantCode, tt[i].wantOut)
+		}
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	dir := t.TempDir()
+	dbFlags := []string{"-db-driver", "sqlite", "-db-name", filepath.Join(dir, "admin.db")}
+	input := filepath.Join(dir, "input.tsv")
+	if err := os.WriteFile(input, []byte(TEST_FILE+"MT\tAKBKMTMTXXX\tBIC11\tAKBANK\tPORTOMASO \tST. JULIAN'S\tMALTA\tEurope/Malta\r\n"), 0o600); err != nil {
+		t.Fatalf("error writing input file: %v", err)
+	}
+	if code, out := runCommand(t, append([]string{"import", "-dry-run"}, append(dbFlags, input)...)...); code != EXIT_ISSUES || !strings.Contains(out, "checked 4 rows, found 2 issues") {
+		t.Errorf("import -dry-run = %v, %q, want %v", code, out, EXIT_ISSUES)
+	}
+
+	code, out := runCommand(t, append([]string{"import", "-dry-run", "-report", "-"}, append(dbFlags, input)...)...)
+	if code != EXIT_ISSUES {
+		t.Errorf("import -dry-run -report - = %v, %q, want %v", code, out, EXIT_ISSUES)
+	}
+	var report initdb.QualityReport
+	if err := json.NewDecoder(strings.NewReader(out)).Decode(&report); err != nil {
+		t.Fatalf("error decoding report %q: %v", out, err)
+	}
+	want := []initdb.DuplicateCode{{SwiftCode: "AKBKMTMTXXX", Lines: []int{4, 5}}}
+	if report.Rows != 4 || !reflect.DeepEqual(report.DuplicateCodes, want) || len(report.StrayWhitespace) != 1 {
+		t.Errorf("import -dry-run report = %+v, want 4 rows, duplicates %+v and 1 stray whitespace", report, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "admin.db")); !os.IsNotExist(err) {
+		t.Errorf("import -dry-run touched the database, stat error = %v", err)
+	}
+
+	// Files are checked without database credentials
+	for _, env := range []string{"SC_DB_USER", "SC_DB_HOST", "SC_DB_PORT", "SC_DB_NAME"} {
+		t.Setenv(env, "")
+	}
+	if code, out := runCommand(t, "import", "-dry-run", "-db-driver", "mysql", input); code != EXIT_ISSUES || !strings.Contains(out, "checked 4 rows") {
+		t.Errorf("import -dry-run without database settings = %v, %q, want %v", code, out, EXIT_ISSUES)
+	}
+	if code, _ := runCommand(t, "import", "-db-driver", "mysql", input); code != EXIT_USAGE {
+		t.Errorf("import without database settings = %v, want %v", code, EXIT_USAGE)
+	}
+}
+
+func TestImportFormats(t *testing.T) {
+	dir := t.TempDir()
+	dbFlags := []string{"-db-driver", "sqlite", "-db-name", filepath.Join(dir, "admin.db")}
+	fixtures := filepath.Join("..", "..", "internal", "initdb", "testdata")
+	if code, out := runCommand(t, append([]string{"create"}, dbFlags...)...); code != EXIT_OK {
+		t.Fatalf("create = %v, %q", code, out)
+	}
+
+	tt := []struct {
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{append([]string{"import", "-format", "fixed"}, append(dbFlags, filepath.Join(fixtures, "bicplus.txt"))...), EXIT_USAGE, ""},
+		{append([]string{"import", "-format", "bicplus", "-max-errors", "1"}, append(dbFlags, filepath.Join(fixtures, "bicplus.txt"))...), EXIT_OK, "imported 3 of 4 rows"},
+		{append([]string{"diff", "-format", "iso20022"}, append(dbFlags, filepath.Join(fixtures, "iso20022.xml"))...), EXIT_ERROR, "line 41: AKBKMTMTXXX: bank name is empty"},
+		{append([]string{"import", "-dry-run", "-format", "bicdir2018"}, append(dbFlags, filepath.Join(fixtures, "bicdir2018.dat"))...), EXIT_ISSUES, "checked 4 rows, found 1 issues"},
+		{append([]string{"keys"}, dbFlags...), EXIT_OK, "AKBKMTMTXXX\nBIGBPLPWCUS\nBIGBPLPWXXX\n"},
+	}
+	for i := 0; i < len(tt); i++ {
+		code, out := runCommand(t, tt[i].args...)
+		if code != tt[i].wantCode || !strings.Contains(out, tt[i].wantOut) {
+			t.Errorf("run(%v) = %v, %q, want %v, %q", tt[i].args, code, out, tt[i].wantCode, tt[i].wantOut)
+		}
+	}
+}
+
+func TestSignedImport(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "admin.db")
+	dbFlags := []string{"-db-driver", "sqlite", "-db-name", dbPath}
+	input := filepath.Join(dir, "input.tsv")
+	if err := os.WriteFile(input, []byte(TEST_FILE), 0o600); err != nil {
+		t.Fatalf("error writing input file: %v", err)
+	}
+	if code, out := runCommand(t, append([]string{"create"}, dbFlags...)...); code != EXIT_OK {
+		t.Fatalf("create = %v, %q", code, out)
+	}
+	code, trustedLine := runCommand(t, "keygen", filepath.Join(dir, "vendor"))
+	if code != EXIT_OK || !strings.HasPrefix(trustedLine, "vendor ") {
+		t.Fatalf("keygen = %v, %q, want the trusted keys line of vendor", code, trustedLine)
+	}
+	trustedKeys := filepath.Join(dir, "trusted_keys")
+	if err := os.WriteFile(trustedKeys, []byte(trustedLine), 0o600); err != nil {
+		t.Fatalf("error writing trusted keys: %v", err)
+	}
+	verifiedFlags := append([]string{"-import-trusted-keys", trustedKeys}, dbFlags...)
+	key := filepath.Join(dir, "vendor.key")
+	manifest := filepath.Join(dir, "SHA256SUMS")
+
+	tt := []struct {
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{append([]string{"import"}, append(verifiedFlags, input)...), EXIT_ERROR, ""},
+		{[]string{"sign", input}, EXIT_USAGE, ""},
+		{[]string{"sign", "-key", key, input}, EXIT_OK, "signed " + input},
+		{append([]string{"import", "-manifest", manifest}, append(verifiedFlags, input)...), EXIT_ERROR, ""},
+		{[]string{"sign", "-key", key, "-manifest", manifest, input}, EXIT_OK, "signed " + manifest},
+		{append([]string{"import", "-manifest", manifest}, append(dbFlags, input)...), EXIT_ERROR, ""},
+		{append([]string{"import", "-manifest", manifest}, append(verifiedFlags, input)...), EXIT_OK, "signed by vendor\nimported 3 of 3 rows"},
+		{append([]string{"sync", "-yes"}, append(verifiedFlags, input)...), EXIT_OK, "signed by vendor\n0 added, 0 changed, 0 removed"},
+	}
+	for i := 0; i < len(tt); i++ {
+		code, out := runCommand(t, tt[i].args...)
+		if code != tt[i].wantCode || !strings.Contains(out, tt[i].wantOut) {
+			t.Errorf("run(%v) = %v, %q, want %v, %q", tt[i].args, code, out, tt[i].wantCode, tt[i].wantOut)
+		}
+	}
+
+	if err := os.WriteFile(input, []byte(strings.Replace(TEST_FILE, "PORTOMASO", "ELSEWHERE", 1)), 0o600); err != nil {
+		t.Fatalf("error writing tampered file: %v", err)
+	}
+	if code, out := runCommand(t, append([]string{"sync", "-yes"}, append(verifiedFlags, input)...)...); code != EXIT_ERROR || strings.Contains(out, "changed") {
+		t.Errorf("sync of a tampered file = %v, %q, want %v before any diff", code, out, EXIT_ERROR)
+	}
+
+	db, err := store.Open(config.DB{Driver: config.DRIVER_SQLITE, Name: dbPath})
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	imports, err := store.New(config.DRIVER_SQLITE, db).ListImports(context.Background())
+	if err != nil || len(imports) != 1 {
+		t.Fatalf("ListImports() = %+v, %v, want the one import that inserted rows", imports, err)
+	}
+	if imports[0].FileName != "input.tsv" || imports[0].Signer != "vendor" || imports[0].Sha256 != verify.Digest([]byte(TEST_FILE)) || imports[0].ImportedAt == "" {
+		t.Errorf("ListImports() = %+v, want input.tsv with its digest signed by vendor", imports[0])
+	}
+}
+
+func TestRollback(t *testing.T) {
+	dir := t.TempDir()
+	dbFlags := []string{"-db-driver", "sqlite", "-db-name", filepath.Join(dir, "admin.db")}
+	input := filepath.Join(dir, "input.tsv")
+	if err := os.WriteFile(input, []byte(TEST_FILE), 0o600); err != nil {
+		t.Fatalf("error writing input file: %v", err)
+	}
+	// The sync changes AKBKMTMTXXX, removes BIGBPLPWCUS and adds BIGBPLPWABC
+	changedFile := strings.Replace(TEST_FILE, "PORTOMASO BUSINESS TOWER", "PORTOMASO", 1)
+	changedFile = strings.Replace(changedFile, "BIGBPLPWCUS", "BIGBPLPWABC", 1)
+	changed := filepath.Join(dir, "changed.tsv")
+	if err := os.WriteFile(changed, []byte(changedFile), 0o600); err != nil {
+		t.Fatalf("error writing changed file: %v", err)
+	}
+	if code, out := runCommand(t, append([]string{"create"}, dbFlags...)...); code != EXIT_OK {
+		t.Fatalf("create = %v, %q", code, out)
+	}
+	code, out := runCommand(t, append([]string{"import"}, append(dbFlags, input)...)...)
+	_, imported, _ := strings.Cut(out, "recorded as import ")
+	imported = strings.TrimSpace(imported)
+	if code != EXIT_OK || imported == "" {
+		t.Fatalf("import = %v, %q, want the import ID", code, out)
+	}
+	code, out = runCommand(t, append([]string{"sync", "-yes"}, append(dbFlags, changed)...)...)
+	_, synced, _ := strings.Cut(out, "sync applied as import ")
+	synced = strings.TrimSpace(synced)
+	if code != EXIT_OK || synced == "" {
+		t.Fatalf("sync = %v, %q, want the import ID", code, out)
+	}
+	stdin = strings.NewReader("n\n")
+
+	tt := []struct {
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{append([]string{"imports"}, dbFlags...), EXIT_OK, imported + "\t"},
+		{append([]string{"imports"}, dbFlags...), EXIT_OK, "input.tsv\t" + verify.Digest([]byte(TEST_FILE)) + "\t\t1 codes\n"},
+		{append([]string{"imports"}, dbFlags...), EXIT_OK, "changed.tsv\t"},
+		{append([]string{"rollback"}, dbFlags...), EXIT_USAGE, ""},
+		{append([]string{"rollback"}, append(dbFlags, "0123")...), EXIT_ERROR, ""},
+		// Rolling back the sync restores the rows it changed and removed
+		{append([]string{"rollback"}, append(dbFlags, synced)...), EXIT_ERROR, "- BIGBPLPWABC\n~ AKBKMTMTXXX\n~ BIGBPLPWCUS\n1 codes from import " + synced + " to delete, 2 to restore"},
+		{append([]string{"rollback", "-yes"}, append(dbFlags, synced)...), EXIT_OK, "rolled back import " + synced + ", 1 codes deleted, 2 restored"},
+		{append([]string{"rollback", "-yes"}, append(dbFlags, synced)...), EXIT_OK, "0 codes from import " + synced + " to delete, 0 to restore"},
+		{append([]string{"diff"}, append(dbFlags, input)...), EXIT_OK, "0 added, 0 changed, 0 removed\n"},
+		{append([]string{"imports"}, dbFlags...), EXIT_OK, "input.tsv\t" + verify.Digest([]byte(TEST_FILE)) + "\t\t3 codes\n"},
+		{append([]string{"rollback", "-yes"}, append(dbFlags, imported)...), EXIT_OK, "- AKBKMTMTXXX\n- BIGBPLPWCUS\n- BIGBPLPWXXX\n3 codes from import " + imported},
+		{append([]string{"rollback", "-yes"}, append(dbFlags, imported)...), EXIT_OK, "0 codes from import " + imported},
+	}
+	for i := 0; i < len(tt); i++ {
+		code, out := runCommand(t, tt[i].args...)
+		if code != tt[i].wantCode || !strings.Contains(out, tt[i].wantOut) {
+			t.Errorf("run(%v) = %v, %q, want %v, %q", tt[i].args, code, out, tt[i].wantCode, tt[i].wantOut)
+		}
+	}
+	if code, out := runCommand(t, append([]string{"keys"}, dbFlags...)...); code != EXIT_OK || out != "" {
+		t.Errorf("keys after rollback = %v, %q, want none", code, out)
+	}
+}
+
+func TestClearCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	t.Setenv("SC_CACHE_REDIS", "redis://"+mr.Addr())
+	dir := t.TempDir()
+	dbFlags := []string{"-db-driver", "sqlite", "-db-name", filepath.Join(dir, "admin.db")}
+	input := filepath.Join(dir, "input.tsv")
+	if err := os.WriteFile(input, []byte(TEST_FILE), 0o600); err != nil {
+		t.Fatalf("error writing input file: %v", err)
+	}
+	changed := filepath.Join(dir, "changed.tsv")
+	if err := os.WriteFile(changed, []byte(strings.Replace(TEST_FILE, "PORTOMASO BUSINESS TOWER", "PORTOMASO", 1)), 0o600); err != nil {
+		t.Fatalf("error writing changed file: %v", err)
+	}
+	if code, out := runCommand(t, append([]string{"create"}, dbFlags...)...); code != EXIT_OK {
+		t.Fatalf("create = %v, %q", code, out)
+	}
+
+	// A code cached by the API before each command
+	const CACHED = "swiftcodes:cache:code:AKBKMTMTXXX"
+	tt := []struct {
+		args        []string
+		wantCleared bool
+	}{
+		{append([]string{"diff"}, append(dbFlags, input)...), false},
+		{append([]string{"import"}, append(dbFlags, input)...), true},
+		{append([]string{"sync", "-yes"}, append(dbFlags, changed)...), true},
+		{append([]string{"sync", "-yes"}, append(dbFlags, changed)...), false},
+	}
+	for i := 0; i < len(tt); i++ {
+		mr.Set(CACHED, "[]")
+		code, out := runCommand(t, tt[i].args...)
+		if cleared := !mr.Exists(CACHED); code != EXIT_OK && code != EXIT_DIFF || cleared != tt[i].wantCleared {
+			t.Errorf("run(%v) = %v, %q, cleared the shared cache %v, want %v", tt[i].args, code, out, cleared, tt[i].wantCleared)
+		}
+	}
+
+	_, out := runCommand(t, append([]string{"imports"}, dbFlags...)...)
+	id, _, _ := strings.Cut(out, "\t")
+	mr.Set(CACHED, "[]")
+	if code, out := runCommand(t, append([]string{"rollback", "-yes"}, append(dbFlags, id)...)...); code != EXIT_OK || mr.Exists(CACHED) {
+		t.Errorf("rollback %s = %v, %q, want the shared cache cleared", id, code, out)
+	}
+}
+
+func TestSyncConflicts(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "admin.db")
+	dbFlags := []string{"-db-driver", "sqlite", "-db-name", dbPath}
+	input := filepath.Join(dir, "input.tsv")
+	if err := os.WriteFile(input, []byte(TEST_FILE), 0o600); err != nil {
+		t.Fatalf("error writing input file: %v", err)
+	}
+	changed := filepath.Join(dir, "changed.tsv")
+	if err := os.WriteFile(changed, []byte(strings.Replace(TEST_FILE, "PORTOMASO BUSINESS TOWER", "PORTOMASO", 1)), 0o600); err != nil {
+		t.Fatalf("error writing changed file: %v", err)
+	}
+	if code, out := runCommand(t, append([]string{"create"}, dbFlags...)...); code != EXIT_OK {
+		t.Fatalf("create = %v, %q", code, out)
+	}
+	if code, out := runCommand(t, append([]string{"import"}, append(dbFlags, input)...)...); code != EXIT_OK {
+		t.Fatalf("import = %v, %q", code, out)
+	}
+	db, err := store.Open(config.DB{Driver: config.DRIVER_SQLITE, Name: dbPath})
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	defer db.Close()
+	queries := store.New(config.DRIVER_SQLITE, db)
+	if _, err := queries.EditSwiftCode(context.Background(), sqlcout.EditSwiftCodeParams{
+		Address:   sql.NullString{String: "PORTOMASO BUSINESS TOWER, LEVEL 5", Valid: true},
+		Actor:     "ops",
+		SwiftCode: "AKBKMTMTXXX",
+	}); err != nil {
+		t.Fatalf("EditSwiftCode() error: %v", err)
+	}
+
+	conflict := "! AKBKMTMTXXX\taddress\tmanual \"PORTOMASO BUSINESS TOWER, LEVEL 5\"\timport \"PORTOMASO\"\n"
+	tt := []struct {
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{append([]string{"diff"}, append(dbFlags, changed)...), EXIT_DIFF, conflict + "0 added, 1 changed, 0 removed\n1 conflicts"},
+		{append([]string{"sync", "-conflicts", "newest"}, append(dbFlags, changed)...), EXIT_USAGE, ""},
+		{append([]string{"sync", "-conflicts", "fail"}, append(dbFlags, changed)...), EXIT_ERROR, conflict},
+		{append([]string{"sync", "-yes"}, append(dbFlags, changed)...), EXIT_OK, conflict + "0 added, 0 changed, 0 removed\n1 conflicts (!) with values corrected through the API\nconflicts resolved with keep-manual\n"},
+		{append([]string{"sync", "-yes", "-conflicts", "prefer-import"}, append(dbFlags, changed)...), EXIT_OK, conflict + "0 added, 1 changed, 0 removed"},
+		{append([]string{"diff"}, append(dbFlags, changed)...), EXIT_OK, "0 added, 0 changed, 0 removed\n"},
+	}
+	for i := 0; i < len(tt); i++ {
+		code, out := runCommand(t, tt[i].args...)
+		if code != tt[i].wantCode || !strings.Contains(out, tt[i].wantOut) {
+			t.Errorf("run(%v) = %v, %q, want %v, %q", tt[i].args, code, out, tt[i].wantCode, tt[i].wantOut)
+		}
+	}
+	source, err := queries.GetSwiftCodeSource(context.Background(), "AKBKMTMTXXX")
+	if err != nil || source.AddressOverride || source.Actor != "" {
+		t.Errorf(`GetSwiftCodeSource("AKBKMTMTXXX") after prefer-import = %+v, %v, want the override cleared`, source, err)
+	}
+
+	// A file dropping a corrected code conflicts with the correction
+	dropped := filepath.Join(dir, "dropped.tsv")
+	lines := strings.SplitAfter(strings.Replace(TEST_FILE, "PORTOMASO BUSINESS TOWER", "PORTOMASO", 1), "\r\n")
+	if err := os.WriteFile(dropped, []byte(lines[0]+lines[1]+lines[3]), 0o600); err != nil {
+		t.Fatalf("error writing dropped file: %v", err)
+	}
+	if _, err := queries.EditSwiftCode(context.Background(), sqlcout.EditSwiftCodeParams{
+		BankName:  sql.NullString{String: "MILLENNIUM", Valid: true},
+		Actor:     "ops",
+		SwiftCode: "BIGBPLPWCUS",
+	}); err != nil {
+		t.Fatalf("EditSwiftCode() error: %v", err)
+	}
+	removal := "! BIGBPLPWCUS\tbankName\tmanual \"MILLENNIUM\"\tremoved\n"
+	tt = []struct {
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{append([]string{"diff"}, append(dbFlags, dropped)...), EXIT_DIFF, removal + "0 added, 0 changed, 1 removed\n1 conflicts"},
+		{append([]string{"sync", "-conflicts", "fail"}, append(dbFlags, dropped)...), EXIT_ERROR, removal},
+		{append([]string{"sync", "-yes"}, append(dbFlags, dropped)...), EXIT_OK, removal + "0 added, 0 changed, 0 removed\n"},
+		{append([]string{"keys"}, dbFlags...), EXIT_OK, "BIGBPLPWCUS\n"},
+		{append([]string{"sync", "-yes", "-conflicts", "prefer-import"}, append(dbFlags, dropped)...), EXIT_OK, removal + "0 added, 0 changed, 1 removed"},
+		{append([]string{"diff"}, append(dbFlags, dropped)...), EXIT_OK, "0 added, 0 changed, 0 removed\n"},
+	}
+	for i := 0; i < len(tt); i++ {
+		code, out := runCommand(t, tt[i].args...)
+		if code != tt[i].wantCode || !strings.Contains(out, tt[i].wantOut) {
+			t.Errorf("run(%v) = %v, %q, want %v, %q", tt[i].args, code, out, tt[i].wantCode, tt[i].wantOut)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"swiftcodes/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatusClientClosedRequest is reported when the client went away before its queries completed
+const StatusClientClosedRequest = 499
+
+// StoreErrorStatus maps the domain errors returned by the store to HTTP statuses.
+// All handlers go through here, so the same failure always gets the same status.
+func StoreErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrDuplicateCode), errors.Is(err, store.ErrUnknownCountry):
+		return http.StatusConflict
+	case errors.Is(err, store.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, store.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// StoreErrorMessage words the error response of a request about swiftCode or, if it is empty, countryISO2
+func StoreErrorMessage(err error, swiftCode string, countryISO2 string) string {
+	status := StoreErrorStatus(err)
+	message := strconv.Itoa(status) + " "
+	switch {
+	case errors.Is(err, store.ErrNotFound) && swiftCode != "":
+		message += "swift code " + swiftCode + " not found"
+	case errors.Is(err, store.ErrNotFound):
+		message += "country with ISO2 code " + countryISO2 + " not found"
+	case errors.Is(err, store.ErrDuplicateCode):
+		message += "swift code " + swiftCode + " already exists"
+	case errors.Is(err, store.ErrUnknownCountry):
+		message += "no country with ISO2 code " + countryISO2
+	case errors.Is(err, store.ErrUnavailable):
+		message += "database unavailable, try again later"
+	case errors.Is(err, store.ErrTimeout):
+		message += "database query timed out, try again later"
+	case errors.Is(err, context.Canceled):
+		message += "client closed request"
+	default:
+		message += "internal server error"
+	}
+	return message
+}
+
+// AbortWithStoreError responds to a failed query, logging errors that are not the client's fault
+func AbortWithStoreError(c *gin.Context, query string, err error, swiftCode string, countryISO2 string) {
+	status := StoreErrorStatus(err)
+	if status >= http.StatusInternalServerError {
+		log.Print("Failed in query "+query+": ", err)
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": StoreErrorMessage(err, swiftCode, countryISO2)})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"swiftcodes/internal/store"
+	"testing"
+)
+
+func TestStoreErrorResponse(t *testing.T) {
+	tt := []struct {
+		err         error
+		swiftcode   string
+		countryISO2 string
+		wantCode    int
+		wantMessage string
+	}{
+		{store.ErrNotFound, "AAAAPLPWXXX", "", http.StatusNotFound, "404 swift code AAAAPLPWXXX not found"},
+		{store.ErrNotFound, "", "WT", http.StatusNotFound, "404 country with ISO2 code WT not found"},
+		{fmt.Errorf("%w: driver error", store.ErrDuplicateCode), "AAAAPLPWXXX", "PL", http.StatusConflict, "409 swift code AAAAPLPWXXX already exists"},
+		{store.ErrUnknownCountry, "AAAAWTWTXXX", "WT", http.StatusConflict, "409 no country with ISO2 code WT"},
+		{store.ErrUnavailable, "AAAAPLPWXXX", "", http.StatusServiceUnavailable, "503 database unavailable, try again later"},
+		{fmt.Errorf("%w: %w", store.ErrTimeout, context.DeadlineExceeded), "AAAAPLPWXXX", "", http.StatusGatewayTimeout, "504 database query timed out, try again later"},
+		{context.Canceled, "AAAAPLPWXXX", "", StatusClientClosedRequest, "499 client closed request"},
+		{errors.New("syntax error"), "AAAAPLPWXXX", "", http.StatusInternalServerError, "500 internal server error"},
+	}
+	for i := 0; i < len(tt); i++ {
+		code := StoreErrorStatus(tt[i].err)
+		if code != tt[i].wantCode {
+			t.Errorf(`StoreErrorStatus("%v") = %d, want %d`, tt[i].err, code, tt[i].wantCode)
+		}
+		message := StoreErrorMessage(tt[i].err, tt[i].swiftcode, tt[i].countryISO2)
+		if message != tt[i].wantMessage {
+			t.Errorf(`StoreErrorMessage("%v", "%s", "%s") = %s, want %s`, tt[i].err, tt[i].swiftcode, tt[i].countryISO2, message, tt[i].wantMessage)
+		}
+	}
+}
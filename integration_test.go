@@ -1,15 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"swiftcodes/internal/config"
+	"swiftcodes/internal/importjob"
 	"swiftcodes/internal/initdb"
+	"swiftcodes/internal/jobs"
+	"swiftcodes/internal/migrate"
+	"swiftcodes/internal/seed"
+	"swiftcodes/internal/store"
+	"swiftcodes/internal/verify"
+	"swiftcodes/sqlcout"
+
+	"github.com/xuri/excelize/v2"
 )
 
 const TEST_DB_NAME = "test"
@@ -25,10 +45,21 @@ func JSONEqual(a, b string) (bool, error) {
 	return reflect.DeepEqual(aInterface, bInterface), nil
 }
 
+// LoadTestConfig loads the configuration from the environment, pointed at the test database
+func LoadTestConfig(t *testing.T) config.Config {
+	t.Setenv("SC_DB_NAME", TEST_DB_NAME)
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("LoadTestConfig() invalid configuration: %v", err)
+	}
+	return cfg
+}
+
 func TestGetCodeDetailsHandler(t *testing.T) {
-	db := initdb.SetupDB(TEST_DB_NAME, true)
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
 	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
-	router, err := SetupRouter(DB_CONN_BASE, TEST_DB_NAME)
+	router, err := SetupRouter(cfg)
 	if err != nil {
 		t.Errorf("TestGetCodeDetailsHandler() DB connection error: %v", err)
 	}
@@ -88,9 +119,10 @@ func TestGetCodeDetailsHandler(t *testing.T) {
 }
 
 func TestGetCodeDetailsByCountryCodeHandler(t *testing.T) {
-	db := initdb.SetupDB(TEST_DB_NAME, true)
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
 	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
-	router, err := SetupRouter(DB_CONN_BASE, TEST_DB_NAME)
+	router, err := SetupRouter(cfg)
 	if err != nil {
 		t.Errorf("TestGetCodeDetailsByCountryCodeHandler() DB connection error: %v", err)
 	}
@@ -137,9 +169,10 @@ func TestGetCodeDetailsByCountryCodeHandler(t *testing.T) {
 }
 
 func TestPostSwiftCodeHandler(t *testing.T) {
-	db := initdb.SetupDB(TEST_DB_NAME, true)
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
 	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
-	router, err := SetupRouter(DB_CONN_BASE, TEST_DB_NAME)
+	router, err := SetupRouter(cfg)
 	if err != nil {
 		t.Errorf("TestGetCodeDetailsByCountryCodeHandler() DB connection error: %v", err)
 	}
@@ -234,9 +267,10 @@ func TestPostSwiftCodeHandler(t *testing.T) {
 }
 
 func TestDeleteSwiftCodeHandler(t *testing.T) {
-	db := initdb.SetupDB(TEST_DB_NAME, true)
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
 	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
-	router, err := SetupRouter(DB_CONN_BASE, TEST_DB_NAME)
+	router, err := SetupRouter(cfg)
 	if err != nil {
 		t.Errorf("TestDeleteSwiftCodeHandler() DB connection error: %v", err)
 	}
@@ -275,3 +309,553 @@ func TestDeleteSwiftCodeHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryTimeoutHandler(t *testing.T) {
+	t.Setenv("SC_API_QUERY_TIMEOUT_GET_CODE", "1ns")
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
+	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
+	router, err := SetupRouter(cfg)
+	if err != nil {
+		t.Errorf("TestQueryTimeoutHandler() DB connection error: %v", err)
+	}
+
+	tt := []struct {
+		method       string
+		url          string
+		reader       io.Reader
+		wantCode     int
+		wantResponse string
+	}{
+		{
+			http.MethodGet,
+			"/v1/swift-codes/BIGBPLPWXXX",
+			nil,
+			http.StatusGatewayTimeout,
+			`{"error":"504 database query timed out, try again later"}`,
+		},
+		{
+			http.MethodDelete,
+			"/v1/swift-codes/TEST",
+			nil,
+			http.StatusNotFound,
+			`{"error":"404 swift code TEST not found"}`,
+		},
+	}
+
+	for i := 0; i < len(tt); i++ {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(tt[i].method, tt[i].url, tt[i].reader)
+		if err != nil {
+			t.Errorf("TestQueryTimeoutHandler() error handling request: %v", err)
+		}
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt[i].wantCode {
+			t.Errorf("TestQueryTimeoutHandler() test index %v. response code %v, want %v",
+				i, w.Code, tt[i].wantCode)
+		}
+		responseCorrect, err := JSONEqual(tt[i].wantResponse, w.Body.String())
+		if err != nil || !responseCorrect {
+			t.Errorf("TestQueryTimeoutHandler() test index %v. response %v, want %v",
+				i, w.Body.String(), tt[i].wantResponse)
+		}
+	}
+}
+
+func TestSetupRouterSchemaVersion(t *testing.T) {
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
+	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
+	defer db.Close()
+
+	if _, err := SetupRouter(cfg); err != nil {
+		t.Fatalf("SetupRouter() on migrated schema error: %v", err)
+	}
+	if _, err := migrate.Down(context.Background(), db, cfg.DB.Driver, 1); err != nil {
+		t.Fatalf("migrate.Down() error: %v", err)
+	}
+	if _, err := SetupRouter(cfg); !errors.Is(err, migrate.ErrSchemaTooOld) {
+		t.Errorf("SetupRouter() on rolled back schema error = %v, want %v", err, migrate.ErrSchemaTooOld)
+	}
+}
+
+func TestGetDatasetHandler(t *testing.T) {
+	cfg := LoadTestConfig(t)
+	cfg.DB.Seed = filepath.Join(t.TempDir(), "seed.csv")
+	content := "SWIFT CODE,NAME,COUNTRY ISO2 CODE,COUNTRY NAME\nDEUTDEFFXXX,DEUTSCHE BANK AG,DE,GERMANY\n"
+	if err := os.WriteFile(cfg.DB.Seed, []byte(content), 0o600); err != nil {
+		t.Fatalf("error writing seed file: %v", err)
+	}
+	db := initdb.SetupDB(cfg.DB, true)
+	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
+	defer db.Close()
+	router, err := SetupRouter(cfg)
+	if err != nil {
+		t.Fatalf("TestGetDatasetHandler() DB connection error: %v", err)
+	}
+
+	embedded, err := json.Marshal(seed.Embedded())
+	if err != nil {
+		t.Fatalf("error encoding dataset: %v", err)
+	}
+	tt := []struct {
+		url          string
+		wantCode     int
+		wantResponse string
+	}{
+		{"/v1/dataset", http.StatusOK, string(embedded)},
+		{"/v1/swift-codes/DEUTDEFFXXX", http.StatusOK, `{"address":"","bankName":"DEUTSCHE BANK AG","countryISO2":"DE","countryName":"GERMANY","isHeadquarter":true,"swiftCode":"DEUTDEFFXXX"}`},
+		{"/v1/swift-codes/BIGBPLPWXXX", http.StatusNotFound, `{"error":"404 swift code BIGBPLPWXXX not found"}`},
+	}
+	for i := 0; i < len(tt); i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tt[i].url, nil)
+		router.ServeHTTP(w, req)
+		if equal, err := JSONEqual(tt[i].wantResponse, w.Body.String()); w.Code != tt[i].wantCode || err != nil || !equal {
+			t.Errorf("GET %s = %v %v, want %v %v", tt[i].url, w.Code, w.Body.String(), tt[i].wantCode, tt[i].wantResponse)
+		}
+	}
+}
+
+func TestGetCodeDetailsProvenance(t *testing.T) {
+	cfg := LoadTestConfig(t)
+	cfg.DB.Seed = filepath.Join(t.TempDir(), "seed.csv")
+	content := "SWIFT CODE,NAME,COUNTRY ISO2 CODE,COUNTRY NAME\nDEUTDEFFXXX,DEUTSCHE BANK AG,DE,GERMANY\n"
+	if err := os.WriteFile(cfg.DB.Seed, []byte(content), 0o600); err != nil {
+		t.Fatalf("error writing seed file: %v", err)
+	}
+	db := initdb.SetupDB(cfg.DB, true)
+	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
+	defer db.Close()
+	router, err := SetupRouter(cfg)
+	if err != nil {
+		t.Fatalf("TestGetCodeDetailsProvenance() DB connection error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/swift-codes", strings.NewReader(`{"address":"","bankName":"DEUTSCHE BANK AG","countryISO2":"DE","countryName":"GERMANY","isHeadquarter":false,"swiftCode":"DEUTDEFF500"}`))
+	req.Header.Set(ACTOR_HEADER, "alice")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /v1/swift-codes = %v %v, want %v", w.Code, w.Body.String(), http.StatusCreated)
+	}
+
+	tt := []struct {
+		url      string
+		wantCode int
+		want     *ProvenanceResponse
+	}{
+		{"/v1/swift-codes/DEUTDEFFXXX", http.StatusOK, nil},
+		{"/v1/swift-codes/DEUTDEFFXXX?include=provenance", http.StatusOK, &ProvenanceResponse{Source: SOURCE_IMPORT, FileName: "seed.csv", Sha256: verify.Digest([]byte(content))}},
+		{"/v1/swift-codes/DEUTDEFF500?include=provenance", http.StatusOK, &ProvenanceResponse{Source: SOURCE_API, Actor: "alice"}},
+		{"/v1/swift-codes/DEUTDEFF500?include=history", http.StatusBadRequest, nil},
+	}
+	for i := 0; i < len(tt); i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt[i].url, nil))
+		var response DetailsMainResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if response.Provenance != nil && response.Provenance.Source == SOURCE_IMPORT {
+			if response.Provenance.ImportID == "" || response.Provenance.ImportedAt == "" {
+				t.Errorf("GET %s provenance = %+v, want the import ID and time", tt[i].url, response.Provenance)
+			}
+			response.Provenance.ImportID, response.Provenance.ImportedAt = "", ""
+		}
+		if w.Code != tt[i].wantCode || !reflect.DeepEqual(response.Provenance, tt[i].want) {
+			t.Errorf("GET %s = %v %v, want %v with provenance %+v", tt[i].url, w.Code, w.Body.String(), tt[i].wantCode, tt[i].want)
+		}
+	}
+}
+
+func TestPatchSwiftCodeHandler(t *testing.T) {
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
+	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
+	defer db.Close()
+	router, err := SetupRouter(cfg)
+	if err != nil {
+		t.Fatalf("TestPatchSwiftCodeHandler() DB connection error: %v", err)
+	}
+
+	tt := []struct {
+		url      string
+		payload  string
+		wantCode int
+	}{
+		{"/v1/swift-codes/BIGBPLPWXXX", `{"address":"UL. STANISLAWA ZARYNA 2A, 02-593 WARSZAWA"}`, http.StatusOK},
+		{"/v1/swift-codes/BIGBPLPWXXX", `{"address":"UL. STANISLAWA ZARYNA 2A, 02-593 WARSZAWA"}`, http.StatusOK},
+		{"/v1/swift-codes/ABC", `{"address":"A"}`, http.StatusNotFound},
+		{"/v1/swift-codes/BIGBPLPWXXX", `{}`, http.StatusBadRequest},
+		{"/v1/swift-codes/BIGBPLPWXXX", `{"bankName":""}`, http.StatusBadRequest},
+		{"/v1/swift-codes/BIGBPLPWXXX", `{"address":1}`, http.StatusBadRequest},
+	}
+	for i := 0; i < len(tt); i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, tt[i].url, strings.NewReader(tt[i].payload))
+		req.Header.Set(ACTOR_HEADER, "ops")
+		router.ServeHTTP(w, req)
+		if w.Code != tt[i].wantCode {
+			t.Errorf("PATCH %s %s = %v %v, want %v", tt[i].url, tt[i].payload, w.Code, w.Body.String(), tt[i].wantCode)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/swift-codes/BIGBPLPWXXX?include=provenance", nil))
+	var response DetailsMainResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Provenance == nil {
+		t.Fatalf("GET after PATCH = %v %v, want the details with provenance", w.Code, w.Body.String())
+	}
+	if response.Address != "UL. STANISLAWA ZARYNA 2A, 02-593 WARSZAWA" || response.BankName != "BANK MILLENNIUM S.A." {
+		t.Errorf("GET after PATCH = %+v, want the corrected address and the imported bank name", response)
+	}
+	provenance := response.Provenance
+	if provenance.Source != SOURCE_IMPORT || provenance.Actor != "ops" || !reflect.DeepEqual(provenance.Overrides, []string{initdb.FIELD_ADDRESS}) {
+		t.Errorf("provenance after PATCH = %+v, want the import with the address corrected by ops", provenance)
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
+	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
+	defer db.Close()
+	router, err := SetupRouter(cfg)
+	if err != nil {
+		t.Fatalf("TestExportHandler() DB connection error: %v", err)
+	}
+	stored, err := queries.ListSwiftCodes(context.Background())
+	if err != nil {
+		t.Fatalf("ListSwiftCodes() error: %v", err)
+	}
+	var storedMT int
+	for _, code := range stored {
+		if code.CountryISO2 == "MT" {
+			storedMT++
+		}
+	}
+
+	tt := []struct {
+		url             string
+		wantCode        int
+		wantContentType string
+		wantRows        int
+	}{
+		{"/v1/swift-codes/export", http.StatusOK, "text/csv; charset=utf-8", len(stored) + 1},
+		{"/v1/swift-codes/export?format=tsv", http.StatusOK, "text/tab-separated-values; charset=utf-8", len(stored) + 1},
+		{"/v1/swift-codes/export?format=tsv&country=MT", http.StatusOK, "text/tab-separated-values; charset=utf-8", storedMT + 1},
+		{"/v1/swift-codes/export?format=jsonl&country=MT", http.StatusOK, "application/jsonl; charset=utf-8", storedMT},
+		{"/v1/swift-codes/export?format=xml", http.StatusBadRequest, "application/json; charset=utf-8", 0},
+		{"/v1/swift-codes/export?country=XX", http.StatusNotFound, "application/json; charset=utf-8", 0},
+	}
+	for i := 0; i < len(tt); i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt[i].url, nil))
+		rows := strings.Count(w.Body.String(), "\n")
+		if w.Code != tt[i].wantCode || w.Header().Get("Content-Type") != tt[i].wantContentType || (w.Code == http.StatusOK && rows != tt[i].wantRows) {
+			t.Errorf("GET %s = %v %q with %d rows, want %v %q with %d rows", tt[i].url, w.Code, w.Header().Get("Content-Type"), rows, tt[i].wantCode, tt[i].wantContentType, tt[i].wantRows)
+		}
+	}
+
+	// The TSV export is read back as the stored codes
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/swift-codes/export?format=tsv", nil))
+	exported := filepath.Join(t.TempDir(), "export.tsv")
+	if err := os.WriteFile(exported, w.Body.Bytes(), 0o600); err != nil {
+		t.Fatalf("error writing export: %v", err)
+	}
+	rows, err := initdb.ReadCSV(exported)
+	if err != nil {
+		t.Fatalf("ReadCSV() error: %v", err)
+	}
+	codes, _, err := initdb.ParseData(rows)
+	if err != nil {
+		t.Fatalf("ParseData() error: %v", err)
+	}
+	if diff := initdb.DiffCodes(stored, codes); !diff.Empty() {
+		t.Errorf("export differs from the stored codes: %d added, %d changed, %d removed", len(diff.Added), len(diff.Changed), len(diff.Removed))
+	}
+
+	// The XLSX export holds the same rows in its first sheet
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/swift-codes/export?format=xlsx&country=MT", nil))
+	workbook, err := excelize.OpenReader(w.Body)
+	if err != nil {
+		t.Fatalf("GET xlsx export = %v, not a workbook: %v", w.Code, err)
+	}
+	defer workbook.Close()
+	sheet, err := workbook.GetRows(workbook.GetSheetName(0))
+	if err != nil || len(sheet) != storedMT+1 || !reflect.DeepEqual(sheet[0], initdb.HEADER) {
+		t.Errorf("xlsx export = %d rows, %v, want %d rows under the import header", len(sheet), err, storedMT+1)
+	}
+}
+
+// failingStore fails EachSwiftCode after the first code
+type failingStore struct {
+	store.Store
+}
+
+func (s failingStore) EachSwiftCode(ctx context.Context, countryIso2 string, f func(sqlcout.ListSwiftCodesRow) error) error {
+	return s.Store.EachSwiftCode(ctx, countryIso2, func(code sqlcout.ListSwiftCodesRow) error {
+		if err := f(code); err != nil {
+			return err
+		}
+		return store.ErrUnavailable
+	})
+}
+
+// discardCounter counts the writers of exports that were discarded
+type discardCounter struct {
+	initdb.ExportWriter
+	discarded *atomic.Int64
+}
+
+func (w discardCounter) Discard() {
+	w.discarded.Add(1)
+	w.ExportWriter.Discard()
+}
+
+func TestExportFailure(t *testing.T) {
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
+	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
+	defer db.Close()
+	router, err := SetupRouter(cfg)
+	if err != nil {
+		t.Fatalf("TestExportFailure() DB connection error: %v", err)
+	}
+	queries = failingStore{queries}
+	var created, discarded atomic.Int64
+	newExportWriter = func(w io.Writer, format string) (initdb.ExportWriter, error) {
+		writer, err := initdb.NewExportWriter(w, format)
+		if err == nil {
+			created.Add(1)
+			writer = discardCounter{writer, &discarded}
+		}
+		return writer, err
+	}
+	defer func() { newExportWriter = initdb.NewExportWriter }()
+
+	for _, format := range initdb.EXPORT_FORMATS {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/swift-codes/export?format="+format, nil))
+		// The XLSX workbook is only sent once complete, so its failure still gets a status
+		if format == initdb.EXPORT_XLSX && w.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET xlsx export failing mid-way = %v, want %v", w.Code, http.StatusServiceUnavailable)
+		}
+	}
+	if created.Load() != int64(len(initdb.EXPORT_FORMATS)) || discarded.Load() != created.Load() {
+		t.Errorf("export writers discarded = %d of %d, want all", discarded.Load(), created.Load())
+	}
+}
+
+func TestExportOutlivesWriteTimeout(t *testing.T) {
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
+	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
+	defer db.Close()
+	router, err := SetupRouter(cfg)
+	if err != nil {
+		t.Fatalf("TestExportOutlivesWriteTimeout() DB connection error: %v", err)
+	}
+	stored, err := queries.ListSwiftCodes(context.Background())
+	if err != nil {
+		t.Fatalf("ListSwiftCodes() error: %v", err)
+	}
+	// Requests take longer than the write timeout of the server before reaching the router
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		router.ServeHTTP(w, r)
+	}))
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/swift-codes/export")
+	if err != nil {
+		t.Fatalf("GET slow export error: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if rows := strings.Count(string(body), "\n"); err != nil || resp.StatusCode != http.StatusOK || rows != len(stored)+1 {
+		t.Errorf("GET slow export = %v with %d rows, %v, want %v with %d rows", resp.StatusCode, rows, err, http.StatusOK, len(stored)+1)
+	}
+	if resp, err := http.Get(server.URL + "/v1/swift-codes/BIGBPLPWXXX"); err == nil {
+		resp.Body.Close()
+		t.Errorf("GET slow code = %v, want the response cut off by the write timeout", resp.StatusCode)
+	}
+}
+
+// uploadRequest returns a multipart request uploading content as fileName to the admin API with fields
+func uploadRequest(t *testing.T, token string, fileName string, content string, fields map[string]string) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	if fileName != "" {
+		f, err := form.CreateFormFile(FORM_FILE, fileName)
+		if err != nil {
+			t.Fatalf("error writing form: %v", err)
+		}
+		io.WriteString(f, content)
+	}
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, IMPORTS_URI, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestImportHandlers(t *testing.T) {
+	t.Setenv("SC_API_ADMIN_TOKEN", "secret")
+	cfg := LoadTestConfig(t)
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	cfg.Import.TrustedKeys = filepath.Join(t.TempDir(), "trusted_keys")
+	if err := os.WriteFile(cfg.Import.TrustedKeys, []byte("vendor "+base64.StdEncoding.EncodeToString(public)+"\n"), 0o600); err != nil {
+		t.Fatalf("error writing trusted keys: %v", err)
+	}
+	db := initdb.SetupDB(cfg.DB, true)
+	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
+	defer db.Close()
+	cfg.Jobs.Dir = t.TempDir()
+	router, err := SetupRouter(cfg)
+	if err != nil {
+		t.Fatalf("TestImportHandlers() DB connection error: %v", err)
+	}
+	runner.Start()
+	defer runner.Close()
+
+	content := "SWIFT CODE\tNAME\tCOUNTRY ISO2 CODE\tCOUNTRY NAME\n" +
+		"AAAAQQ22XXX\tBANK A\tQQ\tQUEENSLAND\n" +
+		"AAAAQQ2\tBANK A\tQQ\tQUEENSLAND\n" +
+		"BBBBQQ22XXX\tBANK B\tQQ\tQUEENSLAND\n"
+	signature := string(verify.Sign(private, []byte(content)))
+
+	tt := []struct {
+		req      *http.Request
+		wantCode int
+		wantBody string
+	}{
+		{uploadRequest(t, "wrong", "codes.tsv", content, map[string]string{FORM_SIGNATURE: signature}), http.StatusUnauthorized, "401 unauthorized"},
+		{uploadRequest(t, "secret", "", "", nil), http.StatusBadRequest, "400 missing file"},
+		{uploadRequest(t, "secret", "codes.tsv", content, map[string]string{FORM_SIGNATURE: signature, FORM_MAX_ERRORS: "few"}), http.StatusBadRequest, "400 invalid maxErrors"},
+		{uploadRequest(t, "secret", "codes.tsv", content, nil), http.StatusForbidden, "403 no signature"},
+		{uploadRequest(t, "secret", "codes.tsv", content, map[string]string{FORM_SIGNATURE: "c2lnbmF0dXJl"}), http.StatusBadRequest, "400 signature isn't a base64 ed25519 signature"},
+		{uploadRequest(t, "secret", "codes.tsv", content+"\n", map[string]string{FORM_SIGNATURE: signature}), http.StatusForbidden, "403 signature doesn't verify"},
+		{uploadRequest(t, "secret", "codes.xml", content, map[string]string{FORM_SIGNATURE: string(verify.Sign(private, []byte(content)))}), http.StatusBadRequest, "400 unsupported file"},
+		{uploadRequest(t, "secret", "codes.tsv", content, map[string]string{FORM_SIGNATURE: signature, FORM_MAX_ERRORS: "1"}), http.StatusAccepted, `"state":"queued"`},
+	}
+	var location string
+	for i := 0; i < len(tt); i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tt[i].req)
+		if w.Code != tt[i].wantCode || !strings.Contains(w.Body.String(), tt[i].wantBody) {
+			t.Errorf("upload %d = %v %v, want %v %v", i, w.Code, w.Body.String(), tt[i].wantCode, tt[i].wantBody)
+		}
+		location = w.Header().Get("Location")
+	}
+
+	// The job is polled until the import finished
+	status := importjob.Status{State: jobs.STATE_QUEUED}
+	for deadline := time.Now().Add(5 * time.Second); !status.Finished() && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, location, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		router.ServeHTTP(w, req)
+		if err := json.Unmarshal(w.Body.Bytes(), &status); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("GET %s = %v %v, want the job status", location, w.Code, w.Body.String())
+		}
+	}
+	if status.State != jobs.STATE_SUCCEEDED || status.Signer != "vendor" || status.Imported != 2 || status.Failed != 1 || len(status.Errors) != 1 {
+		t.Errorf("status = %+v, want 2 codes imported from the file signed by vendor and 1 failed row", status)
+	}
+	if _, err := queries.GetCodeDetails(context.Background(), sqlcout.GetCodeDetailsParams{SwiftCode: "BBBBQQ22XXX"}); err != nil {
+		t.Errorf("GetCodeDetails() of an uploaded code error: %v", err)
+	}
+
+	tt = []struct {
+		req      *http.Request
+		wantCode int
+		wantBody string
+	}{
+		{httptest.NewRequest(http.MethodGet, IMPORTS_URI+"/unknown", nil), http.StatusNotFound, "404 import job unknown not found"},
+		{httptest.NewRequest(http.MethodDelete, IMPORTS_URI+"/unknown", nil), http.StatusNotFound, "404 import job unknown not found"},
+		{httptest.NewRequest(http.MethodDelete, location, nil), http.StatusConflict, "409 import job " + status.ID + " already succeeded"},
+		{httptest.NewRequest(http.MethodGet, JOBS_URI, nil), http.StatusOK, `"kind":"import"`},
+		{httptest.NewRequest(http.MethodGet, JOBS_URI+"?limit=0", nil), http.StatusBadRequest, "400 invalid limit"},
+		{httptest.NewRequest(http.MethodGet, JOBS_URI+"/"+status.ID, nil), http.StatusOK, `"state":"succeeded"`},
+		{httptest.NewRequest(http.MethodGet, JOBS_URI+"/unknown", nil), http.StatusNotFound, "404 job unknown not found"},
+	}
+	for i := 0; i < len(tt); i++ {
+		w := httptest.NewRecorder()
+		tt[i].req.Header.Set("Authorization", "Bearer secret")
+		router.ServeHTTP(w, tt[i].req)
+		if w.Code != tt[i].wantCode || !strings.Contains(w.Body.String(), tt[i].wantBody) {
+			t.Errorf("%s %s = %v %v, want %v %v", tt[i].req.Method, tt[i].req.URL, w.Code, w.Body.String(), tt[i].wantCode, tt[i].wantBody)
+		}
+	}
+}
+
+func TestCachedLookups(t *testing.T) {
+	cfg := LoadTestConfig(t)
+	db := initdb.SetupDB(cfg.DB, true)
+	defer db.Exec("DROP DATABASE IF EXISTS " + TEST_DB_NAME)
+	defer db.Close()
+	router, err := SetupRouter(cfg)
+	if err != nil {
+		t.Fatalf("TestCachedLookups() DB connection error: %v", err)
+	}
+	branch, _ := json.Marshal(DetailsInputPayload{
+		Address:     "HARMONY CENTER",
+		BankName:    "BANK MILLENNIUM S.A.",
+		CountryISO2: "PL",
+		CountryName: "POLAND",
+		SwiftCode:   "BIGBPLPWAAA",
+	})
+
+	// Every write shows in the lookups that follow, even though they are cached
+	tt := []struct {
+		method   string
+		url      string
+		payload  string
+		wantBody string
+		wantHas  bool
+	}{
+		{http.MethodGet, "/v1/swift-codes/BIGBPLPWXXX", "", "BIGBPLPWAAA", false},
+		{http.MethodGet, "/v1/swift-codes/country/PL", "", "BIGBPLPWAAA", false},
+		{http.MethodPost, "/v1/swift-codes", string(branch), "created", true},
+		{http.MethodGet, "/v1/swift-codes/BIGBPLPWXXX", "", "BIGBPLPWAAA", true},
+		{http.MethodGet, "/v1/swift-codes/country/PL", "", "BIGBPLPWAAA", true},
+		{http.MethodPatch, "/v1/swift-codes/BIGBPLPWAAA", `{"address":"ZARYNA 2A"}`, "updated", true},
+		{http.MethodGet, "/v1/swift-codes/BIGBPLPWXXX", "", "ZARYNA 2A", true},
+		{http.MethodGet, "/v1/swift-codes/country/PL", "", "ZARYNA 2A", true},
+		{http.MethodDelete, "/v1/swift-codes/BIGBPLPWAAA", "", "deleted", true},
+		{http.MethodGet, "/v1/swift-codes/BIGBPLPWXXX", "", "BIGBPLPWAAA", false},
+		{http.MethodGet, "/v1/swift-codes/country/PL", "", "BIGBPLPWAAA", false},
+		{http.MethodGet, "/v1/swift-codes/country/PL", "", "BIGBPLPWXXX", true},
+	}
+	for i := 0; i < len(tt); i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt[i].method, tt[i].url, strings.NewReader(tt[i].payload)))
+		if strings.Contains(w.Body.String(), tt[i].wantBody) != tt[i].wantHas {
+			t.Errorf("%d: %s %s = %v %v, want it to contain %s: %v", i, tt[i].method, tt[i].url, w.Code, w.Body.String(), tt[i].wantBody, tt[i].wantHas)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, METRICS_URI, nil))
+	for _, want := range []string{
+		`swiftcodes_cache_hits_total{cache="country"} 4`,
+		`swiftcodes_cache_misses_total{cache="code"} 4`,
+		`swiftcodes_cache_hits_total{cache="country_codes"} 1`,
+		"# TYPE swiftcodes_cache_entries gauge",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("GET %s = %v %v, want %s", METRICS_URI, w.Code, w.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package bic validates Business Identifier Codes (SWIFT codes) as defined by ISO 9362
+package bic
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrLength       = errors.New("must be 8 or 11 characters long")
+	ErrBankCode     = errors.New("bank code (characters 1-4) must be letters")
+	ErrCountryCode  = errors.New("country code (characters 5-6) must be letters")
+	ErrLocationCode = errors.New("location code (characters 7-8) must be letters or digits")
+	ErrBranchCode   = errors.New("branch code (characters 9-11) must be letters or digits")
+)
+
+// HEADQUARTER_BRANCH is the branch code of a bank's headquarters, or primary office
+const HEADQUARTER_BRANCH = "XXX"
+
+// Validate checks that code is a well-formed BIC8 or BIC11 in upper case
+func Validate(code string) error {
+	var err error
+	switch {
+	case len(code) != 8 && len(code) != 11:
+		err = ErrLength
+	case !all(code[0:4], isLetter):
+		err = ErrBankCode
+	case !all(code[4:6], isLetter):
+		err = ErrCountryCode
+	case !all(code[6:8], isAlphanumeric):
+		err = ErrLocationCode
+	case len(code) == 11 && !all(code[8:11], isAlphanumeric):
+		err = ErrBranchCode
+	}
+	if err != nil {
+		return fmt.Errorf("invalid BIC %q: %w", code, err)
+	}
+	return nil
+}
+
+// Country returns the ISO 3166 country code embedded in a valid BIC
+func Country(code string) string {
+	return code[4:6]
+}
+
+// Bank8 returns the first 8 characters of a valid BIC, which identify the institution at a location
+func Bank8(code string) string {
+	return code[0:8]
+}
+
+// IsHeadquarter reports whether a valid BIC is a BIC8 or has the headquarters branch code
+func IsHeadquarter(code string) bool {
+	return len(code) == 8 || code[8:11] == HEADQUARTER_BRANCH
+}
+
+func all(s string, valid func(c byte) bool) bool {
+	for i := 0; i < len(s); i++ {
+		if !valid(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+func isAlphanumeric(c byte) bool {
+	return isLetter(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,55 @@
+package bic
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		code string
+		want error
+	}{
+		{"BIGBPLPWXXX", nil},
+		{"BIGBPLPW", nil},
+		{"ALBPPLP1BMW", nil},
+		{"AIZKLV22XXX", nil},
+		{"", ErrLength},
+		{"BIGBPLPWXX", ErrLength},
+		{"BIGBPLPWXXXX", ErrLength},
+		{"B1GBPLPWXXX", ErrBankCode},
+		{"bigbplpwxxx", ErrBankCode},
+		{"BIGBP1PWXXX", ErrCountryCode},
+		{"BIGBPLP-XXX", ErrLocationCode},
+		{"BIGBPLPWXX ", ErrBranchCode},
+	}
+	for i := 0; i < len(tt); i++ {
+		if got := Validate(tt[i].code); !errors.Is(got, tt[i].want) {
+			t.Errorf(`Validate("%v") = %v, want %v`, tt[i].code, got, tt[i].want)
+		}
+	}
+}
+
+func TestParts(t *testing.T) {
+	tt := []struct {
+		code          string
+		country       string
+		bank8         string
+		isHeadquarter bool
+	}{
+		{"BIGBPLPWXXX", "PL", "BIGBPLPW", true},
+		{"BIGBPLPWCUS", "PL", "BIGBPLPW", false},
+		{"AKBKMTMT", "MT", "AKBKMTMT", true},
+	}
+	for i := 0; i < len(tt); i++ {
+		if got := Country(tt[i].code); got != tt[i].country {
+			t.Errorf(`Country("%v") = %v, want %v`, tt[i].code, got, tt[i].country)
+		}
+		if got := Bank8(tt[i].code); got != tt[i].bank8 {
+			t.Errorf(`Bank8("%v") = %v, want %v`, tt[i].code, got, tt[i].bank8)
+		}
+		if got := IsHeadquarter(tt[i].code); got != tt[i].isHeadquarter {
+			t.Errorf(`IsHeadquarter("%v") = %v, want %v`, tt[i].code, got, tt[i].isHeadquarter)
+		}
+	}
+}
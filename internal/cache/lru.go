@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Stats counts the lookups of a cache since it was created
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// LRU is a size bounded cache whose entries expire TTL after they were set, the least recently used
+// entry is evicted when it is full. Entries carry tags, e.g. the bank and country of a code, so that a
+// write can drop every entry it affects with Invalidate.
+type LRU[V any] struct {
+	mu   sync.Mutex
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+	// order holds the entries, the most recently used first
+	order   *list.List
+	entries map[string]*list.Element
+	tags    map[string]map[*list.Element]struct{}
+	// generation grows with every invalidation, values read from the database before one aren't stored
+	generation uint64
+	stats      Stats
+}
+
+type entry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+	tags    []string
+}
+
+func NewLRU[V any](size int, ttl time.Duration) *LRU[V] {
+	return &LRU[V]{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[*list.Element]struct{}),
+	}
+}
+
+// Get returns the value of key unless it is missing or expired
+func (c *LRU[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if ok && c.now().After(element.Value.(*entry[V]).expires) {
+		c.remove(element)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*entry[V]).value, true
+}
+
+// Generation is taken before reading a value from the database and passed to Set with the value
+func (c *LRU[V]) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Set stores the value of key with its tags, unless entries were invalidated since generation was taken,
+// as the value may predate the write that invalidated them
+func (c *LRU[V]) Set(generation uint64, key string, value V, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	element := c.order.PushFront(&entry[V]{key, value, c.now().Add(c.ttl), tags})
+	c.entries[key] = element
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[*list.Element]struct{})
+		}
+		c.tags[tag][element] = struct{}{}
+	}
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Invalidate drops the entries carrying any of tags
+func (c *LRU[V]) Invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for _, tag := range tags {
+		for element := range c.tags[tag] {
+			c.remove(element)
+		}
+	}
+}
+
+// Clear drops every entry
+func (c *LRU[V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.order.Init()
+	clear(c.entries)
+	clear(c.tags)
+}
+
+func (c *LRU[V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+func (c *LRU[V]) remove(element *list.Element) {
+	e := element.Value.(*entry[V])
+	c.order.Remove(element)
+	delete(c.entries, e.key)
+	for _, tag := range e.tags {
+		delete(c.tags[tag], element)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	c := NewLRU[int](2, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Set(c.Generation(), "a", 1, "bank:A")
+	c.Set(c.Generation(), "b", 2, "bank:B", "country:PL")
+	c.Get("a")
+	// b is the least recently used
+	c.Set(c.Generation(), "c", 3, "bank:C", "country:PL")
+	tt := []struct {
+		key    string
+		want   int
+		wantOk bool
+	}{
+		{"a", 1, true},
+		{"b", 0, false},
+		{"c", 3, true},
+	}
+	for i := 0; i < len(tt); i++ {
+		if value, ok := c.Get(tt[i].key); value != tt[i].want || ok != tt[i].wantOk {
+			t.Errorf("Get(%s) = %v, %v, want %v, %v", tt[i].key, value, ok, tt[i].want, tt[i].wantOk)
+		}
+	}
+	if stats := c.Stats(); stats != (Stats{Hits: 3, Misses: 1, Evictions: 1, Entries: 2}) {
+		t.Errorf("Stats() = %+v, want 3 hits, 1 miss, 1 eviction and 2 entries", stats)
+	}
+
+	c.Invalidate("country:PL")
+	if _, ok := c.Get("c"); ok {
+		t.Errorf("Get(c) after invalidating its country found it")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("Get(a) after invalidating another country didn't find it")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Get(a) after its TTL found it")
+	}
+	if stats := c.Stats(); stats.Entries != 0 {
+		t.Errorf("Stats() = %+v, want expired entries dropped", stats)
+	}
+}
+
+func TestLRUGeneration(t *testing.T) {
+	c := NewLRU[int](10, time.Minute)
+	// A value read before a write isn't stored after it
+	generation := c.Generation()
+	c.Invalidate("bank:A")
+	c.Set(generation, "a", 1, "bank:A")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Get(a) found a value read before an invalidation")
+	}
+	c.Set(c.Generation(), "a", 1, "bank:A")
+	c.Clear()
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Get(a) after Clear() found it")
+	}
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ALL is the tag broadcast when every entry is dropped
+const ALL = "*"
+
+// Shared is a cache shared by the API replicas, in front of their own LRU caches. Invalidations are
+// broadcast to every replica, so that they drop their entries too.
+type Shared interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Generation and Set work like those of LRU, across replicas
+	Generation(ctx context.Context) (uint64, error)
+	Set(ctx context.Context, generation uint64, key string, value []byte, tags ...string) error
+	// Invalidate drops the entries carrying any of tags, every entry if tags hold ALL
+	Invalidate(ctx context.Context, tags ...string) error
+	// Subscribe calls f with the tags invalidated by other replicas until the cache is closed
+	Subscribe(ctx context.Context, f func(tags []string)) error
+	Close() error
+}
+
+// Redis is a Shared cache in a server speaking the Redis protocol. Its keys start with a prefix, so that
+// several deployments can share a server.
+type Redis struct {
+	client *redis.Client
+	ttl    time.Duration
+	prefix string
+	// origin tells the invalidations of this replica apart from those of others
+	origin string
+	pubsub *redis.PubSub
+}
+
+// message is published on every invalidation
+type message struct {
+	Origin string   `json:"origin"`
+	Tags   []string `json:"tags"`
+}
+
+// NewRedis connects to the server at url, e.g. redis://localhost:6379/0, whose entries expire after ttl
+func NewRedis(url string, ttl time.Duration, prefix string) (*Redis, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(options)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &Redis{client: client, ttl: ttl, prefix: prefix, origin: newOrigin()}, nil
+}
+
+func (r *Redis) key(key string) string { return r.prefix + "cache:" + key }
+func (r *Redis) tag(tag string) string { return r.prefix + "tag:" + tag }
+func (r *Redis) generation() string    { return r.prefix + "generation" }
+func (r *Redis) channel() string       { return r.prefix + "invalidate" }
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, r.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	return value, err == nil, err
+}
+
+func (r *Redis) Generation(ctx context.Context) (uint64, error) {
+	generation, err := r.client.Get(ctx, r.generation()).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return generation, err
+}
+
+func (r *Redis) Set(ctx context.Context, generation uint64, key string, value []byte, tags ...string) error {
+	// The transaction fails if another replica invalidates entries before it committed
+	err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, r.generation()).Uint64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if current != generation {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, r.key(key), value, r.ttl)
+			for _, tag := range tags {
+				pipe.SAdd(ctx, r.tag(tag), r.key(key))
+				pipe.Expire(ctx, r.tag(tag), r.ttl)
+			}
+			return nil
+		})
+		return err
+	}, r.generation())
+	if errors.Is(err, redis.TxFailedErr) {
+		return nil
+	}
+	return err
+}
+
+func (r *Redis) Invalidate(ctx context.Context, tags ...string) error {
+	if err := r.client.Incr(ctx, r.generation()).Err(); err != nil {
+		return err
+	}
+	var keys []string
+	for _, tag := range tags {
+		if tag == ALL {
+			if err := r.deleteAll(ctx); err != nil {
+				return err
+			}
+			keys = nil
+			break
+		}
+		members, err := r.client.SMembers(ctx, r.tag(tag)).Result()
+		if err != nil {
+			return err
+		}
+		keys = append(append(keys, members...), r.tag(tag))
+	}
+	if len(keys) > 0 {
+		if err := r.client.Del(ctx, keys...).Err(); err != nil {
+			return err
+		}
+	}
+	encoded, err := json.Marshal(message{r.origin, tags})
+	if err != nil {
+		return err
+	}
+	return r.client.Publish(ctx, r.channel(), encoded).Err()
+}
+
+// deleteAll deletes the entries and tags of the prefix
+func (r *Redis) deleteAll(ctx context.Context) error {
+	for _, pattern := range []string{r.key("*"), r.tag("*")} {
+		iter := r.client.Scan(ctx, 0, pattern, 1000).Iterator()
+		var keys []string
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := r.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Subscribe returns once the subscription is active, f is called from a goroutine of its own
+func (r *Redis) Subscribe(ctx context.Context, f func(tags []string)) error {
+	r.pubsub = r.client.Subscribe(ctx, r.channel())
+	if _, err := r.pubsub.Receive(ctx); err != nil {
+		r.pubsub.Close()
+		return err
+	}
+	messages := r.pubsub.Channel()
+	go func() {
+		for m := range messages {
+			var decoded message
+			if err := json.Unmarshal([]byte(m.Payload), &decoded); err != nil {
+				log.Print("Invalid cache invalidation message: ", err)
+				continue
+			}
+			if decoded.Origin != r.origin {
+				f(decoded.Tags)
+			}
+		}
+	}()
+	return nil
+}
+
+func newOrigin() string {
+	origin := make([]byte, 8)
+	rand.Read(origin)
+	return hex.EncodeToString(origin)
+}
+
+func (r *Redis) Close() error {
+	if r.pubsub != nil {
+		r.pubsub.Close()
+	}
+	return r.client.Close()
+}
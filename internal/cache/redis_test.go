@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newRedis(t *testing.T, mr *miniredis.Miniredis) *Redis {
+	r, err := NewRedis("redis://"+mr.Addr(), time.Minute, "test:")
+	if err != nil {
+		t.Fatal("NewRedis() error: ", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	r := newRedis(t, mr)
+
+	generation, err := r.Generation(ctx)
+	if err != nil {
+		t.Fatal("Generation() error: ", err)
+	}
+	r.Set(ctx, generation, "a", []byte("1"), "bank:A")
+	r.Set(ctx, generation, "b", []byte("2"), "bank:B", "country:PL")
+	tt := []struct {
+		key    string
+		want   string
+		wantOk bool
+	}{
+		{"a", "1", true},
+		{"b", "2", true},
+		{"c", "", false},
+	}
+	for i := 0; i < len(tt); i++ {
+		if value, ok, err := r.Get(ctx, tt[i].key); string(value) != tt[i].want || ok != tt[i].wantOk || err != nil {
+			t.Errorf("Get(%s) = %q, %v, %v, want %q, %v", tt[i].key, value, ok, err, tt[i].want, tt[i].wantOk)
+		}
+	}
+	if ttl := mr.TTL("test:cache:a"); ttl != time.Minute {
+		t.Errorf("TTL of a = %v, want 1m", ttl)
+	}
+
+	if err := r.Invalidate(ctx, "country:PL"); err != nil {
+		t.Fatal("Invalidate() error: ", err)
+	}
+	if _, ok, _ := r.Get(ctx, "b"); ok {
+		t.Errorf("Get(b) after invalidating its country found it")
+	}
+	if _, ok, _ := r.Get(ctx, "a"); !ok {
+		t.Errorf("Get(a) after invalidating another country didn't find it")
+	}
+	// A value read before an invalidation isn't stored after it
+	r.Set(ctx, generation, "b", []byte("2"), "bank:B")
+	if _, ok, _ := r.Get(ctx, "b"); ok {
+		t.Errorf("Get(b) found a value read before an invalidation")
+	}
+
+	mr.Set("other:cache:a", "1")
+	if err := r.Invalidate(ctx, ALL); err != nil {
+		t.Fatal("Invalidate(ALL) error: ", err)
+	}
+	if _, ok, _ := r.Get(ctx, "a"); ok {
+		t.Errorf("Get(a) after invalidating every entry found it")
+	}
+	if !mr.Exists("other:cache:a") {
+		t.Errorf("Invalidate(ALL) dropped the keys of another prefix")
+	}
+}
+
+func TestRedisSubscribe(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	replicas := []*Redis{newRedis(t, mr), newRedis(t, mr)}
+	received := make([]chan []string, len(replicas))
+	for i, r := range replicas {
+		received[i] = make(chan []string, 1)
+		if err := r.Subscribe(ctx, func(tags []string) { received[i] <- tags }); err != nil {
+			t.Fatal("Subscribe() error: ", err)
+		}
+	}
+
+	if err := replicas[0].Invalidate(ctx, "bank:AAAAPLPW", "country:PL"); err != nil {
+		t.Fatal("Invalidate() error: ", err)
+	}
+	select {
+	case tags := <-received[1]:
+		if !slices.Equal(tags, []string{"bank:AAAAPLPW", "country:PL"}) {
+			t.Errorf("other replica received %v, want the invalidated tags", tags)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("other replica received no invalidation")
+	}
+	select {
+	case tags := <-received[0]:
+		t.Errorf("replica received its own invalidation of %v", tags)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
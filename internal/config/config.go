@@ -0,0 +1,416 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported database drivers
+const (
+	DRIVER_MYSQL    = "mysql"
+	DRIVER_SQLITE   = "sqlite"
+	DRIVER_POSTGRES = "postgres"
+)
+
+// Route names used to configure per-route query timeouts
+const (
+	ROUTE_GET_CODE    = "GET_CODE"
+	ROUTE_GET_COUNTRY = "GET_COUNTRY"
+	ROUTE_POST_CODE   = "POST_CODE"
+	ROUTE_DELETE_CODE = "DELETE_CODE"
+	ROUTE_PATCH_CODE  = "PATCH_CODE"
+)
+
+var ROUTES = []string{ROUTE_GET_CODE, ROUTE_GET_COUNTRY, ROUTE_POST_CODE, ROUTE_DELETE_CODE, ROUTE_PATCH_CODE}
+
+type Config struct {
+	DB     DB
+	API    API
+	Import Import
+	Jobs   Jobs
+	Cache  Cache
+}
+
+type DB struct {
+	Driver   string
+	User     string
+	Password string
+	Name     string
+	Host     string
+	Port     string
+	// Seed is the file loaded into new databases, the embedded dataset if empty
+	Seed string
+	// Migrations is the directory of the migrations, the embedded ones if empty
+	Migrations string
+}
+
+type Import struct {
+	// TrustedKeys is the file of the public keys that import files must be signed with, unsigned files
+	// are imported if it is empty
+	TrustedKeys string
+}
+
+type Jobs struct {
+	// Workers is the number of background jobs run at the same time
+	Workers int
+	// MaxAttempts is the number of times a failing job is run before it is marked failed
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry of a job, doubled for every further one
+	RetryBackoff time.Duration
+	// Dir holds the files of queued jobs, such as uploaded import files, until they finished
+	Dir string
+}
+
+type Cache struct {
+	// Size is the number of entries of each lookup cache, e.g. codes or countries
+	Size int
+	// TTL bounds how long writes by other processes take to show
+	TTL time.Duration
+	// Redis is the URL of a cache shared by the API replicas, which then see each other's writes at once
+	Redis string
+	// RedisPrefix starts the keys of the shared cache, so that deployments can share a server
+	RedisPrefix string
+}
+
+type API struct {
+	Host              string
+	Port              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	MaxHeaderBytes    int64
+	MaxBodyBytes      int64
+	// MaxUploadBytes limits the request bodies of the admin API, which carry import files
+	MaxUploadBytes int64
+	// AdminToken is the bearer token of the admin API, which is disabled if it is empty
+	AdminToken   string
+	QueryTimeout time.Duration
+	// RouteQueryTimeouts overrides QueryTimeout for the routes it contains
+	RouteQueryTimeouts map[string]time.Duration
+	// ExportTimeout bounds both the queries and the writing of an export, instead of QueryTimeout and WriteTimeout
+	ExportTimeout time.Duration
+}
+
+// setting describes one configuration value and the places it can be read from
+type setting struct {
+	env   string // environment variable, SC_ prefixed, secrets may instead be read from the file named by <env>_FILE
+	key   string // dotted path in the config file, the same path with a _file suffix names a file holding the value
+	usage string
+	def   string
+	set   func(config *Config, value string) error
+}
+
+func stringSetting(env string, key string, usage string, def string, field func(*Config) *string) setting {
+	return setting{env, key, usage, def, func(config *Config, value string) error {
+		*field(config) = value
+		return nil
+	}}
+}
+
+func durationSetting(env string, key string, usage string, def string, field func(*Config) *time.Duration) setting {
+	return setting{env, key, usage, def, func(config *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid %s %q, want a positive duration such as 5s", env, value)
+		}
+		*field(config) = d
+		return nil
+	}}
+}
+
+func bytesSetting(env string, key string, usage string, def string, field func(*Config) *int64) setting {
+	return setting{env, key, usage, def, func(config *Config, value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid %s %q, want a positive number of bytes", env, value)
+		}
+		*field(config) = n
+		return nil
+	}}
+}
+
+func intSetting(env string, key string, usage string, def string, field func(*Config) *int) setting {
+	return setting{env, key, usage, def, func(config *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid %s %q, want a positive number", env, value)
+		}
+		*field(config) = n
+		return nil
+	}}
+}
+
+func settings() []setting {
+	s := []setting{
+		stringSetting("SC_DB_DRIVER", "db.driver", "database driver: mysql, sqlite or postgres", DRIVER_MYSQL, func(c *Config) *string { return &c.DB.Driver }),
+		stringSetting("SC_DB_USER", "db.user", "database user", "", func(c *Config) *string { return &c.DB.User }),
+		stringSetting("SC_DB_PASSWORD", "db.password", "database password", "", func(c *Config) *string { return &c.DB.Password }),
+		stringSetting("SC_DB_NAME", "db.name", "database name, or file path for sqlite", "", func(c *Config) *string { return &c.DB.Name }),
+		stringSetting("SC_DB_HOST", "db.host", "database host", "", func(c *Config) *string { return &c.DB.Host }),
+		stringSetting("SC_DB_PORT", "db.port", "database port", "", func(c *Config) *string { return &c.DB.Port }),
+		stringSetting("SC_DB_SEED", "db.seed", "CSV or TSV file loaded into new databases instead of the embedded dataset", "", func(c *Config) *string { return &c.DB.Seed }),
+		stringSetting("SC_DB_MIGRATIONS", "db.migrations", "directory of <driver>/NNNN_name.up.sql migrations used instead of the embedded ones", "", func(c *Config) *string { return &c.DB.Migrations }),
+		stringSetting("SC_IMPORT_TRUSTED_KEYS", "import.trusted_keys", "file of signer names and ed25519 public keys that import files must be signed with", "", func(c *Config) *string { return &c.Import.TrustedKeys }),
+		intSetting("SC_JOBS_WORKERS", "jobs.workers", "number of background jobs run at the same time", "2", func(c *Config) *int { return &c.Jobs.Workers }),
+		intSetting("SC_JOBS_MAX_ATTEMPTS", "jobs.max_attempts", "number of times a failing background job is run", "3", func(c *Config) *int { return &c.Jobs.MaxAttempts }),
+		durationSetting("SC_JOBS_RETRY_BACKOFF", "jobs.retry_backoff", "delay before retrying a failed background job, doubled for every further retry", "10s", func(c *Config) *time.Duration { return &c.Jobs.RetryBackoff }),
+		stringSetting("SC_JOBS_DIR", "jobs.dir", "directory holding the files of queued background jobs", "jobs", func(c *Config) *string { return &c.Jobs.Dir }),
+		intSetting("SC_CACHE_SIZE", "cache.size", "number of code and country lookups cached", "10000", func(c *Config) *int { return &c.Cache.Size }),
+		durationSetting("SC_CACHE_TTL", "cache.ttl", "time a cached lookup is served", "1m", func(c *Config) *time.Duration { return &c.Cache.TTL }),
+		stringSetting("SC_CACHE_REDIS", "cache.redis", "URL of a Redis-compatible cache shared by the API replicas, e.g. redis://localhost:6379/0, empty for none", "", func(c *Config) *string { return &c.Cache.Redis }),
+		stringSetting("SC_CACHE_REDIS_PREFIX", "cache.redis_prefix", "prefix of the keys of the shared cache", "swiftcodes:", func(c *Config) *string { return &c.Cache.RedisPrefix }),
+		stringSetting("SC_API_HOST", "api.host", "address to listen on, empty for all interfaces", "", func(c *Config) *string { return &c.API.Host }),
+		stringSetting("SC_API_PORT", "api.port", "port to listen on", "8080", func(c *Config) *string { return &c.API.Port }),
+		durationSetting("SC_API_READ_TIMEOUT", "api.read_timeout", "time to read a whole request", "10s", func(c *Config) *time.Duration { return &c.API.ReadTimeout }),
+		durationSetting("SC_API_READ_HEADER_TIMEOUT", "api.read_header_timeout", "time to read request headers", "5s", func(c *Config) *time.Duration { return &c.API.ReadHeaderTimeout }),
+		durationSetting("SC_API_WRITE_TIMEOUT", "api.write_timeout", "time to write a response", "15s", func(c *Config) *time.Duration { return &c.API.WriteTimeout }),
+		durationSetting("SC_API_IDLE_TIMEOUT", "api.idle_timeout", "time to keep idle connections open", "60s", func(c *Config) *time.Duration { return &c.API.IdleTimeout }),
+		durationSetting("SC_API_SHUTDOWN_TIMEOUT", "api.shutdown_timeout", "time to wait for in-flight requests on shutdown", "15s", func(c *Config) *time.Duration { return &c.API.ShutdownTimeout }),
+		bytesSetting("SC_API_MAX_HEADER_BYTES", "api.max_header_bytes", "request header size limit", strconv.Itoa(http.DefaultMaxHeaderBytes), func(c *Config) *int64 { return &c.API.MaxHeaderBytes }),
+		bytesSetting("SC_API_MAX_BODY_BYTES", "api.max_body_bytes", "request body size limit", "65536", func(c *Config) *int64 { return &c.API.MaxBodyBytes }),
+		bytesSetting("SC_API_MAX_UPLOAD_BYTES", "api.max_upload_bytes", "request body size limit of the admin API", "67108864", func(c *Config) *int64 { return &c.API.MaxUploadBytes }),
+		stringSetting("SC_API_ADMIN_TOKEN", "api.admin_token", "bearer token of the admin API, disabled if empty", "", func(c *Config) *string { return &c.API.AdminToken }),
+		durationSetting("SC_API_QUERY_TIMEOUT", "api.query_timeout", "deadline of the queries of a request", "5s", func(c *Config) *time.Duration { return &c.API.QueryTimeout }),
+		durationSetting("SC_API_EXPORT_TIMEOUT", "api.export_timeout", "time to read and write an export, instead of the query and write timeouts", "10m", func(c *Config) *time.Duration { return &c.API.ExportTimeout }),
+	}
+	for _, route := range ROUTES {
+		s = append(s, setting{
+			"SC_API_QUERY_TIMEOUT_" + route,
+			"api.query_timeouts." + strings.ToLower(route),
+			"deadline of the queries of " + route + " requests, overrides SC_API_QUERY_TIMEOUT",
+			"",
+			func(config *Config, value string) error {
+				d, err := time.ParseDuration(value)
+				if err != nil || d <= 0 {
+					return fmt.Errorf("invalid SC_API_QUERY_TIMEOUT_%s %q, want a positive duration such as 5s", route, value)
+				}
+				config.API.RouteQueryTimeouts[route] = d
+				return nil
+			},
+		})
+	}
+	return s
+}
+
+// secrets are the settings without a command line flag, as flags show in ps and the shell history
+var secrets = map[string]bool{
+	"SC_DB_PASSWORD":     true,
+	"SC_API_ADMIN_TOKEN": true,
+	// The URL may hold credentials
+	"SC_CACHE_REDIS": true,
+}
+
+// flagName derives the command line flag of a setting from its environment variable, e.g. SC_DB_USER is -db-user
+func flagName(env string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(env, "SC_")), "_", "-")
+}
+
+// Load reads the configuration from, in order of precedence, the command line args, environment variables,
+// the optional YAML file named by -config or SC_CONFIG, and defaults. Empty environment variables count as unset.
+// The result is validated.
+func Load(args []string) (Config, error) {
+	return LoadFlagSet(flag.NewFlagSet("swiftcodes", flag.ContinueOnError), args)
+}
+
+// LoadFlagSet is Load with the configuration flags added to flags, so commands can define flags of their own
+// and read the remaining positional arguments from flags.Args()
+func LoadFlagSet(flags *flag.FlagSet, args []string) (Config, error) {
+	config, err := ReadFlagSet(flags, args)
+	if err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
+
+// ReadFlagSet is LoadFlagSet without the validation, for commands that may not use the database
+func ReadFlagSet(flags *flag.FlagSet, args []string) (Config, error) {
+	config := Config{API: API{RouteQueryTimeouts: make(map[string]time.Duration)}}
+	all := settings()
+
+	configPath := flags.String("config", os.Getenv("SC_CONFIG"), "path of a YAML config file")
+	flagValues := make(map[string]*string)
+	for _, s := range all {
+		if !secrets[s.env] {
+			flagValues[s.env] = flags.String(flagName(s.env), "", s.usage+" (env "+s.env+")")
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return config, err
+	}
+	setFlags := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	file := make(map[string]string)
+	if *configPath != "" {
+		var err error
+		if file, err = readFile(*configPath); err != nil {
+			return config, err
+		}
+	}
+
+	var errs []error
+	for _, s := range all {
+		var flagValue string
+		if flagValues[s.env] != nil {
+			flagValue = *flagValues[s.env]
+		}
+		value, found, err := lookup(s, setFlags[flagName(s.env)], flagValue, file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !found {
+			if s.def == "" {
+				continue
+			}
+			value = s.def
+		}
+		if err := s.set(&config, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return config, errors.Join(errs...)
+}
+
+// lookup finds the value of s, following the precedence documented on Load
+func lookup(s setting, flagSet bool, flagValue string, file map[string]string) (string, bool, error) {
+	if flagSet {
+		return flagValue, true, nil
+	}
+	if value := os.Getenv(s.env); value != "" {
+		return value, true, nil
+	}
+	if path := os.Getenv(s.env + "_FILE"); path != "" {
+		value, err := readSecret(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %w", s.env, err)
+		}
+		return value, true, nil
+	}
+	if value, ok := file[s.key]; ok {
+		return value, true, nil
+	}
+	if path, ok := file[s.key+"_file"]; ok {
+		value, err := readSecret(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_file: %w", s.key, err)
+		}
+		return value, true, nil
+	}
+	return "", false, nil
+}
+
+// readSecret reads a value stored in a file, as done for Docker and Kubernetes secrets
+func readSecret(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// readFile reads a YAML config file into a map from dotted keys to values
+func readFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read config file: %w", err)
+	}
+	var tree map[string]any
+	if err := yaml.Unmarshal(content, &tree); err != nil {
+		return nil, fmt.Errorf("couldn't parse config file %s: %w", path, err)
+	}
+	values := make(map[string]string)
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]any, values map[string]string) {
+	for key, value := range tree {
+		if sub, ok := value.(map[string]any); ok {
+			flatten(prefix+key+".", sub, values)
+		} else if value != nil {
+			values[prefix+key] = fmt.Sprint(value)
+		}
+	}
+}
+
+// Validate checks that the settings required by the configured driver are present
+func (config Config) Validate() error {
+	var errs []error
+	required := func(value string, env string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required (or -%s)", env, flagName(env)))
+		}
+	}
+	switch config.DB.Driver {
+	case DRIVER_MYSQL, DRIVER_POSTGRES:
+		required(config.DB.User, "SC_DB_USER")
+		required(config.DB.Host, "SC_DB_HOST")
+		required(config.DB.Port, "SC_DB_PORT")
+	case DRIVER_SQLITE:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported SC_DB_DRIVER %q, want mysql, sqlite or postgres", config.DB.Driver))
+	}
+	required(config.DB.Name, "SC_DB_NAME")
+	return errors.Join(errs...)
+}
+
+// Addr is the address the API listens on
+func (api API) Addr() string {
+	return net.JoinHostPort(api.Host, api.Port)
+}
+
+// QueryTimeoutFor returns how long the queries of a request to route may take
+func (api API) QueryTimeoutFor(route string) time.Duration {
+	if timeout, ok := api.RouteQueryTimeouts[route]; ok {
+		return timeout
+	}
+	return api.QueryTimeout
+}
+
+// MySQLConfig returns the go-sql-driver settings of the database, or of the server only if the name is empty
+func (db DB) MySQLConfig() *mysql.Config {
+	config := mysql.NewConfig()
+	config.User = db.User
+	config.Passwd = db.Password
+	config.Net = "tcp"
+	config.Addr = net.JoinHostPort(db.Host, db.Port)
+	config.DBName = db.Name
+	return config
+}
+
+// DSN returns the data source name of the database for its driver
+func (db DB) DSN() string {
+	switch db.Driver {
+	case DRIVER_SQLITE:
+		return "file:" + db.Name + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	case DRIVER_POSTGRES:
+		u := url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(db.User, db.Password),
+			Host:   net.JoinHostPort(db.Host, db.Port),
+			Path:   "/" + db.Name,
+		}
+		return u.String()
+	}
+	return db.MySQLConfig().FormatDSN()
+}
+
+// WithName returns the settings for database name on the same server
+func (db DB) WithName(name string) DB {
+	db.Name = name
+	return db
+}
@@ -0,0 +1,301 @@
+package importjob
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"swiftcodes/internal/initdb"
+	"swiftcodes/internal/jobs"
+	"swiftcodes/internal/store"
+	"swiftcodes/internal/verify"
+	"swiftcodes/sqlcout"
+)
+
+// KIND is the kind of the background jobs importing uploaded files
+const KIND = "import"
+
+var ErrNotFound = errors.New("import job not found")
+
+type invalidError struct {
+	err error
+}
+
+func (e invalidError) Error() string { return e.err.Error() }
+func (e invalidError) Unwrap() error { return e.err }
+
+// IsInvalid reports whether Enqueue returned err because the upload failed Validate
+func IsInvalid(err error) bool {
+	var invalid invalidError
+	return errors.As(err, &invalid)
+}
+
+// Payload is stored with a job, the uploaded file is kept in a file of its own until the job finished
+type Payload struct {
+	FileName  string `json:"fileName"`
+	Path      string `json:"path"`
+	Sha256    string `json:"sha256"`
+	Signer    string `json:"signer"`
+	MaxErrors int    `json:"maxErrors"`
+}
+
+// Progress is saved by a running job, it starts over when the job is retried
+type Progress struct {
+	Progress float64           `json:"progress"`
+	Rows     int               `json:"rows"`
+	Imported int               `json:"imported"`
+	Failed   int               `json:"failed"`
+	Errors   []initdb.RowError `json:"errors"`
+}
+
+// Status reports the progress of a job. The counts grow while the job runs; once it finished they are
+// those of the import report, which adds the rows that failed to be stored.
+type Status struct {
+	// ID is also the ID of the import batch the imported codes reference
+	ID       string `json:"id"`
+	FileName string `json:"fileName"`
+	Sha256   string `json:"sha256"`
+	Signer   string `json:"signer"`
+	State    string `json:"state"`
+	Attempts int64  `json:"attempts"`
+	// Progress is the fraction of the file read, from 0 to 1
+	Progress   float64           `json:"progress"`
+	Rows       int               `json:"rows"`
+	Imported   int               `json:"imported"`
+	Failed     int               `json:"failed"`
+	Errors     []initdb.RowError `json:"errors"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  string            `json:"createdAt"`
+	FinishedAt string            `json:"finishedAt,omitempty"`
+}
+
+// Finished reports whether the job stopped, whatever the outcome
+func (status Status) Finished() bool {
+	return status.State != jobs.STATE_QUEUED && status.State != jobs.STATE_RUNNING
+}
+
+// StatusOf returns the status of job, ErrNotFound if it doesn't import a file
+func StatusOf(job jobs.Job) (Status, error) {
+	if job.Kind != KIND {
+		return Status{}, ErrNotFound
+	}
+	var payload Payload
+	if err := job.Payload(&payload); err != nil {
+		return Status{}, err
+	}
+	var progress Progress
+	if err := json.Unmarshal(job.Progress, &progress); err != nil {
+		return Status{}, err
+	}
+	if progress.Errors == nil {
+		progress.Errors = []initdb.RowError{}
+	}
+	return Status{
+		ID:         job.ID,
+		FileName:   payload.FileName,
+		Sha256:     payload.Sha256,
+		Signer:     payload.Signer,
+		State:      job.State,
+		Attempts:   job.Attempts,
+		Progress:   progress.Progress,
+		Rows:       progress.Rows,
+		Imported:   progress.Imported,
+		Failed:     progress.Failed,
+		Errors:     progress.Errors,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		FinishedAt: job.FinishedAt,
+	}, nil
+}
+
+// Enqueue validates upload and stores it in dir for a new job, returning the status of the job. Errors of
+// the validation are reported by IsInvalid.
+func Enqueue(ctx context.Context, runner *jobs.Runner, dir string, upload Upload) (Status, error) {
+	if err := Validate(upload); err != nil {
+		return Status{}, invalidError{err}
+	}
+	id := jobs.NewID()
+	payload := Payload{
+		FileName:  upload.FileName,
+		Path:      filepath.Join(dir, id+filepath.Ext(upload.FileName)),
+		Sha256:    verify.Digest(upload.Data),
+		Signer:    upload.Signer,
+		MaxErrors: upload.MaxErrors,
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Status{}, fmt.Errorf("couldn't store %s: %w", upload.FileName, err)
+	}
+	if err := os.WriteFile(payload.Path, upload.Data, 0o600); err != nil {
+		return Status{}, fmt.Errorf("couldn't store %s: %w", upload.FileName, err)
+	}
+	job, err := runner.Enqueue(ctx, id, KIND, payload)
+	if err != nil {
+		os.Remove(payload.Path)
+		return Status{}, err
+	}
+	return StatusOf(job)
+}
+
+// Handler imports the files of the jobs of KIND with queries. A retried job first rolls back the codes of
+// the attempt before, and the file is removed once the job finished. invalidate, which may be nil, is called
+// once after every attempt, e.g. to drop the cached lookups of the codes it wrote.
+func Handler(queries store.Store, invalidate func(ctx context.Context)) jobs.Handler {
+	return jobs.Handler{
+		Run: func(ctx context.Context, run *jobs.Run) error {
+			err := importFile(ctx, queries, run)
+			if invalidate != nil {
+				invalidate(ctx)
+			}
+			return err
+		},
+		Done: func(job jobs.Job) {
+			var payload Payload
+			if err := job.Payload(&payload); err == nil {
+				os.Remove(payload.Path)
+			}
+		},
+	}
+}
+
+func importFile(ctx context.Context, queries store.Store, run *jobs.Run) error {
+	var payload Payload
+	if err := run.Payload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	data, err := os.ReadFile(payload.Path)
+	if err != nil {
+		return jobs.Permanent(fmt.Errorf("couldn't read %s: %w", payload.FileName, err))
+	}
+	r, name, size, err := Upload{FileName: payload.FileName, Data: data}.open()
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	defer r.Close()
+
+	if run.Attempts > 1 {
+		if _, err := queries.DeleteSwiftCodesByImport(ctx, sql.NullString{String: run.ID, Valid: true}); err != nil {
+			return err
+		}
+		if _, err := queries.DeleteImport(ctx, run.ID); err != nil {
+			return err
+		}
+	}
+
+	tracker := &tracker{size: size, progress: Progress{Errors: []initdb.RowError{}}}
+	run.SetProgress(tracker.snapshot)
+	batch := sqlcout.InsertImportParams{
+		ID:         run.ID,
+		FileName:   payload.FileName,
+		Sha256:     payload.Sha256,
+		Signer:     payload.Signer,
+		ImportedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	reader := &progressReader{initdb.NewReader(&countingReader{r, &tracker.read}, initdb.Comma(name)), tracker}
+	report, err := initdb.Import(ctx, progressStore{queries, tracker}, reader, payload.MaxErrors, batch)
+	tracker.finish(report, err == nil)
+	switch {
+	case err == nil, ctx.Err() != nil:
+		return err
+	case errors.Is(err, store.ErrUnavailable), errors.Is(err, store.ErrTimeout):
+		return err
+	default:
+		return jobs.Permanent(err)
+	}
+}
+
+// tracker counts the progress of a running job
+type tracker struct {
+	// read and size are the bytes of the import file read so far and in total
+	read atomic.Int64
+	size int64
+
+	mu       sync.Mutex
+	progress Progress
+}
+
+func (t *tracker) update(f func(progress *Progress)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f(&t.progress)
+}
+
+func (t *tracker) snapshot() any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	progress := t.progress
+	progress.Errors = slices.Clone(progress.Errors)
+	if t.size > 0 && progress.Progress < 1 {
+		progress.Progress = min(float64(t.read.Load())/float64(t.size), 1)
+	}
+	return progress
+}
+
+// finish replaces the counts with those of the import report
+func (t *tracker) finish(report initdb.Report, complete bool) {
+	t.update(func(progress *Progress) {
+		progress.Rows = report.Rows
+		progress.Imported = report.Imported
+		progress.Failed = report.Failed
+		progress.Errors = report.Errors
+		if complete {
+			progress.Progress = 1
+		}
+	})
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r    io.Reader
+	read *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read.Add(int64(n))
+	return n, err
+}
+
+// progressReader counts the rows read and the rows that failed to be read
+type progressReader struct {
+	reader  initdb.RecordReader
+	tracker *tracker
+}
+
+func (r *progressReader) Read() (initdb.Record, error) {
+	record, err := r.reader.Read()
+	var rowErr *initdb.RowError
+	switch {
+	case errors.As(err, &rowErr):
+		r.tracker.update(func(progress *Progress) {
+			progress.Rows++
+			progress.Failed++
+			progress.Errors = append(progress.Errors, *rowErr)
+		})
+	case err == nil:
+		r.tracker.update(func(progress *Progress) { progress.Rows++ })
+	}
+	return record, err
+}
+
+// progressStore counts the codes stored
+type progressStore struct {
+	store.Store
+	tracker *tracker
+}
+
+func (s progressStore) InsertSwiftCode(ctx context.Context, arg sqlcout.InsertSwiftCodeParams) (sql.Result, error) {
+	result, err := s.Store.InsertSwiftCode(ctx, arg)
+	if err == nil {
+		s.tracker.update(func(progress *Progress) { progress.Imported++ })
+	}
+	return result, err
+}
@@ -0,0 +1,183 @@
+package importjob
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"swiftcodes/internal/config"
+	"swiftcodes/internal/jobs"
+	"swiftcodes/internal/migrate"
+	"swiftcodes/internal/store"
+	"swiftcodes/sqlcout"
+)
+
+const TEST_FILE = "COUNTRY ISO2 CODE\tSWIFT CODE\tCODE TYPE\tNAME\tADDRESS\tTOWN NAME\tCOUNTRY NAME\tTIME ZONE\n" +
+	"PL\tBIGBPLPWXXX\tBIC11\tBANK MILLENNIUM S.A.\tHARMONY CENTER\tWARSZAWA\tPOLAND\tEurope/Warsaw\n" +
+	"PL\tBIGBPLP\tBIC11\tBANK MILLENNIUM S.A.\tHARMONY CENTER\tWARSZAWA\tPOLAND\tEurope/Warsaw\n" +
+	"MT\tAKBKMTMTXXX\tBIC11\tAKBANK T.A.S. (MALTA BRANCH)\tPORTOMASO BUSINESS TOWER\tST. JULIAN'S\tMALTA\tEurope/Malta\n"
+
+// zipFile returns a zip file holding files by name
+func zipFile(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("error zipping %s: %v", name, err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("error zipping: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func setupRunner(t *testing.T) (*jobs.Runner, store.Store, string) {
+	db, err := store.Open(config.DB{Driver: config.DRIVER_SQLITE, Name: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrate.Up(context.Background(), db, config.DRIVER_SQLITE); err != nil {
+		t.Fatalf("error creating tables: %v", err)
+	}
+	queries := store.New(config.DRIVER_SQLITE, db)
+	runner := jobs.NewRunner(queries, jobs.Options{Workers: 1, Backoff: time.Millisecond, Poll: 10 * time.Millisecond})
+	runner.Handle(KIND, Handler(queries, nil))
+	return runner, queries, t.TempDir()
+}
+
+// wait polls the job with id until it finished
+func wait(t *testing.T, runner *jobs.Runner, id string) Status {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		job, err := runner.Get(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Get(%s) error: %v", id, err)
+		}
+		if status, err := StatusOf(job); err != nil || status.Finished() {
+			return status
+		}
+	}
+	t.Fatalf("job %s didn't finish", id)
+	return Status{}
+}
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		upload  Upload
+		wantErr bool
+	}{
+		{Upload{FileName: "codes.tsv", Data: []byte(TEST_FILE)}, false},
+		{Upload{FileName: "codes.zip", Data: zipFile(t, map[string]string{"dir/codes.TSV": TEST_FILE})}, false},
+		{Upload{FileName: "codes.csv", Data: []byte("SWIFT CODE,NAME,COUNTRY ISO2 CODE,COUNTRY NAME\n")}, false},
+		{Upload{FileName: "codes.csv", Data: []byte(TEST_FILE)}, true},
+		{Upload{FileName: "codes.tsv", Data: nil}, true},
+		{Upload{FileName: "codes.xml", Data: []byte(TEST_FILE)}, true},
+		{Upload{FileName: "codes.zip", Data: []byte(TEST_FILE)}, true},
+		{Upload{FileName: "codes.zip", Data: zipFile(t, map[string]string{"codes.tsv": TEST_FILE, "more.tsv": TEST_FILE})}, true},
+		{Upload{FileName: "codes.zip", Data: zipFile(t, map[string]string{"codes.txt": TEST_FILE})}, true},
+		{Upload{FileName: "codes.zip", Data: zipFile(t, nil)}, true},
+	}
+	for i := 0; i < len(tt); i++ {
+		if err := Validate(tt[i].upload); (err != nil) != tt[i].wantErr {
+			t.Errorf("Validate(%s) error = %v, want error %v", tt[i].upload.FileName, err, tt[i].wantErr)
+		}
+	}
+}
+
+func TestImportJobs(t *testing.T) {
+	runner, queries, dir := setupRunner(t)
+	ctx := context.Background()
+
+	if _, err := Enqueue(ctx, runner, dir, Upload{FileName: "codes.xml", Data: []byte(TEST_FILE)}); !errors.Is(err, ErrUnsupportedFile) || !IsInvalid(err) {
+		t.Errorf("Enqueue() of an XML file error = %v, want invalid %v", err, ErrUnsupportedFile)
+	}
+
+	var invalidations atomic.Int64
+	runner.Handle(KIND, Handler(queries, func(context.Context) { invalidations.Add(1) }))
+
+	// A job queued while the runner is stopped runs once it starts
+	started, err := Enqueue(ctx, runner, dir, Upload{FileName: "codes.zip", Data: zipFile(t, map[string]string{"codes.tsv": TEST_FILE}), MaxErrors: 1})
+	if err != nil || started.Finished() {
+		t.Fatalf("Enqueue() = %+v, %v, want a queued job", started, err)
+	}
+	runner.Start()
+	defer runner.Close()
+	status := wait(t, runner, started.ID)
+	if status.State != jobs.STATE_SUCCEEDED || status.Progress != 1 || status.Rows != 3 || status.Imported != 2 || status.Failed != 1 || len(status.Errors) != 1 || status.Errors[0].Line != 3 {
+		t.Errorf("status = %+v, want 2 of 3 rows imported with the error on line 3", status)
+	}
+	if n := invalidations.Load(); n != 1 {
+		t.Errorf("cache invalidated %d times by the import, want once", n)
+	}
+	imports, err := queries.ListImports(ctx)
+	if err != nil || len(imports) != 1 || imports[0].ID != started.ID || imports[0].Records != 2 {
+		t.Errorf("ListImports() = %+v, %v, want import %s with 2 codes", imports, err, started.ID)
+	}
+	// The uploaded file is removed once the job finished
+	time.Sleep(20 * time.Millisecond)
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("files left in the job directory: %v", files)
+	}
+
+	// Importing the same codes again fails on the first duplicate, which isn't retried
+	status, _ = Enqueue(ctx, runner, dir, Upload{FileName: "codes.tsv", Data: []byte(TEST_FILE), MaxErrors: 1})
+	if status = wait(t, runner, status.ID); status.State != jobs.STATE_FAILED || status.Attempts != 1 || status.Imported != 0 || status.Error == "" {
+		t.Errorf("status of a repeated import = %+v, want failed", status)
+	}
+
+	other, _ := runner.List(ctx, 1)
+	if _, err := StatusOf(jobs.Job{ID: other[0].ID, Kind: "other"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("StatusOf() of another kind of job error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestImportJobRetry(t *testing.T) {
+	runner, queries, dir := setupRunner(t)
+	ctx := context.Background()
+	status, err := Enqueue(ctx, runner, dir, Upload{FileName: "codes.tsv", Data: []byte(TEST_FILE), MaxErrors: 1})
+	if err != nil {
+		t.Fatalf("Enqueue() error: %v", err)
+	}
+
+	// The first attempt stores part of the file before the database goes away
+	runner.Handle(KIND, jobs.Handler{Run: func(ctx context.Context, run *jobs.Run) error {
+		if run.Attempts == 1 {
+			return importFile(ctx, &failingStore{queries, 1}, run)
+		}
+		return importFile(ctx, queries, run)
+	}})
+	runner.Start()
+	defer runner.Close()
+	status = wait(t, runner, status.ID)
+	if status.State != jobs.STATE_SUCCEEDED || status.Attempts != 2 || status.Imported != 2 {
+		t.Errorf("status = %+v, want 2 codes imported by the second attempt", status)
+	}
+	imports, err := queries.ListImports(ctx)
+	if err != nil || len(imports) != 1 || imports[0].Records != 2 {
+		t.Errorf("ListImports() = %+v, %v, want one import of 2 codes", imports, err)
+	}
+}
+
+// failingStore fails with ErrUnavailable after storing limit codes
+type failingStore struct {
+	store.Store
+	limit int
+}
+
+func (s *failingStore) InsertSwiftCode(ctx context.Context, arg sqlcout.InsertSwiftCodeParams) (sql.Result, error) {
+	if s.limit == 0 {
+		return nil, store.ErrUnavailable
+	}
+	s.limit--
+	return s.Store.InsertSwiftCode(ctx, arg)
+}
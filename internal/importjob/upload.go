@@ -0,0 +1,86 @@
+package importjob
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"swiftcodes/internal/initdb"
+)
+
+// Upload is an import file sent to the API
+type Upload struct {
+	FileName string
+	Data     []byte
+	// Signer is the name of the trusted key the file was verified with, empty if it wasn't verified
+	Signer string
+	// MaxErrors is the number of failed rows tolerated before the import is aborted, -1 for no limit
+	MaxErrors int
+}
+
+var (
+	ErrUnsupportedFile = errors.New("unsupported file, want a .csv, .tsv or .zip file")
+	ErrZipContent      = errors.New("zip file must hold exactly one .csv or .tsv file")
+)
+
+// Validate checks that upload is a CSV or TSV file, or a zip file holding one, whose header has all required
+// columns. Rows are only validated while importing, like the import command does.
+func Validate(upload Upload) error {
+	r, name, _, err := upload.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	csvReader := csv.NewReader(r)
+	csvReader.Comma = initdb.Comma(name)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return fmt.Errorf("%s is empty", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid header in %s: %w", name, err)
+	}
+	if _, err := initdb.DefaultAliases().Map(header); err != nil {
+		return fmt.Errorf("invalid header in %s: %w", name, err)
+	}
+	return nil
+}
+
+// open returns the content of the import file with its name and size, unpacking it from a zip file
+func (upload Upload) open() (io.ReadCloser, string, int64, error) {
+	switch strings.ToLower(path.Ext(upload.FileName)) {
+	case ".csv", ".tsv":
+		return io.NopCloser(bytes.NewReader(upload.Data)), upload.FileName, int64(len(upload.Data)), nil
+	case ".zip":
+	default:
+		return nil, "", 0, ErrUnsupportedFile
+	}
+	archive, err := zip.NewReader(bytes.NewReader(upload.Data), int64(len(upload.Data)))
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("invalid zip file: %w", err)
+	}
+	var file *zip.File
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if ext := strings.ToLower(path.Ext(f.Name)); file != nil || (ext != ".csv" && ext != ".tsv") {
+			return nil, "", 0, ErrZipContent
+		}
+		file = f
+	}
+	if file == nil {
+		return nil, "", 0, ErrZipContent
+	}
+	r, err := file.Open()
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("invalid zip file: %w", err)
+	}
+	return r, file.Name, int64(file.UncompressedSize64), nil
+}
@@ -0,0 +1,114 @@
+package initdb
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"swiftcodes/sqlcout"
+)
+
+// Columns of BIC Plus files read by BICPlusReader
+const (
+	BIC_PLUS_MODIFICATION_FLAG = "MODIFICATION FLAG"
+	BIC_PLUS_BIC8              = "BIC8"
+	BIC_PLUS_BRANCH_BIC        = "BRANCH BIC"
+	BIC_PLUS_INSTITUTION_NAME  = "INSTITUTION NAME"
+	BIC_PLUS_CITY              = "CITY"
+	BIC_PLUS_ZIP_CODE          = "ZIP CODE"
+	BIC_PLUS_COUNTRY_NAME      = "COUNTRY NAME"
+	BIC_PLUS_ISO_COUNTRY_CODE  = "ISO COUNTRY CODE"
+)
+
+// BIC_PLUS_STREET_ADDRESS are the street address columns of BIC Plus files, in address order
+var BIC_PLUS_STREET_ADDRESS = []string{"STREET ADDRESS 1", "STREET ADDRESS 2", "STREET ADDRESS 3", "STREET ADDRESS 4"}
+
+var bicPlusRequired = []string{BIC_PLUS_BIC8, BIC_PLUS_BRANCH_BIC, BIC_PLUS_INSTITUTION_NAME, BIC_PLUS_COUNTRY_NAME, BIC_PLUS_ISO_COUNTRY_CODE}
+
+// BICPlusReader reads the tab separated BIC Plus files, full or delta, finding the columns by the header.
+// The address joins the street address lines, the city and the zip code. Records flagged as deleted in
+// delta files hold no code to insert and are skipped.
+type BICPlusReader struct {
+	csv     *csv.Reader
+	columns map[string]int
+	width   int
+	err     error
+}
+
+func NewBICPlusReader(r io.Reader) *BICPlusReader {
+	csvReader := csv.NewReader(r)
+	csvReader.Comma = '\t'
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	csvReader.ReuseRecord = true
+	return &BICPlusReader{csv: csvReader}
+}
+
+func (r *BICPlusReader) Read() (Record, error) {
+	if r.err != nil {
+		return Record{}, r.err
+	}
+	if r.columns == nil {
+		header, err := r.csv.Read()
+		if err == nil {
+			r.columns, err = mapBICPlusHeader(header)
+		}
+		if err != nil {
+			r.err = fmt.Errorf("invalid header: %w", err)
+			if err == io.EOF {
+				r.err = err
+			}
+			return Record{}, r.err
+		}
+		r.width = rowWidth(r.columns)
+	}
+	for {
+		row, err := r.csv.Read()
+		if err != nil {
+			return Record{}, readError(err)
+		}
+		line, _ := r.csv.FieldPos(0)
+		if len(row) < r.width {
+			return Record{}, &RowError{Line: line, Message: fmt.Sprintf("has %d columns, want at least %d", len(row), r.width)}
+		}
+		field := func(column string) string {
+			if i, ok := r.columns[column]; ok {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		if strings.EqualFold(field(BIC_PLUS_MODIFICATION_FLAG), "D") {
+			continue
+		}
+		var street []string
+		for _, column := range BIC_PLUS_STREET_ADDRESS {
+			street = append(street, field(column))
+		}
+		record := Record{
+			Line: line,
+			Code: sqlcout.InsertSwiftCodeParams{
+				SwiftCode:   field(BIC_PLUS_BIC8) + field(BIC_PLUS_BRANCH_BIC),
+				BankName:    field(BIC_PLUS_INSTITUTION_NAME),
+				Address:     joinNonEmpty(", ", joinNonEmpty(" ", street...), field(BIC_PLUS_CITY), field(BIC_PLUS_ZIP_CODE)),
+				CountryISO2: strings.ToUpper(field(BIC_PLUS_ISO_COUNTRY_CODE)),
+			},
+			CountryName: strings.ToUpper(field(BIC_PLUS_COUNTRY_NAME)),
+		}
+		if err := record.validate(); err != nil {
+			return Record{}, err
+		}
+		return record, nil
+	}
+}
+
+// mapBICPlusHeader returns the position of each known BIC Plus column in header
+func mapBICPlusHeader(header []string) (map[string]int, error) {
+	known := append([]string{BIC_PLUS_MODIFICATION_FLAG, BIC_PLUS_CITY, BIC_PLUS_ZIP_CODE}, bicPlusRequired...)
+	known = append(known, BIC_PLUS_STREET_ADDRESS...)
+	byName := make(map[string]string)
+	for _, column := range known {
+		byName[normalizeHeader(column)] = column
+	}
+	return mapHeader(byName, header, bicPlusRequired)
+}
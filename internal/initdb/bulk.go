@@ -0,0 +1,82 @@
+package initdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"swiftcodes/internal/store"
+	"swiftcodes/sqlcout"
+)
+
+// BulkImport loads the records of reader like Import, but with multi-row INSERT statements in one
+// transaction, so it either loads every valid row or none. Invalid rows count against maxErrors as in
+// Import, while any failed insert, such as of a code that is stored already, rolls the whole load back.
+// batch is recorded in the same transaction.
+func BulkImport(ctx context.Context, db *sql.DB, driver string, reader RecordReader, maxErrors int, batch sqlcout.InsertImportParams) (Report, error) {
+	start := time.Now()
+	report := Report{Errors: []RowError{}}
+	err := store.InTx(ctx, db, driver, func(queries store.Store) error {
+		if _, err := queries.InsertImport(ctx, batch); err != nil {
+			return fmt.Errorf("couldn't record import: %w", err)
+		}
+		countries := make(map[string]bool)
+		codes := make([]sqlcout.InsertSwiftCodeParams, 0, store.BATCH_SIZE)
+		var firstLine, lastLine int
+		flush := func() error {
+			if len(codes) == 0 {
+				return nil
+			}
+			inserted, err := queries.InsertSwiftCodes(ctx, codes)
+			if err != nil {
+				return fmt.Errorf("couldn't insert the rows on lines %d to %d: %w", firstLine, lastLine, err)
+			}
+			report.Imported += int(inserted)
+			codes = codes[:0]
+			return nil
+		}
+
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return flush()
+			}
+			var rowErr *RowError
+			if errors.As(err, &rowErr) {
+				report.Rows++
+				if err := report.fail(rowErr, maxErrors); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			report.Rows++
+
+			if err := addCountry(ctx, queries, countries, record); err != nil {
+				return fmt.Errorf("line %d: %w", record.Line, err)
+			}
+			if len(codes) == 0 {
+				firstLine = record.Line
+			}
+			lastLine = record.Line
+			record.Code.ImportID = batchID(batch)
+			codes = append(codes, record.Code)
+			if len(codes) == store.BATCH_SIZE {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	})
+	if err != nil {
+		report.Imported = 0
+		report.Aborted = true
+	}
+	report.finish(start)
+	return report, err
+}
@@ -0,0 +1,128 @@
+package initdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"swiftcodes/internal/config"
+	"swiftcodes/internal/migrate"
+	"swiftcodes/internal/store"
+)
+
+// syntheticFile returns an import file of rows valid codes spread over 26 countries
+func syntheticFile(rows int) string {
+	var content strings.Builder
+	content.WriteString(HEADER_ROW)
+	for i := 0; i < rows; i++ {
+		country := string(rune('A'+i%26)) + "Z"
+		bank := fmt.Sprintf("%c%c%c%c", 'A'+i/17576%26, 'A'+i/676%26, 'A'+i/26%26, 'A'+i%26)
+		fmt.Fprintf(&content, "%s\t%s%s22XXX\tBIC11\tBANK %d\tSTREET %d\tTOWN\tCOUNTRY %s\tEurope/Warsaw\n", country, bank, country, i, i, country)
+	}
+	return content.String()
+}
+
+func setupSQLite(tb testing.TB) (*sql.DB, store.Store) {
+	db, err := store.Open(config.DB{Driver: config.DRIVER_SQLITE, Name: filepath.Join(tb.TempDir(), "test.db")})
+	if err != nil {
+		tb.Fatalf("Open() error: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if _, err := migrate.Up(context.Background(), db, config.DRIVER_SQLITE); err != nil {
+		tb.Fatalf("error creating tables: %v", err)
+	}
+	return db, store.New(config.DRIVER_SQLITE, db)
+}
+
+func TestBulkImport(t *testing.T) {
+	ctx := context.Background()
+	db, queries := setupSQLite(t)
+	content := syntheticFile(1234)
+
+	report, err := BulkImport(ctx, db, config.DRIVER_SQLITE, NewReader(strings.NewReader(content), '\t'), 0, NewImport("codes.tsv", "", ""))
+	if err != nil || report.Rows != 1234 || report.Imported != 1234 || report.Aborted {
+		t.Fatalf("BulkImport() = %+v, %v, want 1234 rows imported", report, err)
+	}
+	codes, err := queries.ListSwiftCodes(ctx)
+	if err != nil || len(codes) != 1234 {
+		t.Errorf("ListSwiftCodes() after BulkImport() = %d codes, %v, want 1234", len(codes), err)
+	}
+
+	// Loading a file again fails on the first duplicate and rolls back all its rows, including new ones
+	again := content + "PL\tBIGBPLPWXXX\tBIC11\tBANK\tSTREET\tTOWN\tPOLAND\tEurope/Warsaw\n"
+	report, err = BulkImport(ctx, db, config.DRIVER_SQLITE, NewReader(strings.NewReader(again), '\t'), 0, NewImport("codes.tsv", "", ""))
+	if !errors.Is(err, store.ErrDuplicateCode) || report.Imported != 0 || !report.Aborted {
+		t.Errorf("BulkImport() of duplicates = %+v, %v, want %v", report, err, store.ErrDuplicateCode)
+	}
+	if _, err := queries.GetCountry(ctx, "PL"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf(`GetCountry("PL") after rolled back BulkImport() = %v, want %v`, err, store.ErrNotFound)
+	}
+
+	// Exceeding the error budget rolls back too
+	invalid := HEADER_ROW + "PL\tBIGBPLPWXXX\tBIC11\tBANK\tSTREET\tTOWN\tPOLAND\tEurope/Warsaw\nPL\tBAD\n"
+	report, err = BulkImport(ctx, db, config.DRIVER_SQLITE, NewReader(strings.NewReader(invalid), '\t'), 0, NewImport("codes.tsv", "", ""))
+	if !errors.Is(err, ErrTooManyErrors) || report.Imported != 0 || report.Failed != 1 {
+		t.Errorf("BulkImport() over budget = %+v, %v, want %v", report, err, ErrTooManyErrors)
+	}
+	if _, err := queries.GetCountry(ctx, "PL"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf(`GetCountry("PL") after rolled back BulkImport() = %v, want %v`, err, store.ErrNotFound)
+	}
+}
+
+const BENCHMARK_ROWS = 10000
+
+// BenchmarkPopulateDB measures the original path, parsing the whole file and inserting one row per statement
+func BenchmarkPopulateDB(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "codes.tsv")
+	if err := os.WriteFile(path, []byte(syntheticFile(BENCHMARK_ROWS)), 0o600); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		_, queries := setupSQLite(b)
+		b.StartTimer()
+		rows, err := ReadCSV(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		codes, countries, err := ParseData(rows)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if failed := PopulateDB(queries, context.Background(), countries, codes); failed > 0 {
+			b.Fatalf("PopulateDB() failed %d rows", failed)
+		}
+	}
+	b.ReportMetric(float64(BENCHMARK_ROWS*b.N)/b.Elapsed().Seconds(), "rows/s")
+}
+
+func BenchmarkImport(b *testing.B) {
+	content := syntheticFile(BENCHMARK_ROWS)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		_, queries := setupSQLite(b)
+		b.StartTimer()
+		if _, err := Import(context.Background(), queries, NewReader(strings.NewReader(content), '\t'), 0, NewImport("codes.tsv", "", "")); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(BENCHMARK_ROWS*b.N)/b.Elapsed().Seconds(), "rows/s")
+}
+
+func BenchmarkBulkImport(b *testing.B) {
+	content := syntheticFile(BENCHMARK_ROWS)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db, _ := setupSQLite(b)
+		b.StartTimer()
+		if _, err := BulkImport(context.Background(), db, config.DRIVER_SQLITE, NewReader(strings.NewReader(content), '\t'), 0, NewImport("codes.tsv", "", "")); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(BENCHMARK_ROWS*b.N)/b.Elapsed().Seconds(), "rows/s")
+}
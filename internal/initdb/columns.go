@@ -0,0 +1,112 @@
+package initdb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Columns read from import files
+const (
+	COLUMN_COUNTRY_ISO2 = "country_iso2"
+	COLUMN_SWIFT_CODE   = "swift_code"
+	COLUMN_BANK_NAME    = "bank_name"
+	COLUMN_ADDRESS      = "address"
+	COLUMN_COUNTRY_NAME = "country_name"
+)
+
+// REQUIRED_COLUMNS must be present in every import file, a missing address is read as empty
+var REQUIRED_COLUMNS = []string{COLUMN_COUNTRY_ISO2, COLUMN_SWIFT_CODE, COLUMN_BANK_NAME, COLUMN_COUNTRY_NAME}
+
+// Errors of import file headers
+var (
+	ErrMissingColumns   = errors.New("missing required columns")
+	ErrDuplicateColumns = errors.New("duplicate column")
+)
+
+// Aliases maps each column to the header names it may have in import files. Header names are matched
+// ignoring case and treating spaces, underscores and hyphens alike, so "SWIFT CODE" also matches "swift_code".
+type Aliases map[string][]string
+
+// DefaultAliases returns the header names of this project's files and of common vendor files
+func DefaultAliases() Aliases {
+	return Aliases{
+		COLUMN_COUNTRY_ISO2: {"COUNTRY ISO2 CODE", "COUNTRY ISO2", "ISO2", "COUNTRY CODE"},
+		COLUMN_SWIFT_CODE:   {"SWIFT CODE", "SWIFT", "BIC", "BIC11", "BIC CODE"},
+		COLUMN_BANK_NAME:    {"NAME", "BANK NAME", "INSTITUTION NAME", "INSTITUTION"},
+		COLUMN_ADDRESS:      {"ADDRESS", "STREET ADDRESS"},
+		COLUMN_COUNTRY_NAME: {"COUNTRY NAME", "COUNTRY"},
+	}
+}
+
+// Add makes header another name of column
+func (aliases Aliases) Add(column string, header string) error {
+	if _, ok := aliases[column]; !ok {
+		return fmt.Errorf("unknown column %q, want one of %s", column, strings.Join(aliases.columns(), ", "))
+	}
+	aliases[column] = append(aliases[column], header)
+	return nil
+}
+
+// Map returns the position of each column in header. Columns without a matching header are left out,
+// failing with ErrMissingColumns if they are required.
+func (aliases Aliases) Map(header []string) (map[string]int, error) {
+	byName := make(map[string]string)
+	for _, column := range aliases.columns() {
+		for _, name := range aliases[column] {
+			byName[normalizeHeader(name)] = column
+		}
+	}
+	return mapHeader(byName, header, REQUIRED_COLUMNS)
+}
+
+// mapHeader returns the position in header of the columns named in byName, keyed by normalized header name
+func mapHeader(byName map[string]string, header []string, required []string) (map[string]int, error) {
+	indexes := make(map[string]int)
+	for i, name := range header {
+		column, ok := byName[normalizeHeader(name)]
+		if !ok {
+			continue
+		}
+		if j, ok := indexes[column]; ok {
+			return nil, fmt.Errorf("%w %s, named %q and %q", ErrDuplicateColumns, column, header[j], name)
+		}
+		indexes[column] = i
+	}
+	var missing []string
+	for _, column := range required {
+		if _, ok := indexes[column]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w %s in header %q", ErrMissingColumns, strings.Join(missing, ", "), header)
+	}
+	return indexes, nil
+}
+
+// rowWidth returns the number of fields a row needs to hold all of columns
+func rowWidth(columns map[string]int) int {
+	width := 0
+	for _, i := range columns {
+		width = max(width, i+1)
+	}
+	return width
+}
+
+func (aliases Aliases) columns() []string {
+	var columns []string
+	for column := range aliases {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// normalizeHeader lowercases name, drops a byte order mark and treats spaces, underscores and hyphens alike
+func normalizeHeader(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff")
+	name = strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(name))
+	return strings.Join(strings.Fields(name), " ")
+}
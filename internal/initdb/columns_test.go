@@ -0,0 +1,80 @@
+package initdb
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAliasesMap(t *testing.T) {
+	tt := []struct {
+		header  []string
+		want    map[string]int
+		wantErr error
+	}{
+		{
+			[]string{"COUNTRY ISO2 CODE", "SWIFT CODE", "CODE TYPE", "NAME", "ADDRESS", "TOWN NAME", "COUNTRY NAME", "TIME ZONE"},
+			map[string]int{COLUMN_COUNTRY_ISO2: 0, COLUMN_SWIFT_CODE: 1, COLUMN_BANK_NAME: 3, COLUMN_ADDRESS: 4, COLUMN_COUNTRY_NAME: 6},
+			nil,
+		},
+		{
+			[]string{"bic11", "Bank_Name", "country-name", "\ufeffiso2"},
+			map[string]int{COLUMN_SWIFT_CODE: 0, COLUMN_BANK_NAME: 1, COLUMN_COUNTRY_NAME: 2, COLUMN_COUNTRY_ISO2: 3},
+			nil,
+		},
+		{
+			[]string{"  BIC  ", "NAME", "COUNTRY", "COUNTRY CODE", "EXTRA"},
+			map[string]int{COLUMN_SWIFT_CODE: 0, COLUMN_BANK_NAME: 1, COLUMN_COUNTRY_NAME: 2, COLUMN_COUNTRY_ISO2: 3},
+			nil,
+		},
+		{[]string{"SWIFT CODE", "NAME", "ADDRESS"}, nil, ErrMissingColumns},
+		{[]string{"SWIFT CODE", "BIC", "NAME", "COUNTRY", "ISO2"}, nil, ErrDuplicateColumns},
+	}
+	for i := 0; i < len(tt); i++ {
+		got, err := DefaultAliases().Map(tt[i].header)
+		if !errors.Is(err, tt[i].wantErr) || !reflect.DeepEqual(got, tt[i].want) {
+			t.Errorf(`Map("%v") = %v, %v, want %v, %v`, tt[i].header, got, err, tt[i].want, tt[i].wantErr)
+		}
+	}
+}
+
+func TestAliasesAdd(t *testing.T) {
+	aliases := DefaultAliases()
+	if err := aliases.Add(COLUMN_SWIFT_CODE, "Bank Identifier Code"); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	if err := aliases.Add("town", "TOWN NAME"); err == nil {
+		t.Errorf(`Add("town") error = nil, want unknown column error`)
+	}
+	got, err := aliases.Map([]string{"BANK IDENTIFIER CODE", "NAME", "COUNTRY", "ISO2"})
+	if err != nil || got[COLUMN_SWIFT_CODE] != 0 {
+		t.Errorf("Map() with added alias = %v, %v, want swift_code at 0", got, err)
+	}
+}
+
+func TestReaderColumns(t *testing.T) {
+	content := "Name,BIC,Country,ISO2\n" +
+		"BANK MILLENNIUM S.A.,BIGBPLPWXXX,Poland,pl\n" +
+		"SHORT,BIGBPLPWCUS\n"
+	reader := NewReader(strings.NewReader(content), ',')
+	record, err := reader.Read()
+	want := Record{Line: 2, CountryName: "POLAND"}
+	want.Code.SwiftCode = "BIGBPLPWXXX"
+	want.Code.BankName = "BANK MILLENNIUM S.A."
+	want.Code.CountryISO2 = "PL"
+	if err != nil || !reflect.DeepEqual(record, want) {
+		t.Errorf("Read() = %+v, %v, want %+v", record, err, want)
+	}
+	var rowErr *RowError
+	if _, err := reader.Read(); !errors.As(err, &rowErr) || rowErr.Message != "has 2 columns, want at least 4" {
+		t.Errorf("Read() of short row = %v, want row error", err)
+	}
+
+	reader = NewReader(strings.NewReader("SWIFT CODE,NAME\nBIGBPLPWXXX,BANK\n"), ',')
+	for i := 0; i < 2; i++ {
+		if _, err := reader.Read(); !errors.Is(err, ErrMissingColumns) {
+			t.Errorf("Read() %d with missing columns = %v, want %v", i, err, ErrMissingColumns)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package initdb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"swiftcodes/sqlcout"
+)
+
+// Policies for import values that conflict with values corrected through the API
+const (
+	POLICY_KEEP_MANUAL   = "keep-manual"
+	POLICY_PREFER_IMPORT = "prefer-import"
+	POLICY_FAIL          = "fail"
+)
+
+var POLICIES = []string{POLICY_KEEP_MANUAL, POLICY_PREFER_IMPORT, POLICY_FAIL}
+
+// Fields that can be corrected through the API
+const (
+	FIELD_ADDRESS   = "address"
+	FIELD_BANK_NAME = "bankName"
+)
+
+var ErrConflicts = errors.New("import conflicts with values corrected through the API")
+
+// Change is a code whose stored details differ from those in an import file
+type Change struct {
+	Old sqlcout.ListSwiftCodesRow
+	New sqlcout.InsertSwiftCodeParams
+}
+
+// Conflict is a field corrected through the API that an import file would change, or remove with its code
+type Conflict struct {
+	SwiftCode string `json:"swiftCode"`
+	Field     string `json:"field"`
+	Manual    string `json:"manual"`
+	Import    string `json:"import"`
+	Removed   bool   `json:"removed"`
+}
+
+// Diff lists how the codes of an import file differ from the stored ones, each list sorted by code
+type Diff struct {
+	Added     []sqlcout.InsertSwiftCodeParams
+	Changed   []Change
+	Removed   []sqlcout.InsertSwiftCodeParams
+	Conflicts []Conflict
+}
+
+// Empty reports whether the file matches the database
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// DiffCodes compares the stored codes with those of a file, matching codes case-insensitively like the database does
+func DiffCodes(stored []sqlcout.ListSwiftCodesRow, incoming []sqlcout.InsertSwiftCodeParams) Diff {
+	var diff Diff
+	current := make(map[string]sqlcout.ListSwiftCodesRow)
+	for _, row := range stored {
+		current[strings.ToUpper(row.SwiftCode)] = row
+	}
+	seen := make(map[string]bool)
+	for _, code := range incoming {
+		key := strings.ToUpper(code.SwiftCode)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		old, ok := current[key]
+		if !ok {
+			diff.Added = append(diff.Added, code)
+		} else if changed(old, code) {
+			diff.Changed = append(diff.Changed, Change{Old: old, New: code})
+			if old.AddressOverride && old.Address != code.Address {
+				diff.Conflicts = append(diff.Conflicts, Conflict{old.SwiftCode, FIELD_ADDRESS, old.Address, code.Address, false})
+			}
+			if old.BankNameOverride && old.BankName != code.BankName {
+				diff.Conflicts = append(diff.Conflicts, Conflict{old.SwiftCode, FIELD_BANK_NAME, old.BankName, code.BankName, false})
+			}
+		}
+	}
+	for key, row := range current {
+		if !seen[key] {
+			diff.Removed = append(diff.Removed, sqlcout.InsertSwiftCodeParams{
+				SwiftCode:   row.SwiftCode,
+				Address:     row.Address,
+				BankName:    row.BankName,
+				CountryISO2: row.CountryISO2,
+			})
+			if row.AddressOverride {
+				diff.Conflicts = append(diff.Conflicts, Conflict{row.SwiftCode, FIELD_ADDRESS, row.Address, "", true})
+			}
+			if row.BankNameOverride {
+				diff.Conflicts = append(diff.Conflicts, Conflict{row.SwiftCode, FIELD_BANK_NAME, row.BankName, "", true})
+			}
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].SwiftCode < diff.Added[j].SwiftCode })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].New.SwiftCode < diff.Changed[j].New.SwiftCode })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].SwiftCode < diff.Removed[j].SwiftCode })
+	sort.SliceStable(diff.Conflicts, func(i, j int) bool { return diff.Conflicts[i].SwiftCode < diff.Conflicts[j].SwiftCode })
+	return diff
+}
+
+// changed reports whether the details of code differ from the stored row
+func changed(row sqlcout.ListSwiftCodesRow, code sqlcout.InsertSwiftCodeParams) bool {
+	return row.Address != code.Address || row.BankName != code.BankName || !strings.EqualFold(row.CountryISO2, code.CountryISO2)
+}
+
+// Resolve applies policy to the conflicts of d, which stay listed for review. POLICY_KEEP_MANUAL keeps the
+// corrected values, dropping changes left with nothing to change and keeping corrected codes the file
+// removes, POLICY_PREFER_IMPORT takes the values of the file and POLICY_FAIL returns ErrConflicts if there
+// are any.
+func (d Diff) Resolve(policy string) (Diff, error) {
+	switch policy {
+	case POLICY_PREFER_IMPORT:
+		return d, nil
+	case POLICY_FAIL:
+		if len(d.Conflicts) > 0 {
+			return d, fmt.Errorf("%w: %d conflicts", ErrConflicts, len(d.Conflicts))
+		}
+		return d, nil
+	case POLICY_KEEP_MANUAL:
+	default:
+		return d, fmt.Errorf("unknown conflict policy %q, want one of %s", policy, strings.Join(POLICIES, ", "))
+	}
+
+	resolved := d
+	resolved.Changed = nil
+	for _, change := range d.Changed {
+		if change.Old.AddressOverride {
+			change.New.Address = change.Old.Address
+		}
+		if change.Old.BankNameOverride {
+			change.New.BankName = change.Old.BankName
+		}
+		if changed(change.Old, change.New) {
+			resolved.Changed = append(resolved.Changed, change)
+		}
+	}
+	corrected := make(map[string]bool)
+	for _, conflict := range d.Conflicts {
+		if conflict.Removed {
+			corrected[conflict.SwiftCode] = true
+		}
+	}
+	resolved.Removed = nil
+	for _, code := range d.Removed {
+		if !corrected[code.SwiftCode] {
+			resolved.Removed = append(resolved.Removed, code)
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,63 @@
+package initdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"swiftcodes/sqlcout"
+)
+
+func TestResolve(t *testing.T) {
+	stored := []sqlcout.ListSwiftCodesRow{
+		{SwiftCode: "AKBKMTMTXXX", Address: "TOWER, LEVEL 5", BankName: "AKBANK", CountryISO2: "MT", AddressOverride: true, Actor: "ops"},
+		{SwiftCode: "BIGBPLPWXXX", Address: "HARMONY CENTER", BankName: "MILLENNIUM", CountryISO2: "PL", BankNameOverride: true, Actor: "ops"},
+		{SwiftCode: "BIGBPLPWCUS", Address: "HARMONY CENTER", BankName: "MILLENNIUM", CountryISO2: "PL", AddressOverride: true, Actor: "ops"},
+		{SwiftCode: "BPKOPLPWXXX", Address: "PULAWSKA", BankName: "PKO", CountryISO2: "PL"},
+	}
+	incoming := []sqlcout.InsertSwiftCodeParams{
+		{SwiftCode: "AKBKMTMTXXX", Address: "TOWER", BankName: "AKBANK", CountryISO2: "MT"},
+		{SwiftCode: "BIGBPLPWXXX", Address: "HARMONY CENTER 2A", BankName: "BANK MILLENNIUM", CountryISO2: "PL"},
+	}
+	diff := DiffCodes(stored, incoming)
+	wantConflicts := []Conflict{
+		{"AKBKMTMTXXX", FIELD_ADDRESS, "TOWER, LEVEL 5", "TOWER", false},
+		{"BIGBPLPWCUS", FIELD_ADDRESS, "HARMONY CENTER", "", true},
+		{"BIGBPLPWXXX", FIELD_BANK_NAME, "MILLENNIUM", "BANK MILLENNIUM", false},
+	}
+	if !reflect.DeepEqual(diff.Conflicts, wantConflicts) {
+		t.Fatalf("DiffCodes() conflicts = %+v, want %+v", diff.Conflicts, wantConflicts)
+	}
+
+	corrected := sqlcout.InsertSwiftCodeParams{SwiftCode: "BIGBPLPWCUS", Address: "HARMONY CENTER", BankName: "MILLENNIUM", CountryISO2: "PL"}
+	uncorrected := sqlcout.InsertSwiftCodeParams{SwiftCode: "BPKOPLPWXXX", Address: "PULAWSKA", BankName: "PKO", CountryISO2: "PL"}
+
+	tt := []struct {
+		policy      string
+		wantErr     error
+		wantChanged []sqlcout.InsertSwiftCodeParams
+		wantRemoved []sqlcout.InsertSwiftCodeParams
+	}{
+		{POLICY_PREFER_IMPORT, nil, incoming, []sqlcout.InsertSwiftCodeParams{corrected, uncorrected}},
+		{POLICY_KEEP_MANUAL, nil, []sqlcout.InsertSwiftCodeParams{
+			{SwiftCode: "BIGBPLPWXXX", Address: "HARMONY CENTER 2A", BankName: "MILLENNIUM", CountryISO2: "PL"},
+		}, []sqlcout.InsertSwiftCodeParams{uncorrected}},
+		{POLICY_FAIL, ErrConflicts, incoming, []sqlcout.InsertSwiftCodeParams{corrected, uncorrected}},
+	}
+	for i := 0; i < len(tt); i++ {
+		resolved, err := diff.Resolve(tt[i].policy)
+		var changed []sqlcout.InsertSwiftCodeParams
+		for _, change := range resolved.Changed {
+			changed = append(changed, change.New)
+		}
+		if !errors.Is(err, tt[i].wantErr) || !reflect.DeepEqual(changed, tt[i].wantChanged) || len(resolved.Conflicts) != 3 {
+			t.Errorf(`Resolve("%v") = %+v, %v, want %+v, %v`, tt[i].policy, changed, err, tt[i].wantChanged, tt[i].wantErr)
+		}
+		if !reflect.DeepEqual(resolved.Removed, tt[i].wantRemoved) {
+			t.Errorf(`Resolve("%v") removed = %+v, want %+v`, tt[i].policy, resolved.Removed, tt[i].wantRemoved)
+		}
+	}
+	if _, err := diff.Resolve("newest"); err == nil {
+		t.Errorf(`Resolve("newest") = nil error, want an unknown policy`)
+	}
+}
@@ -0,0 +1,176 @@
+package initdb
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"swiftcodes/sqlcout"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// HEADER is the header row of the import file format
+var HEADER = []string{"COUNTRY ISO2 CODE", "SWIFT CODE", "CODE TYPE", "NAME", "ADDRESS", "TOWN NAME", "COUNTRY NAME", "TIME ZONE"}
+
+// Export file formats
+const (
+	EXPORT_TSV   = "tsv"
+	EXPORT_CSV   = "csv"
+	EXPORT_JSONL = "jsonl"
+	EXPORT_XLSX  = "xlsx"
+)
+
+var EXPORT_FORMATS = []string{EXPORT_TSV, EXPORT_CSV, EXPORT_JSONL, EXPORT_XLSX}
+
+// EXPORT_CONTENT_TYPES are the media types of the export formats
+var EXPORT_CONTENT_TYPES = map[string]string{
+	EXPORT_TSV:   "text/tab-separated-values; charset=utf-8",
+	EXPORT_CSV:   "text/csv; charset=utf-8",
+	EXPORT_JSONL: "application/jsonl; charset=utf-8",
+	EXPORT_XLSX:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// xlsxSheet is the name of the worksheet holding the codes in XLSX exports
+const xlsxSheet = "Sheet1"
+
+// ExportWriter writes codes one at a time. Close must be called after the last code to complete the file,
+// or Discard if the export failed, to free the writer without completing it.
+type ExportWriter interface {
+	Write(code sqlcout.ListSwiftCodesRow) error
+	Close() error
+	Discard()
+}
+
+// NewExportWriter returns a writer of format to w. The tsv and csv formats are the import file format,
+// jsonl writes a JSON object per line and xlsx a worksheet with the columns of the import file format.
+func NewExportWriter(w io.Writer, format string) (ExportWriter, error) {
+	switch format {
+	case EXPORT_TSV:
+		return newCSVExportWriter(w, '\t')
+	case EXPORT_CSV:
+		return newCSVExportWriter(w, ',')
+	case EXPORT_JSONL:
+		return &jsonlExportWriter{json.NewEncoder(w)}, nil
+	case EXPORT_XLSX:
+		return newXLSXExportWriter(w)
+	}
+	return nil, fmt.Errorf("unsupported format %q, want one of %s", format, strings.Join(EXPORT_FORMATS, ", "))
+}
+
+// exportRow returns the columns of HEADER for code. The town name and time zone aren't stored, so those
+// columns are left empty.
+func exportRow(code sqlcout.ListSwiftCodesRow) []string {
+	return []string{code.CountryISO2, code.SwiftCode, "BIC11", code.BankName, code.Address, "", code.CountryName, ""}
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer, comma rune) (*csvExportWriter, error) {
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Comma = comma
+	csvWriter.UseCRLF = true
+	if err := csvWriter.Write(HEADER); err != nil {
+		return nil, err
+	}
+	return &csvExportWriter{csvWriter}, nil
+}
+
+func (w *csvExportWriter) Write(code sqlcout.ListSwiftCodesRow) error {
+	return w.writer.Write(exportRow(code))
+}
+
+func (w *csvExportWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvExportWriter) Discard() {}
+
+// exportRecord is a code in JSON Lines exports
+type exportRecord struct {
+	SwiftCode   string `json:"swiftCode"`
+	BankName    string `json:"bankName"`
+	Address     string `json:"address"`
+	CountryISO2 string `json:"countryISO2"`
+	CountryName string `json:"countryName"`
+}
+
+type jsonlExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlExportWriter) Write(code sqlcout.ListSwiftCodesRow) error {
+	return w.encoder.Encode(exportRecord{
+		SwiftCode:   code.SwiftCode,
+		BankName:    code.BankName,
+		Address:     code.Address,
+		CountryISO2: code.CountryISO2,
+		CountryName: code.CountryName,
+	})
+}
+
+func (w *jsonlExportWriter) Close() error {
+	return nil
+}
+
+func (w *jsonlExportWriter) Discard() {}
+
+// xlsxExportWriter writes the rows with a stream writer, which moves them from memory to a temporary file
+// as the sheet grows. The workbook is written to w on Close.
+type xlsxExportWriter struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXExportWriter(w io.Writer) (*xlsxExportWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	writer := &xlsxExportWriter{w: w, file: file, stream: stream}
+	if err := writer.writeRow(HEADER); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *xlsxExportWriter) Write(code sqlcout.ListSwiftCodesRow) error {
+	return w.writeRow(exportRow(code))
+}
+
+func (w *xlsxExportWriter) writeRow(columns []string) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = column
+	}
+	return w.stream.SetRow(cell, values)
+}
+
+func (w *xlsxExportWriter) Close() error {
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := w.file.WriteTo(w.w)
+	return err
+}
+
+// Discard removes the temporary files of the rows without writing the workbook
+func (w *xlsxExportWriter) Discard() {
+	w.file.Close()
+}
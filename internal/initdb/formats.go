@@ -0,0 +1,46 @@
+package initdb
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Import file formats
+const (
+	// FORMAT_CSV is this project's format, tab separated for .tsv files and comma separated otherwise
+	FORMAT_CSV           = "csv"
+	FORMAT_BIC_PLUS      = "bicplus"
+	FORMAT_BIC_DIRECTORY = "bicdir2018"
+	FORMAT_ISO20022      = "iso20022"
+)
+
+var FORMATS = []string{FORMAT_CSV, FORMAT_BIC_PLUS, FORMAT_BIC_DIRECTORY, FORMAT_ISO20022}
+
+// NewFormatReader returns a reader of the file at path in format, read from r. Aliases only apply to FORMAT_CSV.
+func NewFormatReader(r io.Reader, path string, format string, aliases Aliases) (RecordReader, error) {
+	switch format {
+	case FORMAT_CSV:
+		reader := NewReader(r, Comma(path))
+		reader.Aliases = aliases
+		return reader, nil
+	case FORMAT_BIC_PLUS:
+		return NewBICPlusReader(r), nil
+	case FORMAT_BIC_DIRECTORY:
+		return NewBICDirectoryReader(r), nil
+	case FORMAT_ISO20022:
+		return NewXMLReader(r), nil
+	}
+	return nil, fmt.Errorf("unsupported format %q, want one of %s", format, strings.Join(FORMATS, ", "))
+}
+
+// joinNonEmpty joins the parts that aren't blank with sep, trimming each
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, sep)
+}
@@ -0,0 +1,90 @@
+package initdb
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"swiftcodes/sqlcout"
+)
+
+func TestFormatReaders(t *testing.T) {
+	millennium := sqlcout.InsertSwiftCodeParams{SwiftCode: "BIGBPLPWXXX", BankName: "BANK MILLENNIUM S.A.", CountryISO2: "PL"}
+	custody := sqlcout.InsertSwiftCodeParams{SwiftCode: "BIGBPLPWCUS", BankName: "BANK MILLENNIUM S.A.", CountryISO2: "PL"}
+	akbank := sqlcout.InsertSwiftCodeParams{SwiftCode: "AKBKMTMTXXX", BankName: "AKBANK T.A.S. (MALTA BRANCH)", CountryISO2: "MT"}
+	with := func(code sqlcout.InsertSwiftCodeParams, address string) sqlcout.InsertSwiftCodeParams {
+		code.Address = address
+		return code
+	}
+
+	tt := []struct {
+		file       string
+		format     string
+		wantCodes  []sqlcout.InsertSwiftCodeParams
+		wantLines  []int
+		wantErrors []RowError
+	}{
+		{"bicplus.txt", FORMAT_BIC_PLUS,
+			[]sqlcout.InsertSwiftCodeParams{
+				with(millennium, "HARMONY CENTER UL. STANISLAWA ZARYNA 2A, WARSZAWA, 02-593"),
+				with(custody, "UL. STANISLAWA ZARYNA 2A, WARSZAWA"),
+				with(akbank, "PORTOMASO BUSINESS TOWER, ST. JULIAN'S, STJ 4011"),
+			},
+			[]int{2, 3, 5},
+			[]RowError{{Line: 6, SwiftCode: "AKBKMTXXX", Message: `invalid BIC "AKBKMTXXX": must be 8 or 11 characters long`}},
+		},
+		{"bicdir2018.dat", FORMAT_BIC_DIRECTORY,
+			[]sqlcout.InsertSwiftCodeParams{
+				with(millennium, "HARMONY CENTER UL. STANISLAWA ZARYNA 2A, 02-593 WARSZAWA"),
+				with(custody, "UL. STANISLAWA ZARYNA 2A, WARSZAWA"),
+				with(akbank, "PORTOMASO BUSINESS TOWER, STJ 4011 ST. JULIAN'S"),
+			},
+			[]int{2, 3, 5},
+			[]RowError{{Line: 6, Message: "has 20 characters, want at least 26"}},
+		},
+		{"iso20022.xml", FORMAT_ISO20022,
+			[]sqlcout.InsertSwiftCodeParams{
+				with(millennium, "HARMONY CENTER UL. STANISLAWA ZARYNA 2A, WARSZAWA, 02-593"),
+				with(custody, "WARSZAWA"),
+				with(akbank, "PORTOMASO BUSINESS TOWER, ST. JULIAN'S"),
+			},
+			[]int{3, 18, 29},
+			[]RowError{{Line: 41, SwiftCode: "AKBKMTMTXXX", Message: "bank name is empty"}},
+		},
+	}
+	for i := 0; i < len(tt); i++ {
+		f, err := os.Open(filepath.Join("testdata", tt[i].file))
+		if err != nil {
+			t.Fatalf("error opening fixture: %v", err)
+		}
+		defer f.Close()
+		reader, err := NewFormatReader(f, tt[i].file, tt[i].format, DefaultAliases())
+		if err != nil {
+			t.Fatalf(`NewFormatReader("%v") error = %v`, tt[i].format, err)
+		}
+		records, report, err := ReadAll(reader, -1)
+		if err != nil {
+			t.Errorf(`ReadAll("%v") error = %v`, tt[i].file, err)
+		}
+		codes, countries := Split(records)
+		var lines []int
+		for _, record := range records {
+			lines = append(lines, record.Line)
+		}
+		if !reflect.DeepEqual(codes, tt[i].wantCodes) || !reflect.DeepEqual(lines, tt[i].wantLines) {
+			t.Errorf(`ReadAll("%v") = %+v on lines %v, want %+v on lines %v`, tt[i].file, codes, lines, tt[i].wantCodes, tt[i].wantLines)
+		}
+		wantCountries := []sqlcout.InsertCountryParams{{CountryISO2: "PL", CountryName: "POLAND"}, {CountryISO2: "MT", CountryName: "MALTA"}}
+		if !reflect.DeepEqual(countries, wantCountries) {
+			t.Errorf(`ReadAll("%v") countries = %+v, want %+v`, tt[i].file, countries, wantCountries)
+		}
+		if !reflect.DeepEqual(report.Errors, tt[i].wantErrors) {
+			t.Errorf(`ReadAll("%v") errors = %+v, want %+v`, tt[i].file, report.Errors, tt[i].wantErrors)
+		}
+	}
+
+	if _, err := NewFormatReader(nil, "file.txt", "fixed", DefaultAliases()); err == nil {
+		t.Errorf(`NewFormatReader("fixed") error = nil, want unsupported format`)
+	}
+}
@@ -0,0 +1,302 @@
+package initdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"swiftcodes/internal/bic"
+	"swiftcodes/internal/store"
+	"swiftcodes/sqlcout"
+)
+
+// ErrTooManyErrors stops an import once more rows failed than its error budget allows
+var ErrTooManyErrors = errors.New("too many row errors")
+
+// Record is a validated row of an import file
+type Record struct {
+	Line        int
+	Code        sqlcout.InsertSwiftCodeParams
+	CountryName string
+}
+
+// RecordReader reads the records of an import file in one of the supported formats
+type RecordReader interface {
+	// Read returns the next valid record, or io.EOF at the end of the file. Rows that are malformed or
+	// invalid are returned as a *RowError, after which reading can go on.
+	Read() (Record, error)
+}
+
+// validate returns a *RowError unless record can be inserted
+func (record Record) validate() error {
+	fail := func(message string) error {
+		return &RowError{Line: record.Line, SwiftCode: record.Code.SwiftCode, Message: message}
+	}
+	if err := bic.Validate(record.Code.SwiftCode); err != nil {
+		return fail(err.Error())
+	}
+	if len(record.Code.CountryISO2) != 2 || strings.Trim(record.Code.CountryISO2, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return fail(fmt.Sprintf("country ISO2 code %q must be 2 letters", record.Code.CountryISO2))
+	}
+	if strings.TrimSpace(record.Code.BankName) == "" {
+		return fail("bank name is empty")
+	}
+	if strings.TrimSpace(record.CountryName) == "" {
+		return fail("country name is empty")
+	}
+	return nil
+}
+
+// RowError describes a row that couldn't be read, validated or stored
+type RowError struct {
+	Line      int    `json:"line"`
+	SwiftCode string `json:"swiftCode,omitempty"`
+	Message   string `json:"error"`
+}
+
+func (e *RowError) Error() string {
+	if e.SwiftCode == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.SwiftCode, e.Message)
+}
+
+// Report summarizes an import, Rows counting the data rows read
+type Report struct {
+	Rows          int        `json:"rows"`
+	Imported      int        `json:"imported"`
+	Failed        int        `json:"failed"`
+	Aborted       bool       `json:"aborted"`
+	Seconds       float64    `json:"seconds"`
+	RowsPerSecond float64    `json:"rowsPerSecond"`
+	Errors        []RowError `json:"errors"`
+}
+
+// finish records the throughput of an import that began at start
+func (report *Report) finish(start time.Time) {
+	report.Seconds = time.Since(start).Seconds()
+	if report.Seconds > 0 {
+		report.RowsPerSecond = float64(report.Imported) / report.Seconds
+	}
+}
+
+// fail records err and returns ErrTooManyErrors once more than maxErrors rows failed, unless maxErrors is negative
+func (report *Report) fail(err *RowError, maxErrors int) error {
+	report.Failed++
+	report.Errors = append(report.Errors, *err)
+	if maxErrors >= 0 && report.Failed > maxErrors {
+		report.Aborted = true
+		return ErrTooManyErrors
+	}
+	return nil
+}
+
+// Reader streams the rows of a CSV or TSV import file in this project's format one at a time, finding its columns by the header
+type Reader struct {
+	// Aliases are the header names of the columns, DefaultAliases unless changed before the first Read
+	Aliases Aliases
+
+	csv     *csv.Reader
+	columns map[string]int
+	// width is the number of fields a row needs to hold every mapped column
+	width int
+	err   error
+}
+
+func NewReader(r io.Reader, comma rune) *Reader {
+	csvReader := csv.NewReader(r)
+	csvReader.Comma = comma
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+	return &Reader{Aliases: DefaultAliases(), csv: csvReader}
+}
+
+// Comma returns the separator of the file at path, a tab for .tsv files and a comma otherwise
+func Comma(path string) rune {
+	if strings.HasSuffix(strings.ToLower(path), ".tsv") {
+		return '\t'
+	}
+	return ','
+}
+
+// Read returns the next valid record, or io.EOF at the end of the file. Rows that are malformed or invalid
+// are returned as a *RowError, after which reading can go on. A header lacking required columns fails
+// every Read with ErrMissingColumns.
+func (r *Reader) Read() (Record, error) {
+	if r.err != nil {
+		return Record{}, r.err
+	}
+	if r.columns == nil {
+		header, err := r.csv.Read()
+		if err == nil {
+			r.columns, err = r.Aliases.Map(header)
+		}
+		if err != nil {
+			r.err = fmt.Errorf("invalid header: %w", err)
+			if err == io.EOF {
+				r.err = err
+			}
+			return Record{}, r.err
+		}
+		r.width = rowWidth(r.columns)
+	}
+	row, err := r.csv.Read()
+	if err != nil {
+		return Record{}, readError(err)
+	}
+	line, _ := r.csv.FieldPos(0)
+	return r.parseRow(line, row)
+}
+
+func readError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &RowError{Line: parseErr.StartLine, Message: parseErr.Err.Error()}
+	}
+	return err
+}
+
+// parseRow validates a row and converts it to a record
+func (r *Reader) parseRow(line int, row []string) (Record, error) {
+	if len(row) < r.width {
+		return Record{}, &RowError{Line: line, Message: fmt.Sprintf("has %d columns, want at least %d", len(row), r.width)}
+	}
+	record := Record{
+		Line:        line,
+		Code:        toCode(r.columns, row),
+		CountryName: strings.ToUpper(row[r.columns[COLUMN_COUNTRY_NAME]]),
+	}
+	if err := record.validate(); err != nil {
+		return Record{}, err
+	}
+	return record, nil
+}
+
+// toCode reads the code in row from the mapped columns
+func toCode(columns map[string]int, row []string) sqlcout.InsertSwiftCodeParams {
+	code := sqlcout.InsertSwiftCodeParams{
+		SwiftCode:   row[columns[COLUMN_SWIFT_CODE]],
+		BankName:    row[columns[COLUMN_BANK_NAME]],
+		CountryISO2: strings.ToUpper(row[columns[COLUMN_COUNTRY_ISO2]]),
+	}
+	if i, ok := columns[COLUMN_ADDRESS]; ok {
+		code.Address = row[i]
+	}
+	return code
+}
+
+// ReadAll reads all records of reader, stopping with ErrTooManyErrors like Import
+func ReadAll(reader RecordReader, maxErrors int) ([]Record, Report, error) {
+	var records []Record
+	report := Report{Errors: []RowError{}}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, report, nil
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			report.Rows++
+			if err := report.fail(rowErr, maxErrors); err != nil {
+				return records, report, err
+			}
+			continue
+		}
+		if err != nil {
+			return records, report, err
+		}
+		report.Rows++
+		records = append(records, record)
+	}
+}
+
+// Split returns the codes of records and their countries, named as in the first record of each country
+func Split(records []Record) ([]sqlcout.InsertSwiftCodeParams, []sqlcout.InsertCountryParams) {
+	var codes []sqlcout.InsertSwiftCodeParams
+	var countries []sqlcout.InsertCountryParams
+	seen := make(map[string]bool)
+	for _, record := range records {
+		codes = append(codes, record.Code)
+		if !seen[record.Code.CountryISO2] {
+			seen[record.Code.CountryISO2] = true
+			countries = append(countries, sqlcout.InsertCountryParams{CountryISO2: record.Code.CountryISO2, CountryName: record.CountryName})
+		}
+	}
+	return codes, countries
+}
+
+// Import streams the records of reader into the database, adding their countries when missing.
+// Rows that fail are collected in the report until more than maxErrors failed, which stops the import
+// with ErrTooManyErrors; a negative maxErrors allows any number. Rows imported before stopping are kept.
+// Errors meaning the database can't be used stop the import right away.
+// The import is recorded as batch first, which the imported codes reference as their source.
+func Import(ctx context.Context, queries store.Store, reader RecordReader, maxErrors int, batch sqlcout.InsertImportParams) (Report, error) {
+	start := time.Now()
+	if _, err := queries.InsertImport(ctx, batch); err != nil {
+		return Report{Errors: []RowError{}, Aborted: true}, fmt.Errorf("couldn't record import: %w", err)
+	}
+	report, err := importRecords(ctx, queries, reader, maxErrors, batchID(batch))
+	report.finish(start)
+	return report, err
+}
+
+func importRecords(ctx context.Context, queries store.Store, reader RecordReader, maxErrors int, importID sql.NullString) (Report, error) {
+	report := Report{Errors: []RowError{}}
+	countries := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return report, nil
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			report.Rows++
+			if err := report.fail(rowErr, maxErrors); err != nil {
+				return report, err
+			}
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Rows++
+
+		record.Code.ImportID = importID
+		err = addCountry(ctx, queries, countries, record)
+		if err == nil {
+			_, err = queries.InsertSwiftCode(ctx, record.Code)
+		}
+		if errors.Is(err, store.ErrUnavailable) || errors.Is(err, store.ErrTimeout) || ctx.Err() != nil {
+			report.Aborted = true
+			return report, err
+		}
+		if err != nil {
+			if err := report.fail(&RowError{Line: record.Line, SwiftCode: record.Code.SwiftCode, Message: err.Error()}, maxErrors); err != nil {
+				return report, err
+			}
+			continue
+		}
+		report.Imported++
+	}
+}
+
+// addCountry inserts the country of record unless it is stored already, remembering the countries seen in known
+func addCountry(ctx context.Context, queries store.Store, known map[string]bool, record Record) error {
+	if known[record.Code.CountryISO2] {
+		return nil
+	}
+	_, err := queries.GetCountry(ctx, record.Code.CountryISO2)
+	if errors.Is(err, store.ErrNotFound) {
+		_, err = queries.InsertCountry(ctx, sqlcout.InsertCountryParams{CountryISO2: record.Code.CountryISO2, CountryName: record.CountryName})
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't add country %s: %w", record.Code.CountryISO2, err)
+	}
+	known[record.Code.CountryISO2] = true
+	return nil
+}
@@ -0,0 +1,99 @@
+package initdb
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const HEADER_ROW = "COUNTRY ISO2 CODE\tSWIFT CODE\tCODE TYPE\tNAME\tADDRESS\tTOWN NAME\tCOUNTRY NAME\tTIME ZONE\n"
+
+func TestReaderRowErrors(t *testing.T) {
+	content := HEADER_ROW +
+		"pl\tBIGBPLPWXXX\tBIC11\tBANK MILLENNIUM S.A.\tWARSZAWA\tWARSZAWA\tpoland\tEurope/Warsaw\n" +
+		"PL\tBIGBPLPW\n" +
+		"PL\tbigbplpwcus\tBIC11\tBANK\tWARSZAWA\tWARSZAWA\tPOLAND\tEurope/Warsaw\n" +
+		"P1\tBIGBPLPWCUS\tBIC11\tBANK\tWARSZAWA\tWARSZAWA\tPOLAND\tEurope/Warsaw\n" +
+		"PL\tBIGBPLPWCUS\tBIC11\t \tWARSZAWA\tWARSZAWA\tPOLAND\tEurope/Warsaw\n" +
+		"PL\tBIGBPLPWCUS\tBIC11\tBANK \"MILLENNIUM\tWARSZAWA\tWARSZAWA\tPOLAND\tEurope/Warsaw\n" +
+		"PL\tBIGBPLPWXYZ\tBIC11\tBANK\t\"ONE\nTWO\"\tWARSZAWA\tPOLAND\tEurope/Warsaw\n" +
+		"MT\tAKBKMTMTXXX\tBIC11\tAKBANK\tPORTOMASO\tST. JULIAN'S\tMALTA\tEurope/Malta\n"
+
+	tt := []struct {
+		line      int
+		swiftCode string
+		wantError bool
+	}{
+		{2, "BIGBPLPWXXX", false},
+		{3, "", true},
+		{4, "bigbplpwcus", true},
+		{5, "BIGBPLPWCUS", true},
+		{6, "BIGBPLPWCUS", true},
+		{7, "", true},
+		{8, "BIGBPLPWXYZ", false},
+		{10, "AKBKMTMTXXX", false},
+	}
+
+	reader := NewReader(strings.NewReader(content), '\t')
+	for i := 0; i < len(tt); i++ {
+		record, err := reader.Read()
+		var rowErr *RowError
+		if tt[i].wantError {
+			if !errors.As(err, &rowErr) || rowErr.Line != tt[i].line || rowErr.SwiftCode != tt[i].swiftCode {
+				t.Errorf("Read() row %d = %+v, %v, want error on line %d for %q", i, record, err, tt[i].line, tt[i].swiftCode)
+			}
+			continue
+		}
+		if err != nil || record.Line != tt[i].line || record.Code.SwiftCode != tt[i].swiftCode {
+			t.Errorf("Read() row %d = %+v, %v, want line %d with %q", i, record, err, tt[i].line, tt[i].swiftCode)
+		}
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("Read() at end = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestReadAllErrorBudget(t *testing.T) {
+	content := HEADER_ROW +
+		"PL\tBIGBPLPWXXX\tBIC11\tBANK\tWARSZAWA\tWARSZAWA\tPOLAND\tEurope/Warsaw\n" +
+		"PL\tBAD\n" +
+		"PL\tWORSE\n" +
+		"MT\tAKBKMTMTXXX\tBIC11\tAKBANK\tPORTOMASO\tST. JULIAN'S\tMALTA\tEurope/Malta\n"
+
+	tt := []struct {
+		maxErrors   int
+		wantRecords int
+		wantFailed  int
+		wantErr     error
+	}{
+		{-1, 2, 2, nil},
+		{2, 2, 2, nil},
+		{1, 1, 2, ErrTooManyErrors},
+		{0, 1, 1, ErrTooManyErrors},
+	}
+	for i := 0; i < len(tt); i++ {
+		records, report, err := ReadAll(NewReader(strings.NewReader(content), '\t'), tt[i].maxErrors)
+		if len(records) != tt[i].wantRecords || report.Failed != tt[i].wantFailed || !errors.Is(err, tt[i].wantErr) || report.Aborted != (tt[i].wantErr != nil) {
+			t.Errorf("ReadAll(%v) = %d records, %+v, %v, want %d records, %d failed, %v", tt[i].maxErrors, len(records), report, err, tt[i].wantRecords, tt[i].wantFailed, tt[i].wantErr)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	records, _, err := ReadAll(NewReader(strings.NewReader(HEADER_ROW+
+		"pl\tBIGBPLPWXXX\tBIC11\tBANK\tWARSZAWA\tWARSZAWA\tpoland\tEurope/Warsaw\n"+
+		"PL\tBIGBPLPWCUS\tBIC11\tBANK\tWARSZAWA\tWARSZAWA\tPOLSKA\tEurope/Warsaw\n"), '\t'), 0)
+	if err != nil {
+		t.Fatalf("ReadAll() error: %v", err)
+	}
+	codes, countries := Split(records)
+	if len(codes) != 2 || codes[0].CountryISO2 != "PL" {
+		t.Errorf("Split() codes = %+v, want 2 codes in PL", codes)
+	}
+	want := []string{"PL", "POLAND"}
+	if len(countries) != 1 || !reflect.DeepEqual([]string{countries[0].CountryISO2, countries[0].CountryName}, want) {
+		t.Errorf("Split() countries = %+v, want %v", countries, want)
+	}
+}
@@ -0,0 +1,29 @@
+package initdb
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"swiftcodes/sqlcout"
+)
+
+// NewImport returns the imports row recording a file with the SHA-256 hex digest, verified with the key
+// of signer or not verified if signer is empty
+func NewImport(fileName string, digest string, signer string) sqlcout.InsertImportParams {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return sqlcout.InsertImportParams{
+		ID:         hex.EncodeToString(id),
+		FileName:   fileName,
+		Sha256:     digest,
+		Signer:     signer,
+		ImportedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// batchID is the import_id of the codes written by batch
+func batchID(batch sqlcout.InsertImportParams) sql.NullString {
+	return sql.NullString{String: batch.ID, Valid: true}
+}
@@ -0,0 +1,83 @@
+package initdb
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"swiftcodes/sqlcout"
+)
+
+// XML_ENTRY is the element holding each institution of the XML files read by XMLReader
+const XML_ENTRY = "Entry"
+
+// xmlEntry is an institution identified as in ISO 20022 messages, with the country name the countries table needs
+type xmlEntry struct {
+	BICFI   string `xml:"FinInstnId>BICFI"`
+	Name    string `xml:"FinInstnId>Nm"`
+	Address struct {
+		Lines          []string `xml:"AdrLine"`
+		StreetName     string   `xml:"StrtNm"`
+		BuildingNumber string   `xml:"BldgNb"`
+		PostCode       string   `xml:"PstCd"`
+		TownName       string   `xml:"TwnNm"`
+		Country        string   `xml:"Ctry"`
+	} `xml:"FinInstnId>PstlAdr"`
+	CountryName string `xml:"CtryNm"`
+}
+
+// XMLReader reads ISO 20022 style XML files, in which every Entry element holds a FinInstnId with the
+// BICFI, Nm and PstlAdr of an institution next to a CtryNm with its country name, e.g.
+//
+//	<Entry>
+//	  <FinInstnId>
+//	    <BICFI>BIGBPLPWXXX</BICFI>
+//	    <Nm>BANK MILLENNIUM S.A.</Nm>
+//	    <PstlAdr><StrtNm>UL. STANISLAWA ZARYNA</StrtNm><BldgNb>2A</BldgNb><TwnNm>WARSZAWA</TwnNm><Ctry>PL</Ctry></PstlAdr>
+//	  </FinInstnId>
+//	  <CtryNm>POLAND</CtryNm>
+//	</Entry>
+//
+// Elements are matched by local name, so the file may use any namespace. The address joins the address
+// lines, street and building number, then the town and the post code. Malformed XML stops reading.
+type XMLReader struct {
+	decoder *xml.Decoder
+}
+
+func NewXMLReader(r io.Reader) *XMLReader {
+	return &XMLReader{decoder: xml.NewDecoder(r)}
+}
+
+func (r *XMLReader) Read() (Record, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return Record{}, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != XML_ENTRY {
+			continue
+		}
+		line, _ := r.decoder.InputPos()
+		var entry xmlEntry
+		if err := r.decoder.DecodeElement(&entry, &start); err != nil {
+			return Record{}, err
+		}
+		address := entry.Address
+		street := joinNonEmpty(" ", append(address.Lines, address.StreetName, address.BuildingNumber)...)
+		record := Record{
+			Line: line,
+			Code: sqlcout.InsertSwiftCodeParams{
+				SwiftCode:   strings.TrimSpace(entry.BICFI),
+				BankName:    strings.TrimSpace(entry.Name),
+				Address:     joinNonEmpty(", ", street, address.TownName, address.PostCode),
+				CountryISO2: strings.ToUpper(strings.TrimSpace(address.Country)),
+			},
+			CountryName: strings.ToUpper(strings.TrimSpace(entry.CountryName)),
+		}
+		if err := record.validate(); err != nil {
+			return Record{}, err
+		}
+		return record, nil
+	}
+}
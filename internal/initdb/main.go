@@ -1,64 +1,67 @@
 package initdb
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/csv"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"swiftcodes/internal/config"
+	"swiftcodes/internal/migrate"
+	"swiftcodes/internal/seed"
+	"swiftcodes/internal/store"
+	"swiftcodes/internal/verify"
 	"swiftcodes/sqlcout"
-
-	_ "github.com/go-sql-driver/mysql"
-)
-
-var (
-	DB_USER      = os.Getenv("SC_DB_USER")
-	DB_PASSWORD  = os.Getenv("SC_DB_PASSWORD")
-	DB_NAME      = os.Getenv("SC_DB_NAME")
-	DB_HOST      = os.Getenv("SC_DB_HOST")
-	DB_PORT      = os.Getenv("SC_DB_PORT")
-	DB_CONN_BASE = DB_USER + ":" + DB_PASSWORD + "@tcp(" + DB_HOST + ":" + DB_PORT + ")/"
 )
 
-func ReadCSV(path string) [][]string {
+// ReadCSV reads a whole CSV file, or TSV file if path ends with .tsv. Use a Reader to stream large files.
+func ReadCSV(path string) ([][]string, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		log.Fatal("Couldn't read input file "+path, err)
+		return nil, fmt.Errorf("couldn't read input file %s: %w", path, err)
 	}
 	defer f.Close()
 
 	csvReader := csv.NewReader(f)
-	if strings.HasSuffix(path, ".tsv") {
-		csvReader.Comma = '\t'
-	}
-	// csvReader.LazyQuotes = true
-	csvReader.FieldsPerRecord = 0
+	csvReader.Comma = Comma(path)
+	csvReader.FieldsPerRecord = -1
 	rows, err := csvReader.ReadAll()
 	if err != nil {
-		log.Fatal("Error parsing file "+path, err)
+		return nil, fmt.Errorf("error parsing file %s: %w", path, err)
 	}
-	return rows
+	return rows, nil
 }
 
-func ParseData(rows [][]string) ([]sqlcout.InsertSwiftCodeParams, []sqlcout.InsertCountryParams) {
+// ParseData converts the rows of an import file, finding the columns by the header in the first row with
+// DefaultAliases. Rows with too few columns are skipped.
+func ParseData(rows [][]string) ([]sqlcout.InsertSwiftCodeParams, []sqlcout.InsertCountryParams, error) {
 	var codes []sqlcout.InsertSwiftCodeParams
 	var countries []sqlcout.InsertCountryParams
 	countryMap := make(map[string]string)
+	if len(rows) == 0 {
+		return codes, countries, nil
+	}
+	columns, err := DefaultAliases().Map(rows[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	width := rowWidth(columns)
 
 	for i := 1; i < len(rows); i++ {
-		newCode := sqlcout.InsertSwiftCodeParams{
-			SwiftCode:   rows[i][1],
-			Address:     rows[i][4],
-			BankName:    rows[i][3],
-			CountryISO2: strings.ToUpper(rows[i][0]),
+		if len(rows[i]) < width {
+			continue
 		}
+		newCode := toCode(columns, rows[i])
 		codes = append(codes, newCode)
 
 		_, isKey := countryMap[newCode.CountryISO2]
 		if !isKey {
-			countryName := strings.ToUpper(rows[i][6])
+			countryName := strings.ToUpper(rows[i][columns[COLUMN_COUNTRY_NAME]])
 			countryMap[newCode.CountryISO2] = countryName
 			countries = append(countries, sqlcout.InsertCountryParams{
 				CountryISO2: newCode.CountryISO2,
@@ -66,78 +69,176 @@ func ParseData(rows [][]string) ([]sqlcout.InsertSwiftCodeParams, []sqlcout.Inse
 			})
 		}
 	}
-	return codes, countries
+	return codes, countries, nil
+}
+
+// CreateDB creates the database if needed and applies the pending migrations
+func CreateDB(cfg config.DB, forTest bool) *sql.DB {
+	var db *sql.DB
+	switch cfg.Driver {
+	case config.DRIVER_SQLITE:
+		db = createSQLiteDB(cfg, forTest)
+	case config.DRIVER_POSTGRES:
+		db = createPostgresDB(cfg, forTest)
+	default:
+		db = createMySQLDB(cfg, forTest)
+	}
+
+	applied, err := migrate.Up(context.Background(), db, cfg.Driver)
+	if err != nil {
+		log.Fatal("Failed to migrate database: ", err)
+	}
+	for _, m := range applied {
+		log.Printf("Applied migration %d_%s", m.Version, m.Name)
+	}
+	return db
 }
 
-func CreateDB(name string, schemaPath string, forTest bool) *sql.DB {
+func createMySQLDB(cfg config.DB, forTest bool) *sql.DB {
 	// Connect to DBMS and create DB
-	db, err := sql.Open("mysql", DB_CONN_BASE)
+	db, err := sql.Open("mysql", cfg.WithName("").DSN())
 	if err != nil {
 		log.Fatal("Failed to connect to database: ", err)
 	}
 
 	if forTest {
-		db.Exec("DROP DATABASE IF EXISTS " + name)
+		db.Exec("DROP DATABASE IF EXISTS " + cfg.Name)
 	}
-	_, err = db.Exec("CREATE DATABASE IF NOT EXISTS " + name)
+	_, err = db.Exec("CREATE DATABASE IF NOT EXISTS " + cfg.Name)
 	if err != nil {
 		log.Fatal("Failed to create database: ", err)
 	}
 	db.Close()
 
 	// Connect to our DB specifically
-	db, err = sql.Open("mysql", DB_CONN_BASE+name+"?multiStatements=true")
+	db, err = store.Open(cfg)
 	if err != nil {
 		log.Fatal("Failed to connect to database: ", err)
 	}
+	return db
+}
 
-	// Read and execute schema
-	schema, err := os.ReadFile(schemaPath)
+// createSQLiteDB creates the database file, as SQLite has no CREATE DATABASE
+func createSQLiteDB(cfg config.DB, forTest bool) *sql.DB {
+	if forTest {
+		os.Remove(cfg.Name)
+	}
+	db, err := store.Open(cfg)
 	if err != nil {
-		log.Fatal("Couldn't read schema file "+schemaPath, err)
+		log.Fatal("Failed to open database file: ", err)
 	}
-	_, err = db.Exec(string(schema))
+	return db
+}
+
+// createPostgresDB creates the database through the maintenance database, as PostgreSQL has no CREATE DATABASE IF NOT EXISTS
+func createPostgresDB(cfg config.DB, forTest bool) *sql.DB {
+	db, err := store.Open(cfg.WithName("postgres"))
 	if err != nil {
-		log.Fatal("Failed to create tables: ", err)
+		log.Fatal("Failed to connect to database: ", err)
 	}
 
+	if forTest {
+		db.Exec("DROP DATABASE IF EXISTS " + cfg.Name + " WITH (FORCE)")
+	}
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", cfg.Name).Scan(&exists)
+	if err != nil {
+		log.Fatal("Failed to create database: ", err)
+	}
+	if !exists {
+		_, err = db.Exec("CREATE DATABASE " + cfg.Name)
+		if err != nil {
+			log.Fatal("Failed to create database: ", err)
+		}
+	}
+	db.Close()
+
+	// Connect to our DB specifically
+	db, err = store.Open(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
 	return db
 }
 
-func PopulateDB(queries *sqlcout.Queries, ctx context.Context, countries []sqlcout.InsertCountryParams, swiftcodes []sqlcout.InsertSwiftCodeParams) {
+// PopulateDB inserts countries and swiftcodes, logging and skipping the rows that fail, and returns how many failed
+func PopulateDB(queries store.Store, ctx context.Context, countries []sqlcout.InsertCountryParams, swiftcodes []sqlcout.InsertSwiftCodeParams) int {
+	failed := 0
 	for _, country := range countries {
 		_, err := queries.InsertCountry(ctx, country)
 		if err != nil {
 			log.Print("Failed to insert country: ", err)
+			failed++
 		}
 	}
 	for _, code := range swiftcodes {
 		_, err := queries.InsertSwiftCode(ctx, code)
 		if err != nil {
 			log.Print("Failed to insert swift code: ", err)
+			failed++
 		}
 	}
+	return failed
 }
 
-func DBExists(name string) bool {
-	db, _ := sql.Open("mysql", DB_CONN_BASE+name)
-	err := db.Ping()
-	return err == nil
+func DBExists(cfg config.DB) bool {
+	if cfg.Driver == config.DRIVER_SQLITE {
+		_, err := os.Stat(cfg.Name)
+		return err == nil
+	}
+	db, err := store.Open(cfg)
+	if err != nil {
+		return false
+	}
+	db.Close()
+	return true
 }
 
-func SetupDB(name string, forTest bool) *sql.DB {
-	ctx := context.Background()
-	db := CreateDB(name, "schema.sql", forTest)
+// DropDB deletes the database, or the database file for SQLite
+func DropDB(cfg config.DB) error {
+	switch cfg.Driver {
+	case config.DRIVER_SQLITE:
+		return os.Remove(cfg.Name)
+	case config.DRIVER_POSTGRES:
+		db, err := store.Open(cfg.WithName("postgres"))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		_, err = db.Exec("DROP DATABASE IF EXISTS " + cfg.Name + " WITH (FORCE)")
+		return err
+	}
+	db, err := sql.Open("mysql", cfg.WithName("").DSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.Exec("DROP DATABASE IF EXISTS " + cfg.Name)
+	return err
+}
 
-	swiftcodes, countries := ParseData(ReadCSV("swiftcodes.tsv"))
+// SetupDB creates the database and loads the file named by cfg.Seed into it, or the embedded dataset
+func SetupDB(cfg config.DB, forTest bool) *sql.DB {
+	ctx := context.Background()
+	db := CreateDB(cfg, forTest)
 
-	queries := sqlcout.New(db)
+	name, data, digest := seed.FILE_NAME, seed.Open(), seed.Embedded().SHA256
+	if cfg.Seed != "" {
+		content, err := os.ReadFile(cfg.Seed)
+		if err != nil {
+			log.Fatal("Couldn't read seed file: ", err)
+		}
+		name, data, digest = cfg.Seed, bytes.NewReader(content), verify.Digest(content)
+	}
 
-	PopulateDB(queries, ctx, countries, swiftcodes)
+	batch := NewImport(filepath.Base(name), digest, "")
+	report, err := BulkImport(ctx, db, cfg.Driver, NewReader(data, Comma(name)), -1, batch)
+	for _, rowErr := range report.Errors {
+		log.Print("Failed to import row: ", rowErr.Error())
+	}
+	if err != nil {
+		log.Fatal("Failed to import "+name+": ", err)
+	}
 
 	return db
 }
-
-func main() {
-	SetupDB(DB_NAME, false)
-}
@@ -0,0 +1,200 @@
+package initdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"swiftcodes/internal/bic"
+)
+
+// QualityReport lists the problems found in an import file without loading it
+type QualityReport struct {
+	Rows                 int                   `json:"rows"`
+	Invalid              []RowError            `json:"invalid"`
+	DuplicateCodes       []DuplicateCode       `json:"duplicateCodes"`
+	MissingHeadquarters  []MissingHeadquarter  `json:"missingHeadquarters"`
+	CountryNameConflicts []CountryNameConflict `json:"countryNameConflicts"`
+	CountryMismatches    []CountryMismatch     `json:"countryMismatches"`
+	StrayWhitespace      []StrayWhitespace     `json:"strayWhitespace"`
+}
+
+// DuplicateCode is a code found on several lines
+type DuplicateCode struct {
+	SwiftCode string `json:"swiftCode"`
+	Lines     []int  `json:"lines"`
+}
+
+// MissingHeadquarter is a branch whose headquarters code isn't in the file
+type MissingHeadquarter struct {
+	Line        int    `json:"line"`
+	SwiftCode   string `json:"swiftCode"`
+	Headquarter string `json:"headquarter"`
+}
+
+// CountryNameConflict is an ISO2 code given different country names
+type CountryNameConflict struct {
+	CountryISO2 string        `json:"countryISO2"`
+	Names       []CountryName `json:"names"`
+}
+
+type CountryName struct {
+	Name  string `json:"name"`
+	Lines []int  `json:"lines"`
+}
+
+// CountryMismatch is a code whose country segment differs from the ISO2 column of its row
+type CountryMismatch struct {
+	Line        int    `json:"line"`
+	SwiftCode   string `json:"swiftCode"`
+	CountryISO2 string `json:"countryISO2"`
+	BICCountry  string `json:"bicCountry"`
+}
+
+// StrayWhitespace is an address with leading, trailing or repeated whitespace
+type StrayWhitespace struct {
+	Line      int    `json:"line"`
+	SwiftCode string `json:"swiftCode"`
+	Address   string `json:"address"`
+}
+
+// Issues returns the number of problems found
+func (report QualityReport) Issues() int {
+	return len(report.Invalid) + len(report.DuplicateCodes) + len(report.MissingHeadquarters) +
+		len(report.CountryNameConflicts) + len(report.CountryMismatches) + len(report.StrayWhitespace)
+}
+
+// CheckQuality reads every row of reader and reports the problems of the file. Only errors reading the
+// file itself are returned, invalid rows are part of the report.
+func CheckQuality(reader RecordReader) (QualityReport, error) {
+	report := QualityReport{
+		Invalid:              []RowError{},
+		DuplicateCodes:       []DuplicateCode{},
+		MissingHeadquarters:  []MissingHeadquarter{},
+		CountryNameConflicts: []CountryNameConflict{},
+		CountryMismatches:    []CountryMismatch{},
+		StrayWhitespace:      []StrayWhitespace{},
+	}
+	codeLines := make(map[string][]int)
+	var codeOrder []string
+	headquarters := make(map[string]bool)
+	var branches []Record
+	countryNames := make(map[string]map[string][]int)
+	var countryOrder []string
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			report.Rows++
+			report.Invalid = append(report.Invalid, *rowErr)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Rows++
+		code := record.Code
+
+		if _, ok := codeLines[code.SwiftCode]; !ok {
+			codeOrder = append(codeOrder, code.SwiftCode)
+		}
+		codeLines[code.SwiftCode] = append(codeLines[code.SwiftCode], record.Line)
+
+		if bic.IsHeadquarter(code.SwiftCode) {
+			headquarters[bic.Bank8(code.SwiftCode)] = true
+		} else {
+			branches = append(branches, record)
+		}
+
+		if _, ok := countryNames[code.CountryISO2]; !ok {
+			countryNames[code.CountryISO2] = make(map[string][]int)
+			countryOrder = append(countryOrder, code.CountryISO2)
+		}
+		countryNames[code.CountryISO2][record.CountryName] = append(countryNames[code.CountryISO2][record.CountryName], record.Line)
+
+		if country := bic.Country(code.SwiftCode); country != code.CountryISO2 {
+			report.CountryMismatches = append(report.CountryMismatches, CountryMismatch{record.Line, code.SwiftCode, code.CountryISO2, country})
+		}
+		if hasStrayWhitespace(code.Address) {
+			report.StrayWhitespace = append(report.StrayWhitespace, StrayWhitespace{record.Line, code.SwiftCode, code.Address})
+		}
+	}
+
+	for _, code := range codeOrder {
+		if lines := codeLines[code]; len(lines) > 1 {
+			report.DuplicateCodes = append(report.DuplicateCodes, DuplicateCode{code, lines})
+		}
+	}
+	for _, branch := range branches {
+		if bank8 := bic.Bank8(branch.Code.SwiftCode); !headquarters[bank8] {
+			report.MissingHeadquarters = append(report.MissingHeadquarters, MissingHeadquarter{branch.Line, branch.Code.SwiftCode, bank8 + bic.HEADQUARTER_BRANCH})
+		}
+	}
+	for _, iso2 := range countryOrder {
+		if len(countryNames[iso2]) < 2 {
+			continue
+		}
+		conflict := CountryNameConflict{CountryISO2: iso2}
+		for name, lines := range countryNames[iso2] {
+			conflict.Names = append(conflict.Names, CountryName{name, lines})
+		}
+		sort.Slice(conflict.Names, func(i, j int) bool { return conflict.Names[i].Lines[0] < conflict.Names[j].Lines[0] })
+		report.CountryNameConflicts = append(report.CountryNameConflicts, conflict)
+	}
+	return report, nil
+}
+
+// hasStrayWhitespace reports whether s has leading, trailing or repeated whitespace, or whitespace other than spaces
+func hasStrayWhitespace(s string) bool {
+	return s != strings.Join(strings.Fields(s), " ")
+}
+
+// WriteText writes the report for people to read
+func (report QualityReport) WriteText(w io.Writer) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "checked %d rows, found %d issues\n", report.Rows, report.Issues())
+	fmt.Fprintf(&text, "invalid rows: %d\n", len(report.Invalid))
+	for _, e := range report.Invalid {
+		fmt.Fprintf(&text, "  %s\n", e.Error())
+	}
+	fmt.Fprintf(&text, "duplicate codes: %d\n", len(report.DuplicateCodes))
+	for _, d := range report.DuplicateCodes {
+		fmt.Fprintf(&text, "  %s on lines %s\n", d.SwiftCode, joinLines(d.Lines))
+	}
+	fmt.Fprintf(&text, "branches without headquarters: %d\n", len(report.MissingHeadquarters))
+	for _, m := range report.MissingHeadquarters {
+		fmt.Fprintf(&text, "  line %d: %s, no %s\n", m.Line, m.SwiftCode, m.Headquarter)
+	}
+	fmt.Fprintf(&text, "country names differing per ISO2 code: %d\n", len(report.CountryNameConflicts))
+	for _, c := range report.CountryNameConflicts {
+		var names []string
+		for _, name := range c.Names {
+			names = append(names, fmt.Sprintf("%q on lines %s", name.Name, joinLines(name.Lines)))
+		}
+		fmt.Fprintf(&text, "  %s: %s\n", c.CountryISO2, strings.Join(names, ", "))
+	}
+	fmt.Fprintf(&text, "BIC countries differing from the ISO2 column: %d\n", len(report.CountryMismatches))
+	for _, m := range report.CountryMismatches {
+		fmt.Fprintf(&text, "  line %d: %s is in %s, row says %s\n", m.Line, m.SwiftCode, m.BICCountry, m.CountryISO2)
+	}
+	fmt.Fprintf(&text, "addresses with stray whitespace: %d\n", len(report.StrayWhitespace))
+	for _, s := range report.StrayWhitespace {
+		fmt.Fprintf(&text, "  line %d: %s %q\n", s.Line, s.SwiftCode, s.Address)
+	}
+	_, err := io.WriteString(w, text.String())
+	return err
+}
+
+func joinLines(lines []int) string {
+	var s []string
+	for _, line := range lines {
+		s = append(s, fmt.Sprint(line))
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,76 @@
+package initdb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckQuality(t *testing.T) {
+	content := HEADER_ROW +
+		"PL\tBIGBPLPWXXX\tBIC11\tBANK\tUL. ZARYNA 2A\tWARSZAWA\tPOLAND\tEurope/Warsaw\n" +
+		"PL\tBIGBPLPWCUS\tBIC11\tBANK\t UL. ZARYNA  2A\tWARSZAWA\tPOLSKA\tEurope/Warsaw\n" +
+		"PL\tBIGBPLPWXXX\tBIC11\tBANK\tUL. ZARYNA 2A\tWARSZAWA\tPOLAND\tEurope/Warsaw\n" +
+		"PL\tAKBKMTMTXXX\tBIC11\tAKBANK\tPORTOMASO\tST. JULIAN'S\tPOLAND\tEurope/Malta\n" +
+		"MT\tDEUTMTMTABC\tBIC11\tDEUTSCHE BANK\tPORTOMASO\tST. JULIAN'S\tMALTA\tEurope/Malta\n" +
+		"MT\tAKBK\n"
+
+	report, err := CheckQuality(NewReader(strings.NewReader(content), '\t'))
+	if err != nil {
+		t.Fatalf("CheckQuality() error = %v", err)
+	}
+	want := QualityReport{
+		Rows:                6,
+		Invalid:             []RowError{{Line: 7, Message: "has 2 columns, want at least 7"}},
+		DuplicateCodes:      []DuplicateCode{{"BIGBPLPWXXX", []int{2, 4}}},
+		MissingHeadquarters: []MissingHeadquarter{{6, "DEUTMTMTABC", "DEUTMTMTXXX"}},
+		CountryNameConflicts: []CountryNameConflict{{"PL", []CountryName{
+			{"POLAND", []int{2, 4, 5}},
+			{"POLSKA", []int{3}},
+		}}},
+		CountryMismatches: []CountryMismatch{{5, "AKBKMTMTXXX", "PL", "MT"}},
+		StrayWhitespace:   []StrayWhitespace{{3, "BIGBPLPWCUS", " UL. ZARYNA  2A"}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("CheckQuality() = %+v, want %+v", report, want)
+	}
+	if report.Issues() != 6 {
+		t.Errorf("Issues() = %v, want 6", report.Issues())
+	}
+
+	var text strings.Builder
+	if err := report.WriteText(&text); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	for _, line := range []string{
+		"checked 6 rows, found 6 issues\n",
+		"  BIGBPLPWXXX on lines 2, 4\n",
+		"  line 6: DEUTMTMTABC, no DEUTMTMTXXX\n",
+		"  PL: \"POLAND\" on lines 2, 4, 5, \"POLSKA\" on lines 3\n",
+		"  line 5: AKBKMTMTXXX is in MT, row says PL\n",
+		"  line 3: BIGBPLPWCUS \" UL. ZARYNA  2A\"\n",
+	} {
+		if !strings.Contains(text.String(), line) {
+			t.Errorf("WriteText() = %q, want it to contain %q", text.String(), line)
+		}
+	}
+}
+
+func TestHasStrayWhitespace(t *testing.T) {
+	tt := []struct {
+		address string
+		want    bool
+	}{
+		{"UL. ZARYNA 2A", false},
+		{"", false},
+		{" UL. ZARYNA 2A", true},
+		{"UL. ZARYNA 2A ", true},
+		{"UL.  ZARYNA 2A", true},
+		{"UL.\tZARYNA 2A", true},
+	}
+	for i := 0; i < len(tt); i++ {
+		if got := hasStrayWhitespace(tt[i].address); got != tt[i].want {
+			t.Errorf(`hasStrayWhitespace("%v") = %v, want %v`, tt[i].address, got, tt[i].want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package initdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"swiftcodes/internal/store"
+	"swiftcodes/sqlcout"
+)
+
+// Sync applies diff, with its conflicts resolved by Diff.Resolve, to the database in one transaction, so
+// either all changes are made or none. Countries missing from the database are inserted first. The sync is
+// recorded as batch, which the added and changed codes reference as their source. The previous rows of the
+// changed and removed codes are kept, so that Rollback can restore them.
+func Sync(ctx context.Context, db *sql.DB, driver string, diff Diff, countries []sqlcout.InsertCountryParams, batch sqlcout.InsertImportParams) error {
+	return store.InTx(ctx, db, driver, func(queries store.Store) error {
+		if _, err := queries.InsertImport(ctx, batch); err != nil {
+			return fmt.Errorf("couldn't record import: %w", err)
+		}
+		for _, country := range countries {
+			_, err := queries.GetCountry(ctx, country.CountryISO2)
+			if errors.Is(err, store.ErrNotFound) {
+				_, err = queries.InsertCountry(ctx, country)
+			}
+			if err != nil {
+				return fmt.Errorf("couldn't add country %s: %w", country.CountryISO2, err)
+			}
+		}
+		for _, code := range diff.Added {
+			code.ImportID = batchID(batch)
+			if _, err := queries.InsertSwiftCode(ctx, code); err != nil {
+				return fmt.Errorf("couldn't add %s: %w", code.SwiftCode, err)
+			}
+		}
+		for _, change := range diff.Changed {
+			// A corrected value stays flagged, and its actor recorded, as long as it is kept
+			update := sqlcout.UpdateSwiftCodeParams{
+				Address:          change.New.Address,
+				BankName:         change.New.BankName,
+				CountryISO2:      change.New.CountryISO2,
+				ImportID:         batchID(batch),
+				AddressOverride:  change.Old.AddressOverride && change.New.Address == change.Old.Address,
+				BankNameOverride: change.Old.BankNameOverride && change.New.BankName == change.Old.BankName,
+				SwiftCode:        change.Old.SwiftCode,
+			}
+			if update.AddressOverride || update.BankNameOverride {
+				update.Actor = change.Old.Actor
+			}
+			if err := recordChange(ctx, queries, batch, change.Old.SwiftCode, false); err != nil {
+				return err
+			}
+			if _, err := queries.UpdateSwiftCode(ctx, update); err != nil {
+				return fmt.Errorf("couldn't change %s: %w", change.Old.SwiftCode, err)
+			}
+		}
+		for _, code := range diff.Removed {
+			if err := recordChange(ctx, queries, batch, code.SwiftCode, true); err != nil {
+				return err
+			}
+			if _, err := queries.DeleteSwiftCode(ctx, code.SwiftCode); err != nil {
+				return fmt.Errorf("couldn't remove %s: %w", code.SwiftCode, err)
+			}
+		}
+		return nil
+	})
+}
+
+// recordChange keeps the row of swiftCode before batch changes or removes it
+func recordChange(ctx context.Context, queries store.Store, batch sqlcout.InsertImportParams, swiftCode string, removed bool) error {
+	_, err := queries.RecordSwiftCodeChange(ctx, sqlcout.RecordSwiftCodeChangeParams{ImportID: batch.ID, Removed: removed, SwiftCode: swiftCode})
+	if err != nil {
+		return fmt.Errorf("couldn't keep the previous row of %s: %w", swiftCode, err)
+	}
+	return nil
+}
+
+// Rollback lists what rolling back an import batch does
+type Rollback struct {
+	// Deleted are the codes the batch inserted
+	Deleted []string
+	// Restored are the codes the batch changed or removed, which get their previous rows back. Codes
+	// written again since by another batch are left alone.
+	Restored []string
+}
+
+// PlanRollback returns what RollbackImport would do to the batch importID
+func PlanRollback(ctx context.Context, queries store.Store, importID string) (Rollback, error) {
+	var plan Rollback
+	codes, err := queries.ListSwiftCodesByImport(ctx, sql.NullString{String: importID, Valid: true})
+	if err != nil {
+		return plan, err
+	}
+	changes, err := queries.ListSwiftCodeChanges(ctx, importID)
+	if err != nil {
+		return plan, err
+	}
+	changed := make(map[string]bool)
+	for _, change := range changes {
+		if change.Removed {
+			_, err := queries.GetSwiftCodeSource(ctx, change.SwiftCode)
+			if errors.Is(err, store.ErrNotFound) {
+				plan.Restored = append(plan.Restored, change.SwiftCode)
+				continue
+			}
+			if err != nil {
+				return plan, err
+			}
+			continue
+		}
+		changed[strings.ToUpper(change.SwiftCode)] = true
+	}
+	for _, code := range codes {
+		if changed[strings.ToUpper(code)] {
+			plan.Restored = append(plan.Restored, code)
+		} else {
+			plan.Deleted = append(plan.Deleted, code)
+		}
+	}
+	slices.Sort(plan.Restored)
+	return plan, nil
+}
+
+// RollbackImport undoes the batch importID in one transaction: the codes it inserted are deleted and those
+// it changed or removed are restored. It returns what it did.
+func RollbackImport(ctx context.Context, db *sql.DB, driver string, importID string) (Rollback, error) {
+	var plan Rollback
+	err := store.InTx(ctx, db, driver, func(queries store.Store) error {
+		var err error
+		if plan, err = PlanRollback(ctx, queries, importID); err != nil {
+			return err
+		}
+		changes, err := queries.ListSwiftCodeChanges(ctx, importID)
+		if err != nil {
+			return err
+		}
+		restored := make(map[string]bool)
+		for _, code := range plan.Restored {
+			restored[strings.ToUpper(code)] = true
+		}
+		batch := sql.NullString{String: importID, Valid: true}
+		// Changed codes get their source back first, so that only the inserted ones still reference the batch
+		for _, change := range changes {
+			if change.Removed || !restored[strings.ToUpper(change.SwiftCode)] {
+				continue
+			}
+			if _, err := queries.RestoreSwiftCode(ctx, restoreParams(change, batch)); err != nil {
+				return fmt.Errorf("couldn't restore %s: %w", change.SwiftCode, err)
+			}
+		}
+		if _, err := queries.DeleteSwiftCodesByImport(ctx, batch); err != nil {
+			return err
+		}
+		for _, change := range changes {
+			if !change.Removed || !restored[strings.ToUpper(change.SwiftCode)] {
+				continue
+			}
+			// The code is inserted referencing the batch, then restored like a changed one
+			insert := sqlcout.InsertSwiftCodeParams{
+				SwiftCode:   change.SwiftCode,
+				Address:     change.Address,
+				BankName:    change.BankName,
+				CountryISO2: change.CountryISO2,
+				ImportID:    batch,
+				Actor:       change.Actor,
+			}
+			if _, err := queries.InsertSwiftCode(ctx, insert); err != nil {
+				return fmt.Errorf("couldn't restore %s: %w", change.SwiftCode, err)
+			}
+			if _, err := queries.RestoreSwiftCode(ctx, restoreParams(change, batch)); err != nil {
+				return fmt.Errorf("couldn't restore %s: %w", change.SwiftCode, err)
+			}
+		}
+		_, err = queries.DeleteSwiftCodeChanges(ctx, importID)
+		return err
+	})
+	return plan, err
+}
+
+func restoreParams(change sqlcout.SwiftCodeChange, batch sql.NullString) sqlcout.RestoreSwiftCodeParams {
+	return sqlcout.RestoreSwiftCodeParams{
+		Address:          change.Address,
+		BankName:         change.BankName,
+		CountryISO2:      change.CountryISO2,
+		PreviousImportID: change.PreviousImportID,
+		Actor:            change.Actor,
+		AddressOverride:  change.AddressOverride,
+		BankNameOverride: change.BankNameOverride,
+		SwiftCode:        change.SwiftCode,
+		ImportID:         batch,
+	}
+}
@@ -0,0 +1,593 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"swiftcodes/internal/store"
+	"swiftcodes/sqlcout"
+)
+
+// States of a job
+const (
+	STATE_QUEUED    = "queued"
+	STATE_RUNNING   = "running"
+	STATE_SUCCEEDED = "succeeded"
+	STATE_FAILED    = "failed"
+	STATE_CANCELLED = "cancelled"
+)
+
+var (
+	ErrNotFound    = errors.New("job not found")
+	ErrFinished    = errors.New("job already finished")
+	ErrUnknownKind = errors.New("unknown job kind")
+)
+
+// Job is a background operation stored in the jobs table, which outlives restarts of the API
+type Job struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	State string `json:"state"`
+	// Attempts counts the runs of the job, including the current one
+	Attempts    int64  `json:"attempts"`
+	MaxAttempts int64  `json:"maxAttempts"`
+	RunAfter    string `json:"runAfter"`
+	// Progress is reported by the handler of the job kind, null until it reports any
+	Progress   json.RawMessage `json:"progress"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  string          `json:"createdAt"`
+	UpdatedAt  string          `json:"updatedAt"`
+	FinishedAt string          `json:"finishedAt,omitempty"`
+	payload    string
+}
+
+// Finished reports whether the job stopped for good, whatever the outcome
+func (job Job) Finished() bool {
+	return job.State != STATE_QUEUED && job.State != STATE_RUNNING
+}
+
+// Payload decodes the payload the job was enqueued with into v
+func (job Job) Payload(v any) error {
+	return json.Unmarshal([]byte(job.payload), v)
+}
+
+func fromRow(row sqlcout.Job) Job {
+	return Job{
+		ID:          row.ID,
+		Kind:        row.Kind,
+		State:       row.State,
+		Attempts:    row.Attempts,
+		MaxAttempts: row.MaxAttempts,
+		RunAfter:    row.RunAfter,
+		Progress:    json.RawMessage(row.Progress),
+		Error:       row.Error,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+		FinishedAt:  row.FinishedAt,
+		payload:     row.Payload,
+	}
+}
+
+// Run is a job given to the handler of its kind
+type Run struct {
+	Job
+	mu       sync.Mutex
+	progress func() any
+}
+
+// SetProgress makes the runner save the value returned by progress as the progress of the job, on every
+// heartbeat and when the job ends. progress is called from another goroutine and must be safe for that.
+func (run *Run) SetProgress(progress func() any) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.progress = progress
+}
+
+// encodeProgress returns the JSON of the current progress, the previous one if the handler didn't set any
+func (run *Run) encodeProgress() string {
+	run.mu.Lock()
+	progress := run.progress
+	run.mu.Unlock()
+	if progress == nil {
+		return string(run.Progress)
+	}
+	encoded, err := json.Marshal(progress())
+	if err != nil {
+		log.Printf("Error encoding the progress of job %s: %v", run.ID, err)
+		return string(run.Progress)
+	}
+	return string(encoded)
+}
+
+// Handler runs the jobs of a kind
+type Handler struct {
+	// Run does the work of the job, stopping when ctx is cancelled. Returned errors are retried until the
+	// job ran MaxAttempts times, unless they are wrapped with Permanent.
+	Run func(ctx context.Context, run *Run) error
+	// Done is called once the job finished, whatever the outcome, e.g. to remove its files. It may be nil.
+	Done func(job Job)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one that retrying the job won't fix
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+type Options struct {
+	Workers     int
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled for every further one
+	Backoff time.Duration
+	// Poll is how often the jobs table is checked for due jobs
+	Poll time.Duration
+	// Heartbeat is how often a running job saves its progress. A job whose last heartbeat is older than
+	// Lease is taken to be abandoned by a stopped process and queued again.
+	Heartbeat time.Duration
+	Lease     time.Duration
+}
+
+// DefaultOptions returns the options used for the fields left zero
+func DefaultOptions() Options {
+	return Options{
+		Workers:     2,
+		MaxAttempts: 3,
+		Backoff:     10 * time.Second,
+		Poll:        time.Second,
+		Heartbeat:   5 * time.Second,
+		Lease:       time.Minute,
+	}
+}
+
+// Runner runs the jobs of the jobs table with a bounded number of workers. Several processes sharing the
+// database may run one each, a job is claimed by one of them only.
+type Runner struct {
+	queries  store.Store
+	options  Options
+	handlers map[string]Handler
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+	// slots holds a value per running job
+	slots chan struct{}
+	wake  chan struct{}
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func NewRunner(queries store.Store, options Options) *Runner {
+	def := DefaultOptions()
+	if options.Workers <= 0 {
+		options.Workers = def.Workers
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = def.MaxAttempts
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = def.Backoff
+	}
+	if options.Poll <= 0 {
+		options.Poll = def.Poll
+	}
+	if options.Heartbeat <= 0 {
+		options.Heartbeat = def.Heartbeat
+	}
+	if options.Lease <= 0 {
+		options.Lease = def.Lease
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	return &Runner{
+		queries:  queries,
+		options:  options,
+		handlers: make(map[string]Handler),
+		ctx:      ctx,
+		stop:     stop,
+		slots:    make(chan struct{}, options.Workers),
+		wake:     make(chan struct{}, 1),
+		cancels:  make(map[string]context.CancelFunc),
+	}
+}
+
+// Handle registers the handler of the jobs of kind, before the runner is started
+func (r *Runner) Handle(kind string, handler Handler) {
+	r.handlers[kind] = handler
+}
+
+// Enqueue stores a job of kind to run as soon as a worker is free. The ID is generated if id is empty.
+func (r *Runner) Enqueue(ctx context.Context, id string, kind string, payload any) (Job, error) {
+	if _, ok := r.handlers[kind]; !ok {
+		return Job{}, fmt.Errorf("%w %q", ErrUnknownKind, kind)
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+	if id == "" {
+		id = NewID()
+	}
+	now := timestamp(time.Now())
+	params := sqlcout.InsertJobParams{
+		ID:          id,
+		Kind:        kind,
+		Payload:     string(encoded),
+		State:       STATE_QUEUED,
+		MaxAttempts: int64(r.options.MaxAttempts),
+		RunAfter:    now,
+		Progress:    "null",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := r.queries.InsertJob(ctx, params); err != nil {
+		return Job{}, err
+	}
+	r.signal()
+	return fromRow(sqlcout.Job(params)), nil
+}
+
+// Get returns the job with id
+func (r *Runner) Get(ctx context.Context, id string) (Job, error) {
+	row, err := r.queries.GetJob(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return Job{}, ErrNotFound
+	}
+	if err != nil {
+		return Job{}, err
+	}
+	return fromRow(row), nil
+}
+
+// List returns the newest jobs, at most limit
+func (r *Runner) List(ctx context.Context, limit int) ([]Job, error) {
+	rows, err := r.queries.ListJobs(ctx, int32(limit))
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]Job, 0, len(rows))
+	for _, row := range rows {
+		jobs = append(jobs, fromRow(row))
+	}
+	return jobs, nil
+}
+
+// Cancel stops the job with id, returning it as it was before. A running job is told to stop and records
+// its last progress once it did, poll it until it finished.
+func (r *Runner) Cancel(ctx context.Context, id string) (Job, error) {
+	job, err := r.Get(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	if job.Finished() {
+		return job, ErrFinished
+	}
+	now := timestamp(time.Now())
+	result, err := r.queries.CancelJob(ctx, sqlcout.CancelJobParams{UpdatedAt: now, FinishedAt: now, ID: id})
+	if err != nil {
+		return Job{}, err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		// It finished in the meantime
+		if job, err = r.Get(ctx, id); err != nil {
+			return Job{}, err
+		}
+		return job, ErrFinished
+	}
+	r.mu.Lock()
+	cancel, running := r.cancels[id]
+	r.mu.Unlock()
+	switch {
+	case running:
+		cancel()
+	case job.State == STATE_QUEUED:
+		cancelled := job
+		cancelled.State = STATE_CANCELLED
+		r.done(cancelled)
+	}
+	// A job running in another process is stopped by its next heartbeat
+	return job, nil
+}
+
+// Start queues the jobs abandoned by stopped processes again and starts running due jobs
+func (r *Runner) Start() {
+	r.wg.Add(1)
+	go r.dispatch()
+}
+
+// Close stops the running jobs and waits for them, they are queued again to resume after a restart
+func (r *Runner) Close() {
+	r.stop()
+	r.wg.Wait()
+}
+
+func (r *Runner) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Runner) dispatch() {
+	defer r.wg.Done()
+	poll := time.NewTicker(r.options.Poll)
+	defer poll.Stop()
+	var recovered time.Time
+	for {
+		if time.Since(recovered) >= r.options.Lease {
+			r.requeueStale()
+			recovered = time.Now()
+		}
+		r.claim()
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-poll.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// requeueStale queues the running jobs again whose process stopped sending heartbeats,
+// or fails them if that process ran their last attempt
+func (r *Runner) requeueStale() {
+	now := time.Now()
+	staleBefore := timestamp(now.Add(-r.options.Lease))
+	_, err := r.queries.RequeueStaleJobs(r.ctx, sqlcout.RequeueStaleJobsParams{
+		Now:         timestamp(now),
+		StaleBefore: staleBefore,
+	})
+	if err != nil && r.ctx.Err() == nil {
+		log.Printf("Error requeuing stale jobs: %v", err)
+	}
+	_, err = r.queries.FailStaleJobs(r.ctx, sqlcout.FailStaleJobsParams{
+		Error:       "the job stopped sending heartbeats during its last attempt",
+		Now:         timestamp(now),
+		StaleBefore: staleBefore,
+	})
+	if err != nil && r.ctx.Err() == nil {
+		log.Printf("Error failing stale jobs: %v", err)
+	}
+}
+
+// claim starts due jobs while workers are free
+func (r *Runner) claim() {
+	free := cap(r.slots) - len(r.slots)
+	if free == 0 {
+		return
+	}
+	due, err := r.queries.ListDueJobs(r.ctx, sqlcout.ListDueJobsParams{RunAfter: timestamp(time.Now()), Limit: int32(free)})
+	if err != nil {
+		if r.ctx.Err() == nil {
+			log.Printf("Error listing due jobs: %v", err)
+		}
+		return
+	}
+	for _, job := range due {
+		id := job.ID
+		// The job can be cancelled as soon as it is claimed
+		ctx, cancel := context.WithCancel(r.ctx)
+		r.mu.Lock()
+		r.cancels[id] = cancel
+		r.mu.Unlock()
+		result, err := r.queries.ClaimJob(r.ctx, sqlcout.ClaimJobParams{UpdatedAt: timestamp(time.Now()), ID: id, Attempts: job.Attempts})
+		if err == nil {
+			var n int64
+			if n, err = result.RowsAffected(); err == nil && n == 1 {
+				r.slots <- struct{}{}
+				r.wg.Add(1)
+				go r.work(ctx, cancel, id, job.Attempts+1)
+				continue
+			}
+		}
+		r.forget(id)
+		cancel()
+		if err != nil {
+			if r.ctx.Err() == nil {
+				log.Printf("Error claiming job %s: %v", id, err)
+			}
+			return
+		}
+		// Claimed by another process or cancelled
+	}
+}
+
+func (r *Runner) forget(id string) {
+	r.mu.Lock()
+	delete(r.cancels, id)
+	r.mu.Unlock()
+}
+
+// work runs the attempt of the job with id claimed by the runner. The writes of the run are conditioned on
+// the attempt, so that they are ignored once another process took the job over.
+func (r *Runner) work(ctx context.Context, cancel context.CancelFunc, id string, attempt int64) {
+	defer r.wg.Done()
+	defer func() {
+		<-r.slots
+		r.signal()
+	}()
+	defer cancel()
+	defer r.forget(id)
+
+	job, err := r.Get(context.Background(), id)
+	if err != nil {
+		// Requeued as stale by the next runner that starts
+		log.Printf("Error loading job %s: %v", id, err)
+		return
+	}
+	if job.Attempts != attempt {
+		// Requeued as stale and claimed again in the meantime
+		return
+	}
+	run := &Run{Job: job}
+	handler, ok := r.handlers[job.Kind]
+	if !ok {
+		r.finish(run, STATE_FAILED, fmt.Errorf("%w %q", ErrUnknownKind, job.Kind))
+		return
+	}
+
+	beating := make(chan struct{})
+	go func() {
+		defer close(beating)
+		r.heartbeat(ctx, cancel, run)
+	}()
+	err = r.call(ctx, handler, run)
+	stopped := ctx.Err() != nil
+	cancel()
+	<-beating
+
+	switch {
+	case err != nil && r.ctx.Err() != nil:
+		// Shutting down, another run resumes the job
+		r.release(run)
+	case err != nil && stopped:
+		// Cancelled or taken over by another process
+		r.stopped(run)
+	case err == nil:
+		r.finish(run, STATE_SUCCEEDED, nil)
+	case isPermanent(err) || run.Attempts >= run.MaxAttempts:
+		r.finish(run, STATE_FAILED, err)
+	default:
+		r.retry(run, err)
+	}
+}
+
+// call runs handler, turning a panic into a permanent error
+func (r *Runner) call(ctx context.Context, handler Handler, run *Run) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = Permanent(fmt.Errorf("panic: %v", p))
+		}
+	}()
+	return handler.Run(ctx, run)
+}
+
+// heartbeat saves the progress of run until ctx is cancelled, cancelling it once the job isn't running this
+// attempt anymore, as it was cancelled or requeued and claimed by another process
+func (r *Runner) heartbeat(ctx context.Context, cancel context.CancelFunc, run *Run) {
+	ticker := time.NewTicker(r.options.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		result, err := r.queries.HeartbeatJob(ctx, sqlcout.HeartbeatJobParams{
+			Progress:  run.encodeProgress(),
+			UpdatedAt: timestamp(time.Now()),
+			ID:        run.ID,
+			Attempts:  run.Attempts,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Error saving the progress of job %s: %v", run.ID, err)
+			}
+			continue
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			cancel()
+			return
+		}
+	}
+}
+
+// finish records the outcome of run, the writes use their own context as the runner may be closing. A run
+// whose job was taken over by another process records nothing.
+func (r *Runner) finish(run *Run, state string, cause error) {
+	now := timestamp(time.Now())
+	params := sqlcout.FinishJobParams{State: state, Progress: run.encodeProgress(), UpdatedAt: now, FinishedAt: now, ID: run.ID, Attempts: run.Attempts}
+	if cause != nil {
+		params.Error = cause.Error()
+	}
+	result, err := r.queries.FinishJob(context.Background(), params)
+	if err != nil {
+		log.Printf("Error finishing job %s: %v", run.ID, err)
+		return
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		log.Printf("Job %s was taken over by another run, its attempt %d isn't recorded", run.ID, run.Attempts)
+		return
+	}
+	run.State = state
+	r.done(run.Job)
+}
+
+// stopped records the progress of a cancelled run, unless another process took over the job
+func (r *Runner) stopped(run *Run) {
+	job, err := r.Get(context.Background(), run.ID)
+	if err != nil {
+		log.Printf("Error loading job %s: %v", run.ID, err)
+		return
+	}
+	if job.State == STATE_CANCELLED {
+		r.finish(run, STATE_CANCELLED, nil)
+	}
+}
+
+// retry queues run again after a backoff doubling with every attempt
+func (r *Runner) retry(run *Run, cause error) {
+	now := time.Now()
+	backoff := r.options.Backoff << min(max(run.Attempts-1, 0), 20)
+	_, err := r.queries.RetryJob(context.Background(), sqlcout.RetryJobParams{
+		Progress:  run.encodeProgress(),
+		Error:     cause.Error(),
+		RunAfter:  timestamp(now.Add(backoff)),
+		UpdatedAt: timestamp(now),
+		ID:        run.ID,
+		Attempts:  run.Attempts,
+	})
+	if err != nil {
+		log.Printf("Error queuing job %s for a retry: %v", run.ID, err)
+	}
+}
+
+// release queues run again without counting the interrupted attempt
+func (r *Runner) release(run *Run) {
+	_, err := r.queries.ReleaseJob(context.Background(), sqlcout.ReleaseJobParams{
+		Progress:  run.encodeProgress(),
+		UpdatedAt: timestamp(time.Now()),
+		ID:        run.ID,
+		Attempts:  run.Attempts,
+	})
+	if err != nil {
+		log.Printf("Error releasing job %s: %v", run.ID, err)
+	}
+}
+
+func (r *Runner) done(job Job) {
+	if handler, ok := r.handlers[job.Kind]; ok && handler.Done != nil {
+		handler.Done(job)
+	}
+}
+
+// NewID returns a random job ID
+func NewID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
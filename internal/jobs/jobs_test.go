@@ -0,0 +1,311 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"swiftcodes/internal/config"
+	"swiftcodes/internal/migrate"
+	"swiftcodes/internal/store"
+	"swiftcodes/sqlcout"
+)
+
+var errFlaky = errors.New("flaky")
+
+func setupRunner(t *testing.T, workers int) (*Runner, store.Store) {
+	db, err := store.Open(config.DB{Driver: config.DRIVER_SQLITE, Name: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrate.Up(context.Background(), db, config.DRIVER_SQLITE); err != nil {
+		t.Fatalf("error creating tables: %v", err)
+	}
+	queries := store.New(config.DRIVER_SQLITE, db)
+	runner := NewRunner(queries, Options{
+		Workers:     workers,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		Poll:        10 * time.Millisecond,
+		Heartbeat:   10 * time.Millisecond,
+		Lease:       time.Hour,
+	})
+	return runner, queries
+}
+
+// wait polls the job with id until it finished
+func wait(t *testing.T, runner *Runner, id string) Job {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		job, err := runner.Get(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Get(%s) error: %v", id, err)
+		}
+		if job.Finished() {
+			return job
+		}
+	}
+	t.Fatalf("job %s didn't finish", id)
+	return Job{}
+}
+
+func TestRunner(t *testing.T) {
+	runner, _ := setupRunner(t, 2)
+	var done sync.Map
+	runner.Handle("count", Handler{
+		Run: func(ctx context.Context, run *Run) error {
+			var payload struct{ FailTimes int64 }
+			if err := run.Payload(&payload); err != nil {
+				return Permanent(err)
+			}
+			run.SetProgress(func() any { return run.Attempts })
+			switch {
+			case payload.FailTimes < 0:
+				return Permanent(errFlaky)
+			case run.Attempts <= payload.FailTimes:
+				return errFlaky
+			}
+			return nil
+		},
+		Done: func(job Job) { done.Store(job.ID, job.State) },
+	})
+	runner.Start()
+	defer runner.Close()
+
+	if _, err := runner.Enqueue(context.Background(), "", "unknown", nil); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("Enqueue() of an unknown kind error = %v, want %v", err, ErrUnknownKind)
+	}
+
+	tt := []struct {
+		failTimes    int64
+		wantState    string
+		wantAttempts int64
+	}{
+		{0, STATE_SUCCEEDED, 1},
+		{2, STATE_SUCCEEDED, 3},
+		{3, STATE_FAILED, 3},
+		{-1, STATE_FAILED, 1},
+	}
+	for i := 0; i < len(tt); i++ {
+		queued, err := runner.Enqueue(context.Background(), "", "count", map[string]int64{"FailTimes": tt[i].failTimes})
+		if err != nil || queued.State != STATE_QUEUED {
+			t.Fatalf("Enqueue() = %+v, %v, want a queued job", queued, err)
+		}
+		job := wait(t, runner, queued.ID)
+		if job.State != tt[i].wantState || job.Attempts != tt[i].wantAttempts || string(job.Progress) != string(rune('0'+tt[i].wantAttempts)) {
+			t.Errorf("job failing %d times = %+v, want %s after %d attempts", tt[i].failTimes, job, tt[i].wantState, tt[i].wantAttempts)
+		}
+		if (job.State == STATE_FAILED) != (job.Error == errFlaky.Error()) {
+			t.Errorf("job failing %d times has error %q", tt[i].failTimes, job.Error)
+		}
+		if state, _ := done.Load(job.ID); state != job.State {
+			t.Errorf("Done() of job failing %d times got state %v, want %s", tt[i].failTimes, state, job.State)
+		}
+	}
+
+	jobs, err := runner.List(context.Background(), 2)
+	if err != nil || len(jobs) != 2 {
+		t.Errorf("List(2) = %d jobs, %v, want 2", len(jobs), err)
+	}
+	if _, err := runner.Get(context.Background(), "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of an unknown job error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestRunnerConcurrency(t *testing.T) {
+	const WORKERS = 2
+	runner, _ := setupRunner(t, WORKERS)
+	var running, most atomic.Int64
+	runner.Handle("sleep", Handler{Run: func(ctx context.Context, run *Run) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+		}
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}})
+	runner.Start()
+	defer runner.Close()
+
+	var ids []string
+	for i := 0; i < 6; i++ {
+		job, err := runner.Enqueue(context.Background(), "", "sleep", nil)
+		if err != nil {
+			t.Fatalf("Enqueue() error: %v", err)
+		}
+		ids = append(ids, job.ID)
+	}
+	for _, id := range ids {
+		if job := wait(t, runner, id); job.State != STATE_SUCCEEDED {
+			t.Errorf("job %s = %s, want %s", id, job.State, STATE_SUCCEEDED)
+		}
+	}
+	if most.Load() != WORKERS {
+		t.Errorf("jobs running at the same time = %d, want %d", most.Load(), WORKERS)
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	runner, _ := setupRunner(t, 1)
+	started := make(chan struct{}, 1)
+	var done sync.Map
+	runner.Handle("block", Handler{
+		Run: func(ctx context.Context, run *Run) error {
+			run.SetProgress(func() any { return "blocked" })
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Done: func(job Job) { done.Store(job.ID, job.State) },
+	})
+	runner.Start()
+	defer runner.Close()
+
+	running, _ := runner.Enqueue(context.Background(), "", "block", nil)
+	<-started
+	// The only worker is busy, so this one stays queued
+	queued, _ := runner.Enqueue(context.Background(), "", "block", nil)
+	if _, err := runner.Cancel(context.Background(), queued.ID); err != nil {
+		t.Errorf("Cancel() of a queued job error: %v", err)
+	}
+	if _, err := runner.Cancel(context.Background(), running.ID); err != nil {
+		t.Errorf("Cancel() of a running job error: %v", err)
+	}
+	for _, id := range []string{queued.ID, running.ID} {
+		if job := wait(t, runner, id); job.State != STATE_CANCELLED {
+			t.Errorf("cancelled job = %+v, want %s", job, STATE_CANCELLED)
+		}
+	}
+	// The worker saves the progress and calls Done once the job stopped
+	time.Sleep(50 * time.Millisecond)
+	if job, _ := runner.Get(context.Background(), running.ID); string(job.Progress) != `"blocked"` {
+		t.Errorf("progress of the cancelled job = %s, want the last one reported", job.Progress)
+	}
+	for _, id := range []string{queued.ID, running.ID} {
+		if state, _ := done.Load(id); state != STATE_CANCELLED {
+			t.Errorf("Done() of cancelled job got state %v", state)
+		}
+	}
+	if _, err := runner.Cancel(context.Background(), running.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("Cancel() of a finished job error = %v, want %v", err, ErrFinished)
+	}
+	if _, err := runner.Cancel(context.Background(), "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel() of an unknown job error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestRunnerRecovery(t *testing.T) {
+	runner, queries := setupRunner(t, 1)
+	var runs atomic.Int64
+	runner.Handle("resume", Handler{Run: func(ctx context.Context, run *Run) error {
+		runs.Add(1)
+		return nil
+	}})
+
+	// Jobs left running by a process that stopped an hour and a half ago
+	old := timestamp(time.Now().Add(-90 * time.Minute))
+	tt := []struct {
+		id       string
+		attempts int64
+	}{
+		{"stale", 1},
+		{"exhausted", 3},
+	}
+	for i := 0; i < len(tt); i++ {
+		_, err := queries.InsertJob(context.Background(), sqlcout.InsertJobParams{
+			ID: tt[i].id, Kind: "resume", Payload: "null", State: STATE_RUNNING, Attempts: tt[i].attempts, MaxAttempts: 3,
+			RunAfter: old, Progress: "null", CreatedAt: old, UpdatedAt: old,
+		})
+		if err != nil {
+			t.Fatalf("InsertJob(%s) error: %v", tt[i].id, err)
+		}
+	}
+	runner.Start()
+	defer runner.Close()
+	if job := wait(t, runner, "stale"); job.State != STATE_SUCCEEDED || job.Attempts != 2 || runs.Load() != 1 {
+		t.Errorf("stale job = %+v after %d runs, want it run again", job, runs.Load())
+	}
+	if job := wait(t, runner, "exhausted"); job.State != STATE_FAILED || job.Error == "" || job.Attempts != 3 || runs.Load() != 1 {
+		t.Errorf("exhausted job = %+v after %d runs, want it failed without running", job, runs.Load())
+	}
+}
+
+func TestRunnerTakeover(t *testing.T) {
+	_, queries := setupRunner(t, 1)
+	first := NewRunner(queries, Options{Workers: 1, Poll: 10 * time.Millisecond, Heartbeat: 100 * time.Millisecond, Lease: time.Hour})
+	second := NewRunner(queries, Options{Workers: 1, Poll: 10 * time.Millisecond, Heartbeat: 10 * time.Millisecond, Lease: time.Hour})
+	firstStarted, firstStopped := make(chan struct{}, 1), make(chan struct{})
+	var firstDone atomic.Bool
+	first.Handle("lease", Handler{
+		// Finishes as if it succeeded once it is stopped
+		Run: func(ctx context.Context, run *Run) error {
+			firstStarted <- struct{}{}
+			<-ctx.Done()
+			close(firstStopped)
+			return nil
+		},
+		Done: func(job Job) { firstDone.Store(true) },
+	})
+	secondStarted, release := make(chan struct{}, 1), make(chan struct{})
+	second.Handle("lease", Handler{Run: func(ctx context.Context, run *Run) error {
+		secondStarted <- struct{}{}
+		<-release
+		run.SetProgress(func() any { return "second" })
+		return nil
+	}})
+	first.Start()
+	defer first.Close()
+	job, _ := first.Enqueue(context.Background(), "", "lease", nil)
+	<-firstStarted
+
+	// The lease of the first run passes, as if its process stalled, and the second runner claims the job
+	now := time.Now()
+	if _, err := queries.RequeueStaleJobs(context.Background(), sqlcout.RequeueStaleJobsParams{Now: timestamp(now), StaleBefore: timestamp(now.Add(time.Hour))}); err != nil {
+		t.Fatalf("RequeueStaleJobs() error: %v", err)
+	}
+	second.Start()
+	defer second.Close()
+	closeRelease := sync.OnceFunc(func() { close(release) })
+	defer closeRelease()
+	<-secondStarted
+	select {
+	case <-firstStopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first run didn't stop once the job was taken over")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if job, err := first.Get(context.Background(), job.ID); err != nil || job.State != STATE_RUNNING || job.Attempts != 2 {
+		t.Errorf("job after the first run stopped = %+v, %v, want the second attempt running", job, err)
+	}
+
+	closeRelease()
+	if job := wait(t, second, job.ID); job.State != STATE_SUCCEEDED || job.Attempts != 2 || string(job.Progress) != `"second"` {
+		t.Errorf("job = %+v, want finished by the second run", job)
+	}
+	if firstDone.Load() {
+		t.Errorf("Done() called for the run whose job was taken over")
+	}
+}
+
+func TestRunnerClose(t *testing.T) {
+	runner, _ := setupRunner(t, 1)
+	started := make(chan struct{}, 1)
+	runner.Handle("block", Handler{Run: func(ctx context.Context, run *Run) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	runner.Start()
+	job, _ := runner.Enqueue(context.Background(), "", "block", nil)
+	<-started
+	runner.Close()
+
+	// The interrupted attempt doesn't count, the job resumes after a restart
+	if job, err := runner.Get(context.Background(), job.ID); err != nil || job.State != STATE_QUEUED || job.Attempts != 0 {
+		t.Errorf("job after Close() = %+v, %v, want queued again", job, err)
+	}
+}
@@ -0,0 +1,393 @@
+// Package migrate applies the versioned schema migrations embedded in the binary.
+//
+// Migrations live in migrations/<driver>/NNNN_name.up.sql with a matching NNNN_name.down.sql, or in
+// another directory of the same layout chosen with SetDir.
+// Statements in a file are separated by a semicolon at the end of a line. Every applied
+// migration is recorded in the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"swiftcodes/internal/config"
+)
+
+//go:embed migrations
+var embedded embed.FS
+
+// files holds a directory of migrations per driver
+var files fs.FS = mustSub(embedded, "migrations")
+
+var (
+	ErrSchemaTooOld = errors.New("database schema is older than expected")
+	ErrSchemaTooNew = errors.New("database schema is newer than expected")
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at VARCHAR(64) NOT NULL
+)`
+
+// SetDir reads the migrations from dir instead of the embedded ones, or from the embedded ones again if dir is empty
+func SetDir(dir string) error {
+	if dir == "" {
+		files = mustSub(embedded, "migrations")
+		return nil
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("migrations directory %s doesn't exist", dir)
+	}
+	files = os.DirFS(dir)
+	return nil
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration known to the binary, the database or both
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt string
+	// Known is false for migrations applied by a newer binary
+	Known bool
+}
+
+// Migrations returns the migrations of driver in version order
+func Migrations(driver string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, driver)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q: %w", driver, err)
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, up := strings.CutSuffix(name, ".up.sql")
+		if !up {
+			var down bool
+			if base, down = strings.CutSuffix(name, ".down.sql"); !down {
+				continue
+			}
+		}
+		number, label, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(number, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s doesn't start with a positive version number", name)
+		}
+		content, err := fs.ReadFile(files, path.Join(driver, name))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, m.Name, label)
+		}
+		if up {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest returns the schema version the binary expects for driver
+func Latest(driver string) (int64, error) {
+	migrations, err := Migrations(driver)
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// Check returns an error unless db has exactly the migrations the binary expects applied. It only reads
+// the database, one without schema_migrations has none applied.
+func Check(ctx context.Context, db *sql.DB, driver string) error {
+	migrations, err := Migrations(driver)
+	if err != nil {
+		return err
+	}
+	applied := make(map[int64]bool)
+	exists, err := hasMigrationsTable(ctx, db, driver)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if applied, err = queryVersions(ctx, db); err != nil {
+			return err
+		}
+	}
+	var missing []string
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			missing = append(missing, strconv.FormatInt(m.Version, 10))
+		}
+		delete(applied, m.Version)
+	}
+	if len(applied) > 0 {
+		var unknown []int64
+		for version := range applied {
+			unknown = append(unknown, version)
+		}
+		sort.Slice(unknown, func(i, j int) bool { return unknown[i] < unknown[j] })
+		return fmt.Errorf("%w: unknown migrations %v applied, upgrade the server", ErrSchemaTooNew, unknown)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: migrations %s not applied, run the up migrations", ErrSchemaTooOld, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// hasMigrationsTable reports whether schema_migrations exists, without creating it
+func hasMigrationsTable(ctx context.Context, db *sql.DB, driver string) (bool, error) {
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'"
+	switch driver {
+	case config.DRIVER_SQLITE:
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'"
+	case config.DRIVER_POSTGRES:
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'"
+	}
+	var n int
+	if err := db.QueryRowContext(ctx, query).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Up applies the pending migrations and returns them. Databases created before migrations existed
+// are adopted by recording the first migration without running it.
+func Up(ctx context.Context, db *sql.DB, driver string) ([]Migration, error) {
+	migrations, err := Migrations(driver)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 && len(migrations) > 0 && hasLegacyTables(ctx, db) {
+		if err := record(ctx, db, driver, migrations[0]); err != nil {
+			return nil, err
+		}
+		applied[migrations[0].Version] = true
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		if err := run(ctx, db, m.Up, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, insertQuery(driver), m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+			return err
+		}); err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Down rolls back the last steps applied migrations and returns them
+func Down(ctx context.Context, db *sql.DB, driver string, steps int) ([]Migration, error) {
+	migrations, err := Migrations(driver)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if !applied[m.Version] {
+			continue
+		}
+		if m.Down == "" {
+			return done, fmt.Errorf("migration %d_%s can't be rolled back, it has no down file", m.Version, m.Name)
+		}
+		if err := run(ctx, db, m.Down, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, deleteQuery(driver), m.Version)
+			return err
+		}); err != nil {
+			return done, fmt.Errorf("rolling back migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Statuses lists the migrations of driver and whether they are applied to db
+func Statuses(ctx context.Context, db *sql.DB, driver string) ([]Status, error) {
+	migrations, err := Migrations(driver)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, createTable); err != nil {
+		return nil, fmt.Errorf("couldn't create schema_migrations: %w", err)
+	}
+	rows, err := db.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byVersion := make(map[int64]*Status)
+	for _, m := range migrations {
+		byVersion[m.Version] = &Status{Version: m.Version, Name: m.Name, Known: true}
+	}
+	for rows.Next() {
+		var s Status
+		if err := rows.Scan(&s.Version, &s.Name, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		if known, ok := byVersion[s.Version]; ok {
+			known.Applied = true
+			known.AppliedAt = s.AppliedAt
+			continue
+		}
+		s.Applied = true
+		byVersion[s.Version] = &s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, s := range byVersion {
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+func appliedVersions(ctx context.Context, db *sql.DB) (map[int64]bool, error) {
+	if _, err := db.ExecContext(ctx, createTable); err != nil {
+		return nil, fmt.Errorf("couldn't create schema_migrations: %w", err)
+	}
+	return queryVersions(ctx, db)
+}
+
+func queryVersions(ctx context.Context, db *sql.DB) (map[int64]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// hasLegacyTables reports whether the tables were created by the schema file used before migrations
+func hasLegacyTables(ctx context.Context, db *sql.DB) bool {
+	var n int
+	return db.QueryRowContext(ctx, "SELECT COUNT(*) FROM countries").Scan(&n) == nil
+}
+
+func record(ctx context.Context, db *sql.DB, driver string, m Migration) error {
+	_, err := db.ExecContext(ctx, insertQuery(driver), m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// run executes script and then bookkeeping in one transaction. MySQL commits DDL statements
+// implicitly, so there a failed migration may be partially applied.
+func run(ctx context.Context, db *sql.DB, script string, bookkeeping func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, statement := range Statements(script) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if err := bookkeeping(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Statements splits script at semicolons ending a line, so no driver needs multi-statement support
+func Statements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(strings.ReplaceAll(script, "\r\n", "\n"), "\n") {
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			statements = appendStatement(statements, current.String())
+			current.Reset()
+		}
+	}
+	return appendStatement(statements, current.String())
+}
+
+// appendStatement appends statement unless it holds nothing but whitespace and comments
+func appendStatement(statements []string, statement string) []string {
+	for _, line := range strings.Split(statement, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return append(statements, strings.TrimSpace(statement))
+		}
+	}
+	return statements
+}
+
+func insertQuery(driver string) string {
+	if driver == config.DRIVER_POSTGRES {
+		return "INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)"
+	}
+	return "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"
+}
+
+func deleteQuery(driver string) string {
+	if driver == config.DRIVER_POSTGRES {
+		return "DELETE FROM schema_migrations WHERE version = $1"
+	}
+	return "DELETE FROM schema_migrations WHERE version = ?"
+}
@@ -0,0 +1,178 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"swiftcodes/internal/config"
+
+	_ "modernc.org/sqlite"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	cfg := config.DB{Driver: config.DRIVER_SQLITE, Name: filepath.Join(t.TempDir(), "test.db")}
+	db, err := sql.Open("sqlite", cfg.DSN())
+	if err != nil {
+		t.Fatalf("sql.Open() error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrations(t *testing.T) {
+	drivers := []string{config.DRIVER_MYSQL, config.DRIVER_SQLITE, config.DRIVER_POSTGRES}
+	for i := 0; i < len(drivers); i++ {
+		migrations, err := Migrations(drivers[i])
+		if err != nil {
+			t.Fatalf(`Migrations("%v") error: %v`, drivers[i], err)
+		}
+		if len(migrations) == 0 || migrations[0].Version != 1 {
+			t.Errorf(`Migrations("%v") doesn't start at version 1`, drivers[i])
+		}
+		for j := 0; j < len(migrations); j++ {
+			if migrations[j].Down == "" {
+				t.Errorf(`Migrations("%v") migration %d has no down file`, drivers[i], migrations[j].Version)
+			}
+		}
+	}
+	if _, err := Migrations("oracle"); err == nil {
+		t.Errorf(`Migrations("oracle") error = nil, want error`)
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	latest, err := Latest(config.DRIVER_SQLITE)
+	if err != nil {
+		t.Fatalf("Latest() error: %v", err)
+	}
+
+	if err := Check(ctx, db, config.DRIVER_SQLITE); !errors.Is(err, ErrSchemaTooOld) {
+		t.Errorf("Check() on empty database = %v, want %v", err, ErrSchemaTooOld)
+	}
+	if _, err := db.Exec("SELECT 1 FROM schema_migrations"); err == nil {
+		t.Errorf("Check() on empty database created schema_migrations")
+	}
+	applied, err := Up(ctx, db, config.DRIVER_SQLITE)
+	if err != nil || int64(len(applied)) != latest {
+		t.Fatalf("Up() applied %d migrations, error %v, want %d", len(applied), err, latest)
+	}
+	if err := Check(ctx, db, config.DRIVER_SQLITE); err != nil {
+		t.Errorf("Check() after Up() = %v, want nil", err)
+	}
+	// A migration missing in the middle isn't hidden by the later ones
+	if _, err := db.Exec("DELETE FROM schema_migrations WHERE version = 2"); err != nil {
+		t.Fatalf("error forgetting migration 2: %v", err)
+	}
+	if err := Check(ctx, db, config.DRIVER_SQLITE); !errors.Is(err, ErrSchemaTooOld) || !strings.Contains(err.Error(), "migrations 2 not applied") {
+		t.Errorf("Check() without migration 2 = %v, want %v naming it", err, ErrSchemaTooOld)
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (2, 'create_imports', '')"); err != nil {
+		t.Fatalf("error recording migration 2: %v", err)
+	}
+	if applied, err := Up(ctx, db, config.DRIVER_SQLITE); err != nil || len(applied) != 0 {
+		t.Errorf("second Up() applied %d migrations, error %v, want 0", len(applied), err)
+	}
+	if _, err := db.Exec("INSERT INTO countries (country_iso2, country_name) VALUES ('PL', 'POLAND')"); err != nil {
+		t.Errorf("insert after Up() error: %v", err)
+	}
+
+	statuses, err := Statuses(ctx, db, config.DRIVER_SQLITE)
+	if err != nil || len(statuses) != int(latest) || !statuses[0].Applied || !statuses[0].Known {
+		t.Errorf("Statuses() = %+v, %v, want %d applied migrations", statuses, err, latest)
+	}
+
+	if _, err := Down(ctx, db, config.DRIVER_SQLITE, int(latest)); err != nil {
+		t.Fatalf("Down() error: %v", err)
+	}
+	if err := Check(ctx, db, config.DRIVER_SQLITE); !errors.Is(err, ErrSchemaTooOld) {
+		t.Errorf("Check() after Down() = %v, want %v", err, ErrSchemaTooOld)
+	}
+	if _, err := db.Exec("SELECT 1 FROM countries"); err == nil {
+		t.Errorf("countries table still exists after Down()")
+	}
+
+	if _, err := Up(ctx, db, config.DRIVER_SQLITE); err != nil {
+		t.Fatalf("Up() after Down() error: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, 'future', '')"); err != nil {
+		t.Fatalf("error recording future migration: %v", err)
+	}
+	if err := Check(ctx, db, config.DRIVER_SQLITE); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Check() with future migration = %v, want %v", err, ErrSchemaTooNew)
+	}
+	statuses, _ = Statuses(ctx, db, config.DRIVER_SQLITE)
+	if last := statuses[len(statuses)-1]; last.Version != 9999 || last.Known {
+		t.Errorf("Statuses() last = %+v, want unknown migration 9999", last)
+	}
+}
+
+func TestUpAdoptsLegacySchema(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	migrations, _ := Migrations(config.DRIVER_SQLITE)
+	if _, err := db.Exec(migrations[0].Up); err != nil {
+		t.Fatalf("error creating legacy tables: %v", err)
+	}
+
+	applied, err := Up(ctx, db, config.DRIVER_SQLITE)
+	if err != nil {
+		t.Fatalf("Up() on legacy schema error: %v", err)
+	}
+	if len(applied) != len(migrations)-1 {
+		t.Errorf("Up() on legacy schema applied %d migrations, want %d", len(applied), len(migrations)-1)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	tt := []struct {
+		script string
+		want   []string
+	}{
+		{"", nil},
+		{"-- only a comment\n", nil},
+		{"CREATE TABLE a (x INT);\r\n\r\nCREATE TABLE b (\r\n    y INT\r\n);\r\n", []string{"CREATE TABLE a (x INT);", "CREATE TABLE b (\n    y INT\n);"}},
+		{"-- comment\nDROP TABLE a;\nDROP TABLE b", []string{"-- comment\nDROP TABLE a;", "DROP TABLE b"}},
+	}
+	for i := 0; i < len(tt); i++ {
+		got := Statements(tt[i].script)
+		if !reflect.DeepEqual(got, tt[i].want) {
+			t.Errorf(`Statements("%v") = %q, want %q`, tt[i].script, got, tt[i].want)
+		}
+	}
+}
+
+func TestSetDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, config.DRIVER_SQLITE), 0o755); err != nil {
+		t.Fatalf("error creating migrations directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, config.DRIVER_SQLITE, "0099_custom.up.sql"), []byte("CREATE TABLE custom (id INTEGER);\n"), 0o600); err != nil {
+		t.Fatalf("error writing migration: %v", err)
+	}
+	defer SetDir("")
+
+	if err := SetDir(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("SetDir() of a missing directory error = nil, want error")
+	}
+	if err := SetDir(dir); err != nil {
+		t.Fatalf("SetDir() error: %v", err)
+	}
+	migrations, err := Migrations(config.DRIVER_SQLITE)
+	if err != nil || len(migrations) != 1 || migrations[0].Version != 99 || migrations[0].Name != "custom" {
+		t.Errorf("Migrations() from directory = %+v, %v, want only 0099_custom", migrations, err)
+	}
+	if err := SetDir(""); err != nil {
+		t.Fatalf(`SetDir("") error: %v`, err)
+	}
+	if latest, err := Latest(config.DRIVER_SQLITE); err != nil || latest == 99 {
+		t.Errorf(`Latest() after SetDir("") = %v, %v, want the embedded migrations`, latest, err)
+	}
+}
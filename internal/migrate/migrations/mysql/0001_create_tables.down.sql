@@ -0,0 +1,3 @@
+DROP TABLE swift_codes;
+
+DROP TABLE countries;
@@ -1,9 +1,9 @@
-CREATE TABLE IF NOT EXISTS countries (
+CREATE TABLE countries (
     country_iso2 VARCHAR(10) PRIMARY KEY,
     country_name TEXT NOT NULL
 );
 
-CREATE TABLE IF NOT EXISTS swift_codes (
+CREATE TABLE swift_codes (
     swift_code VARCHAR(50) PRIMARY KEY,
     address TEXT NOT NULL,
     bank_name TEXT NOT NULL,
@@ -0,0 +1,7 @@
+CREATE TABLE imports (
+    id VARCHAR(32) NOT NULL PRIMARY KEY,
+    file_name VARCHAR(255) NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    signer VARCHAR(255) NOT NULL,
+    imported_at VARCHAR(64) NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE swift_codes DROP FOREIGN KEY swift_codes_import_id_fk;
+ALTER TABLE swift_codes DROP COLUMN import_id, DROP COLUMN actor;
@@ -0,0 +1,4 @@
+ALTER TABLE swift_codes
+    ADD COLUMN import_id VARCHAR(32) NULL,
+    ADD COLUMN actor VARCHAR(255) NOT NULL DEFAULT '',
+    ADD CONSTRAINT swift_codes_import_id_fk FOREIGN KEY (import_id) REFERENCES imports (id);
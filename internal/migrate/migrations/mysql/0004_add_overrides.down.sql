@@ -0,0 +1 @@
+ALTER TABLE swift_codes DROP COLUMN address_override, DROP COLUMN bank_name_override;
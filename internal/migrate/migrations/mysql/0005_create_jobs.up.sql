@@ -0,0 +1,16 @@
+CREATE TABLE jobs (
+    id VARCHAR(32) NOT NULL PRIMARY KEY,
+    kind VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    state VARCHAR(16) NOT NULL,
+    attempts BIGINT NOT NULL,
+    max_attempts BIGINT NOT NULL,
+    run_after VARCHAR(64) NOT NULL,
+    progress TEXT NOT NULL,
+    error TEXT NOT NULL,
+    created_at VARCHAR(64) NOT NULL,
+    updated_at VARCHAR(64) NOT NULL,
+    finished_at VARCHAR(64) NOT NULL
+);
+
+CREATE INDEX jobs_state_run_after ON jobs (state, run_after);
@@ -0,0 +1 @@
+ALTER TABLE swift_codes DROP INDEX swift_codes_bank8, DROP COLUMN bank8, DROP COLUMN is_headquarter;
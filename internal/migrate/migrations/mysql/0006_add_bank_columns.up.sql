@@ -0,0 +1,6 @@
+-- Headquarters lookups find the branches of a bank through an index instead of computing LEFT and RIGHT
+-- of every code
+ALTER TABLE swift_codes
+    ADD COLUMN bank8 VARCHAR(8) AS (LEFT(swift_code, 8)) STORED,
+    ADD COLUMN is_headquarter BOOLEAN AS (UPPER(RIGHT(swift_code, 3)) = 'XXX') STORED,
+    ADD INDEX swift_codes_bank8 (bank8, is_headquarter);
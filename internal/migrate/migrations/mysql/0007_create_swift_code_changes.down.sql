@@ -0,0 +1 @@
+DROP TABLE swift_code_changes;
@@ -0,0 +1,5 @@
+DROP TABLE swift_codes;
+
+DROP TABLE countries;
+
+DROP COLLATION case_insensitive;
@@ -0,0 +1,15 @@
+-- Compare codes case-insensitively like the default MariaDB collation does
+CREATE COLLATION case_insensitive (provider = icu, locale = 'und-u-ks-level2', deterministic = false);
+
+CREATE TABLE countries (
+    country_iso2 VARCHAR(10) COLLATE case_insensitive PRIMARY KEY,
+    country_name TEXT NOT NULL
+);
+
+CREATE TABLE swift_codes (
+    swift_code VARCHAR(50) COLLATE case_insensitive PRIMARY KEY,
+    address TEXT NOT NULL,
+    bank_name TEXT NOT NULL,
+    country_iso2 VARCHAR(10) COLLATE case_insensitive NOT NULL,
+    FOREIGN KEY (country_iso2) REFERENCES countries (country_iso2)
+);
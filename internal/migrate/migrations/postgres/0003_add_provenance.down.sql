@@ -0,0 +1,2 @@
+DROP INDEX swift_codes_import_id;
+ALTER TABLE swift_codes DROP COLUMN import_id, DROP COLUMN actor;
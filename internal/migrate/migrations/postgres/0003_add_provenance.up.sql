@@ -0,0 +1,5 @@
+ALTER TABLE swift_codes
+    ADD COLUMN import_id VARCHAR(32) REFERENCES imports (id),
+    ADD COLUMN actor VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX swift_codes_import_id ON swift_codes (import_id);
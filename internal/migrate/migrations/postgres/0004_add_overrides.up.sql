@@ -0,0 +1,3 @@
+ALTER TABLE swift_codes
+    ADD COLUMN address_override BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN bank_name_override BOOLEAN NOT NULL DEFAULT FALSE;
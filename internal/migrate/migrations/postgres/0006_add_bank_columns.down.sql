@@ -0,0 +1,2 @@
+DROP INDEX swift_codes_bank8;
+ALTER TABLE swift_codes DROP COLUMN bank8, DROP COLUMN is_headquarter;
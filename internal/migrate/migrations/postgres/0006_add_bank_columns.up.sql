@@ -0,0 +1,7 @@
+-- Headquarters lookups find the branches of a bank through an index instead of computing LEFT and RIGHT
+-- of every code
+ALTER TABLE swift_codes
+    ADD COLUMN bank8 VARCHAR(8) COLLATE case_insensitive GENERATED ALWAYS AS (LEFT(swift_code, 8)) STORED,
+    ADD COLUMN is_headquarter BOOLEAN GENERATED ALWAYS AS (UPPER(RIGHT(swift_code, 3)) = 'XXX') STORED;
+
+CREATE INDEX swift_codes_bank8 ON swift_codes (bank8, is_headquarter);
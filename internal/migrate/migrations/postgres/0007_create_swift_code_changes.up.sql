@@ -0,0 +1,14 @@
+-- The previous row of every code a sync batch changed or removed, restored when the batch is rolled back
+CREATE TABLE swift_code_changes (
+    import_id VARCHAR(32) NOT NULL REFERENCES imports (id),
+    swift_code VARCHAR(50) COLLATE case_insensitive NOT NULL,
+    address TEXT NOT NULL,
+    bank_name TEXT NOT NULL,
+    country_iso2 VARCHAR(10) COLLATE case_insensitive NOT NULL,
+    previous_import_id VARCHAR(32),
+    actor VARCHAR(255) NOT NULL,
+    address_override BOOLEAN NOT NULL,
+    bank_name_override BOOLEAN NOT NULL,
+    removed BOOLEAN NOT NULL,
+    PRIMARY KEY (import_id, swift_code)
+);
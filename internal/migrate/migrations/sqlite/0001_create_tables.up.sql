@@ -0,0 +1,12 @@
+CREATE TABLE countries (
+    country_iso2 TEXT NOT NULL COLLATE NOCASE PRIMARY KEY,
+    country_name TEXT NOT NULL
+);
+
+CREATE TABLE swift_codes (
+    swift_code TEXT NOT NULL COLLATE NOCASE PRIMARY KEY,
+    address TEXT NOT NULL,
+    bank_name TEXT NOT NULL,
+    country_iso2 TEXT NOT NULL COLLATE NOCASE,
+    FOREIGN KEY (country_iso2) REFERENCES countries (country_iso2)
+);
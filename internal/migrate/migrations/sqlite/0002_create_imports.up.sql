@@ -0,0 +1,7 @@
+CREATE TABLE imports (
+    id TEXT NOT NULL PRIMARY KEY,
+    file_name TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    signer TEXT NOT NULL,
+    imported_at TEXT NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP INDEX swift_codes_import_id;
+ALTER TABLE swift_codes DROP COLUMN actor;
+ALTER TABLE swift_codes DROP COLUMN import_id;
@@ -0,0 +1,4 @@
+ALTER TABLE swift_codes ADD COLUMN import_id TEXT REFERENCES imports (id);
+ALTER TABLE swift_codes ADD COLUMN actor TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX swift_codes_import_id ON swift_codes (import_id);
@@ -0,0 +1,2 @@
+ALTER TABLE swift_codes DROP COLUMN bank_name_override;
+ALTER TABLE swift_codes DROP COLUMN address_override;
@@ -0,0 +1,2 @@
+ALTER TABLE swift_codes ADD COLUMN address_override BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE swift_codes ADD COLUMN bank_name_override BOOLEAN NOT NULL DEFAULT FALSE;
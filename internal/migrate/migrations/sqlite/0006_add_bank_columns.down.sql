@@ -0,0 +1,3 @@
+DROP INDEX swift_codes_bank8;
+ALTER TABLE swift_codes DROP COLUMN is_headquarter;
+ALTER TABLE swift_codes DROP COLUMN bank8;
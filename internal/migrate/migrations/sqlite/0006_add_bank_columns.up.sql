@@ -0,0 +1,7 @@
+-- Headquarters lookups find the branches of a bank through an index instead of computing substr of every
+-- code. SQLite can only add virtual generated columns, which are stored in the index all the same. substr
+-- drops the NOCASE collation of swift_code, so the comparison names it again.
+ALTER TABLE swift_codes ADD COLUMN bank8 TEXT COLLATE NOCASE GENERATED ALWAYS AS (substr(swift_code, 1, 8)) VIRTUAL;
+ALTER TABLE swift_codes ADD COLUMN is_headquarter BOOLEAN GENERATED ALWAYS AS (substr(swift_code, -3) = 'XXX' COLLATE NOCASE) VIRTUAL;
+
+CREATE INDEX swift_codes_bank8 ON swift_codes (bank8, is_headquarter);
@@ -0,0 +1,14 @@
+-- The previous row of every code a sync batch changed or removed, restored when the batch is rolled back
+CREATE TABLE swift_code_changes (
+    import_id TEXT NOT NULL REFERENCES imports (id),
+    swift_code TEXT NOT NULL COLLATE NOCASE,
+    address TEXT NOT NULL,
+    bank_name TEXT NOT NULL,
+    country_iso2 TEXT NOT NULL COLLATE NOCASE,
+    previous_import_id TEXT,
+    actor TEXT NOT NULL,
+    address_override BOOLEAN NOT NULL,
+    bank_name_override BOOLEAN NOT NULL,
+    removed BOOLEAN NOT NULL,
+    PRIMARY KEY (import_id, swift_code)
+);
@@ -0,0 +1,44 @@
+// Package seed embeds the swift codes loaded into new databases, so the server runs from any directory.
+package seed
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"io"
+	"sync"
+)
+
+// FILE_NAME is the name of the embedded dataset, a TSV file in the import format
+const FILE_NAME = "swiftcodes.tsv"
+
+//go:embed swiftcodes.tsv
+var data []byte
+
+// Dataset identifies the embedded dataset
+type Dataset struct {
+	// Version is the start of the SHA-256 digest, so it changes whenever the data does
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+	// Rows is the number of data rows, without the header
+	Rows int `json:"rows"`
+}
+
+// Open returns a reader of the embedded dataset
+func Open() io.Reader {
+	return bytes.NewReader(data)
+}
+
+// Embedded describes the embedded dataset
+var Embedded = sync.OnceValue(func() Dataset {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	rows := 0
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			rows++
+		}
+	}
+	return Dataset{Version: digest[:12], SHA256: digest, Rows: max(rows-1, 0)}
+})
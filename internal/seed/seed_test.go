@@ -0,0 +1,23 @@
+package seed_test
+
+import (
+	"testing"
+
+	"swiftcodes/internal/initdb"
+	"swiftcodes/internal/seed"
+)
+
+func TestEmbedded(t *testing.T) {
+	dataset := seed.Embedded()
+	if len(dataset.SHA256) != 64 || dataset.Version != dataset.SHA256[:12] {
+		t.Errorf("Embedded() = %+v, want a SHA-256 digest and its first 12 digits as version", dataset)
+	}
+
+	records, report, err := initdb.ReadAll(initdb.NewReader(seed.Open(), '\t'), 0)
+	if err != nil {
+		t.Fatalf("ReadAll(Open()) error = %v, errors %v", err, report.Errors)
+	}
+	if len(records) != dataset.Rows || dataset.Rows == 0 {
+		t.Errorf("ReadAll(Open()) read %v records, want %v", len(records), dataset.Rows)
+	}
+}
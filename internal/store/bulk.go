@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"swiftcodes/internal/config"
+	"swiftcodes/sqlcout"
+)
+
+// BATCH_SIZE is the number of rows inserted per statement, well below the placeholder limits of
+// every driver (65535 for MySQL and PostgreSQL, 32766 for SQLite)
+const BATCH_SIZE = 500
+
+// insertColumns is the number of columns set by InsertSwiftCodes
+const insertColumns = 6
+
+// InsertSwiftCodes isn't generated, as sqlc can't build INSERT statements with a variable number of rows.
+// Run it in a transaction, see InTx, so that a failed batch doesn't leave the earlier ones behind.
+func (s mappedStore) InsertSwiftCodes(ctx context.Context, codes []sqlcout.InsertSwiftCodeParams) (int64, error) {
+	var inserted int64
+	for start := 0; start < len(codes); start += BATCH_SIZE {
+		batch := codes[start:min(start+BATCH_SIZE, len(codes))]
+		args := make([]interface{}, 0, insertColumns*len(batch))
+		for _, code := range batch {
+			args = append(args, code.SwiftCode, code.Address, code.BankName, code.CountryISO2, code.ImportID, code.Actor)
+		}
+		result, err := s.db.ExecContext(ctx, insertSwiftCodesQuery(s.driver, len(batch)), args...)
+		if err != nil {
+			return inserted, mapError(ctx, err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return inserted, mapError(ctx, err)
+		}
+		inserted += rows
+	}
+	return inserted, nil
+}
+
+// insertSwiftCodesQuery returns an INSERT statement for rows codes, numbering the placeholders for PostgreSQL
+func insertSwiftCodesQuery(driver string, rows int) string {
+	var query strings.Builder
+	query.WriteString("INSERT INTO swift_codes (swift_code, address, bank_name, country_iso2, import_id, actor) VALUES ")
+	for row := 0; row < rows; row++ {
+		if row > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString("(")
+		for column := 0; column < insertColumns; column++ {
+			if column > 0 {
+				query.WriteString(", ")
+			}
+			if driver == config.DRIVER_POSTGRES {
+				query.WriteString("$" + strconv.Itoa(insertColumns*row+column+1))
+			} else {
+				query.WriteString("?")
+			}
+		}
+		query.WriteString(")")
+	}
+	return query.String()
+}
@@ -0,0 +1,323 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"swiftcodes/internal/cache"
+	"swiftcodes/sqlcout"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Names of the caches of CachedStore, as reported by Stats
+const (
+	CACHE_CODE          = "code"
+	CACHE_COUNTRY       = "country"
+	CACHE_COUNTRY_CODES = "country_codes"
+)
+
+// CachedStore answers code and country lookups from in-process LRU caches, and from a cache shared by
+// the API replicas if one is configured. Writes through it drop the entries they affect: those of the bank
+// of the code, which holds its headquarters and branches, and those of its country. Without a shared
+// cache, writes by other processes show once the entries expired.
+type CachedStore struct {
+	Store
+	codes        *cache.LRU[[]sqlcout.GetCodeDetailsRow]
+	countries    *cache.LRU[sqlcout.Country]
+	countryCodes *cache.LRU[[]sqlcout.GetCodeDetailsByCountryCodeRow]
+	shared       cache.Shared
+	sharedStats  map[string]*counter
+	flights      singleflight.Group
+}
+
+// counter counts the hits and misses of the shared cache
+type counter struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func (c *counter) count(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// NewCached caches the lookups of queries, each cache holding up to size entries for ttl
+func NewCached(queries Store, size int, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		Store:        queries,
+		codes:        cache.NewLRU[[]sqlcout.GetCodeDetailsRow](size, ttl),
+		countries:    cache.NewLRU[sqlcout.Country](size, ttl),
+		countryCodes: cache.NewLRU[[]sqlcout.GetCodeDetailsByCountryCodeRow](size, ttl),
+		sharedStats: map[string]*counter{
+			CACHE_CODE:          {},
+			CACHE_COUNTRY:       {},
+			CACHE_COUNTRY_CODES: {},
+		},
+	}
+}
+
+// Share puts shared between the in-process caches and the database, and drops the entries that other
+// replicas invalidate from the in-process caches
+func (s *CachedStore) Share(ctx context.Context, shared cache.Shared) error {
+	if err := shared.Subscribe(ctx, s.invalidateLocal); err != nil {
+		return err
+	}
+	s.shared = shared
+	return nil
+}
+
+// Close closes the shared cache, if any
+func (s *CachedStore) Close() error {
+	if s.shared == nil {
+		return nil
+	}
+	return s.shared.Close()
+}
+
+// Stats returns the counts of every in-process cache by name
+func (s *CachedStore) Stats() map[string]cache.Stats {
+	return map[string]cache.Stats{
+		CACHE_CODE:          s.codes.Stats(),
+		CACHE_COUNTRY:       s.countries.Stats(),
+		CACHE_COUNTRY_CODES: s.countryCodes.Stats(),
+	}
+}
+
+// SharedStats returns the hits and misses of the shared cache by name, nil without a shared cache
+func (s *CachedStore) SharedStats() map[string]cache.Stats {
+	if s.shared == nil {
+		return nil
+	}
+	stats := make(map[string]cache.Stats, len(s.sharedStats))
+	for name, counter := range s.sharedStats {
+		stats[name] = cache.Stats{Hits: counter.hits.Load(), Misses: counter.misses.Load()}
+	}
+	return stats
+}
+
+// bankTag tags the entries holding codes of the bank of swiftCode, its headquarters and branches share it
+func bankTag(swiftCode string) string {
+	return "bank:" + strings.ToUpper(swiftCode[:min(len(swiftCode), 8)])
+}
+
+func countryTag(countryISO2 string) string {
+	return "country:" + strings.ToUpper(countryISO2)
+}
+
+// countryNameTag tags the country entries, which only writes of countries drop
+func countryNameTag(countryISO2 string) string {
+	return "country_name:" + strings.ToUpper(countryISO2)
+}
+
+// invalidate drops the entries affected by a write of the codes of country, which may be empty if the
+// write doesn't change it
+func (s *CachedStore) invalidate(ctx context.Context, country string, swiftCodes ...string) {
+	tags := make([]string, 0, len(swiftCodes)+1)
+	for _, swiftCode := range swiftCodes {
+		tags = append(tags, bankTag(swiftCode))
+	}
+	if country != "" {
+		tags = append(tags, countryTag(country))
+	}
+	s.invalidateAll(ctx, tags...)
+}
+
+// invalidateAll drops the entries carrying tags from the caches and has the other replicas drop them
+func (s *CachedStore) invalidateAll(ctx context.Context, tags ...string) {
+	s.invalidateLocal(tags)
+	if s.shared == nil {
+		return
+	}
+	// The write is done, so the entries must go even if the client went away
+	if err := s.shared.Invalidate(context.WithoutCancel(ctx), tags...); err != nil {
+		log.Print("Failed to invalidate the shared cache: ", err)
+	}
+}
+
+// InvalidateAll drops every code entry from the caches and has the other replicas drop theirs, after writes
+// that didn't go through s, such as those of a transaction or of a background import
+func (s *CachedStore) InvalidateAll(ctx context.Context) {
+	s.invalidateAll(ctx, cache.ALL)
+}
+
+// invalidateLocal drops the entries carrying tags from the in-process caches, every code entry for ALL
+func (s *CachedStore) invalidateLocal(tags []string) {
+	if slices.Contains(tags, cache.ALL) {
+		s.codes.Clear()
+		s.countryCodes.Clear()
+		return
+	}
+	s.codes.Invalidate(tags...)
+	s.countries.Invalidate(tags...)
+	s.countryCodes.Invalidate(tags...)
+}
+
+// lookup answers key from local, then from the shared cache under name, and then with query, storing
+// what it found in the caches it missed. Concurrent misses of key share one read, unless an invalidation
+// came between them.
+func lookup[V any](ctx context.Context, s *CachedStore, local *cache.LRU[V], name string, key string, query func() (V, error), tags func(V) []string) (V, error) {
+	if value, ok := local.Get(key); ok {
+		return value, nil
+	}
+	generation := local.Generation()
+	flight := name + ":" + strconv.FormatUint(generation, 10) + ":" + key
+	results := s.flights.DoChan(flight, func() (any, error) {
+		value, err := read(ctx, s, local, generation, name, key, query, tags)
+		if err != nil && ctx.Err() != nil {
+			// The request that started the read went away, which says nothing about the others
+			return value, interrupted{err}
+		}
+		return value, err
+	})
+	var zero V
+	select {
+	case result := <-results:
+		var cause interrupted
+		if errors.As(result.Err, &cause) {
+			if ctx.Err() != nil {
+				return zero, cause.err
+			}
+			return read(ctx, s, local, generation, name, key, query, tags)
+		}
+		if result.Err != nil {
+			return zero, result.Err
+		}
+		return result.Val.(V), nil
+	case <-ctx.Done():
+		return zero, mapError(ctx, ctx.Err())
+	}
+}
+
+// interrupted is the error of a shared read whose request was cancelled or timed out
+type interrupted struct {
+	err error
+}
+
+func (e interrupted) Error() string { return e.err.Error() }
+
+// read answers key from the shared cache, then with query. Failures of the shared cache are logged and
+// skipped.
+func read[V any](ctx context.Context, s *CachedStore, local *cache.LRU[V], generation uint64, name string, key string, query func() (V, error), tags func(V) []string) (V, error) {
+	sharedKey := name + ":" + key
+	var sharedGeneration uint64
+	shared := s.shared
+	if shared != nil {
+		encoded, ok, err := shared.Get(ctx, sharedKey)
+		s.sharedStats[name].count(ok)
+		if ok {
+			var value V
+			if err = json.Unmarshal(encoded, &value); err == nil {
+				local.Set(generation, key, value, tags(value)...)
+				return value, nil
+			}
+		}
+		if err == nil {
+			sharedGeneration, err = shared.Generation(ctx)
+		}
+		if err != nil {
+			log.Print("Failed to read the shared cache: ", err)
+			shared = nil
+		}
+	}
+	value, err := query()
+	if err != nil {
+		return value, err
+	}
+	local.Set(generation, key, value, tags(value)...)
+	if shared != nil {
+		encoded, err := json.Marshal(value)
+		if err == nil {
+			err = shared.Set(ctx, sharedGeneration, sharedKey, encoded, tags(value)...)
+		}
+		if err != nil {
+			log.Print("Failed to fill the shared cache: ", err)
+		}
+	}
+	return value, nil
+}
+
+func (s *CachedStore) GetCodeDetails(ctx context.Context, arg sqlcout.GetCodeDetailsParams) ([]sqlcout.GetCodeDetailsRow, error) {
+	return lookup(ctx, s, s.codes, CACHE_CODE, arg.SwiftCode, func() ([]sqlcout.GetCodeDetailsRow, error) {
+		return s.Store.GetCodeDetails(ctx, arg)
+	}, func(details []sqlcout.GetCodeDetailsRow) []string {
+		tags := []string{bankTag(arg.SwiftCode)}
+		for _, detail := range details {
+			tags = append(tags, bankTag(detail.SwiftCode))
+		}
+		return tags
+	})
+}
+
+func (s *CachedStore) GetCountry(ctx context.Context, countryIso2 string) (sqlcout.Country, error) {
+	return lookup(ctx, s, s.countries, CACHE_COUNTRY, countryIso2, func() (sqlcout.Country, error) {
+		return s.Store.GetCountry(ctx, countryIso2)
+	}, func(sqlcout.Country) []string {
+		return []string{countryNameTag(countryIso2)}
+	})
+}
+
+func (s *CachedStore) GetCodeDetailsByCountryCode(ctx context.Context, countryIso2 string) ([]sqlcout.GetCodeDetailsByCountryCodeRow, error) {
+	return lookup(ctx, s, s.countryCodes, CACHE_COUNTRY_CODES, countryIso2, func() ([]sqlcout.GetCodeDetailsByCountryCodeRow, error) {
+		return s.Store.GetCodeDetailsByCountryCode(ctx, countryIso2)
+	}, func(codes []sqlcout.GetCodeDetailsByCountryCodeRow) []string {
+		// Writes of any code listed drop the list, whether or not they name the country
+		tags := []string{countryTag(countryIso2)}
+		for _, code := range codes {
+			tags = append(tags, bankTag(code.SwiftCode))
+		}
+		return tags
+	})
+}
+
+func (s *CachedStore) InsertSwiftCode(ctx context.Context, arg sqlcout.InsertSwiftCodeParams) (sql.Result, error) {
+	defer s.invalidate(ctx, arg.CountryISO2, arg.SwiftCode)
+	return s.Store.InsertSwiftCode(ctx, arg)
+}
+
+func (s *CachedStore) InsertSwiftCodes(ctx context.Context, codes []sqlcout.InsertSwiftCodeParams) (int64, error) {
+	defer s.invalidateAll(ctx, cache.ALL)
+	return s.Store.InsertSwiftCodes(ctx, codes)
+}
+
+func (s *CachedStore) InsertCountry(ctx context.Context, arg sqlcout.InsertCountryParams) (sql.Result, error) {
+	defer s.invalidateAll(ctx, countryNameTag(arg.CountryISO2))
+	return s.Store.InsertCountry(ctx, arg)
+}
+
+func (s *CachedStore) DeleteSwiftCode(ctx context.Context, swiftCode string) (sql.Result, error) {
+	defer s.invalidate(ctx, "", swiftCode)
+	return s.Store.DeleteSwiftCode(ctx, swiftCode)
+}
+
+func (s *CachedStore) UpdateSwiftCode(ctx context.Context, arg sqlcout.UpdateSwiftCodeParams) (sql.Result, error) {
+	defer s.invalidate(ctx, arg.CountryISO2, arg.SwiftCode)
+	return s.Store.UpdateSwiftCode(ctx, arg)
+}
+
+func (s *CachedStore) EditSwiftCode(ctx context.Context, arg sqlcout.EditSwiftCodeParams) (sql.Result, error) {
+	defer s.invalidate(ctx, "", arg.SwiftCode)
+	return s.Store.EditSwiftCode(ctx, arg)
+}
+
+func (s *CachedStore) DeleteSwiftCodesByImport(ctx context.Context, importID sql.NullString) (sql.Result, error) {
+	defer s.invalidateAll(ctx, cache.ALL)
+	return s.Store.DeleteSwiftCodesByImport(ctx, importID)
+}
+
+func (s *CachedStore) RestoreSwiftCode(ctx context.Context, arg sqlcout.RestoreSwiftCodeParams) (sql.Result, error) {
+	// The lists of the country the code is restored from carry its bank tag
+	defer s.invalidate(ctx, arg.CountryISO2, arg.SwiftCode)
+	return s.Store.RestoreSwiftCode(ctx, arg)
+}
@@ -0,0 +1,249 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"swiftcodes/internal/cache"
+	"swiftcodes/sqlcout"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestCachedStore(t *testing.T) {
+	ctx := context.Background()
+	_, queries := setupSQLite(t)
+	cached := NewCached(queries, 100, time.Minute)
+	queries.InsertCountry(ctx, sqlcout.InsertCountryParams{CountryISO2: "PL", CountryName: "POLAND"})
+	queries.InsertCountry(ctx, sqlcout.InsertCountryParams{CountryISO2: "DE", CountryName: "GERMANY"})
+	for _, code := range []string{"AAAAPLPWXXX", "AAAAPLPWBBB", "CCCCPLPWXXX", "DDDDDEFFXXX"} {
+		queries.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: code, CountryISO2: code[4:6]})
+	}
+
+	// counts returns the rows of the lookups through the cache
+	counts := func() (int, int, int) {
+		hq, _ := cached.GetCodeDetails(ctx, sqlcout.GetCodeDetailsParams{SwiftCode: "AAAAPLPWXXX"})
+		other, _ := cached.GetCodeDetails(ctx, sqlcout.GetCodeDetailsParams{SwiftCode: "CCCCPLPWXXX"})
+		de, _ := cached.GetCodeDetailsByCountryCode(ctx, "DE")
+		return len(hq), len(other), len(de)
+	}
+	counts()
+
+	tt := []struct {
+		name      string
+		write     func() error
+		wantHQ    int
+		wantOther int
+		wantDE    int
+	}{
+		// Writes behind the cache don't show until the entries expire
+		{"insert behind the cache", func() error {
+			_, err := queries.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: "AAAAPLPWCCC", CountryISO2: "PL"})
+			return err
+		}, 2, 1, 1},
+		{"insert of a branch", func() error {
+			_, err := cached.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: "AAAAPLPWDDD", CountryISO2: "PL"})
+			return err
+		}, 4, 1, 1},
+		{"delete of a branch", func() error {
+			_, err := cached.DeleteSwiftCode(ctx, "aaaaplpwbbb")
+			return err
+		}, 3, 1, 1},
+		{"delete of another country's code", func() error {
+			_, err := cached.DeleteSwiftCode(ctx, "DDDDDEFFXXX")
+			return err
+		}, 3, 1, 0},
+		{"insert into a country", func() error {
+			_, err := cached.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: "EEEEPLPWXXX", CountryISO2: "DE"})
+			return err
+		}, 3, 1, 1},
+		{"import rollback", func() error {
+			_, err := cached.DeleteSwiftCodesByImport(ctx, sql.NullString{String: "unknown", Valid: true})
+			return err
+		}, 3, 1, 1},
+	}
+	for i := 0; i < len(tt); i++ {
+		if err := tt[i].write(); err != nil {
+			t.Fatalf("%s error: %v", tt[i].name, err)
+		}
+		if hq, other, de := counts(); hq != tt[i].wantHQ || other != tt[i].wantOther || de != tt[i].wantDE {
+			t.Errorf("after %s lookups = %d, %d, %d rows, want %d, %d, %d", tt[i].name, hq, other, de, tt[i].wantHQ, tt[i].wantOther, tt[i].wantDE)
+		}
+	}
+
+	stats := cached.Stats()
+	if stats[CACHE_CODE].Hits != 8 || stats[CACHE_CODE].Misses != 6 || stats[CACHE_COUNTRY_CODES].Entries != 1 {
+		t.Errorf("Stats() = %+v, want 8 code hits, 6 code misses and 1 country cached", stats)
+	}
+}
+
+func TestCachedStoreShared(t *testing.T) {
+	ctx := context.Background()
+	_, queries := setupSQLite(t)
+	queries.InsertCountry(ctx, sqlcout.InsertCountryParams{CountryISO2: "PL", CountryName: "POLAND"})
+	queries.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: "AAAAPLPWXXX", CountryISO2: "PL"})
+	mr := miniredis.RunT(t)
+	replicas := make([]*CachedStore, 2)
+	for i := range replicas {
+		shared, err := cache.NewRedis("redis://"+mr.Addr(), time.Minute, "test:")
+		if err != nil {
+			t.Fatal("NewRedis() error: ", err)
+		}
+		replicas[i] = NewCached(queries, 100, time.Minute)
+		if err := replicas[i].Share(ctx, shared); err != nil {
+			t.Fatal("Share() error: ", err)
+		}
+		t.Cleanup(func() { replicas[i].Close() })
+	}
+	lookup := func(replica *CachedStore) int {
+		details, err := replica.GetCodeDetails(ctx, sqlcout.GetCodeDetailsParams{SwiftCode: "AAAAPLPWXXX"})
+		if err != nil {
+			t.Fatal("GetCodeDetails() error: ", err)
+		}
+		return len(details)
+	}
+
+	// The second replica is answered from the entry the first one shared
+	lookup(replicas[0])
+	lookup(replicas[1])
+	if stats := replicas[1].SharedStats()[CACHE_CODE]; stats.Hits != 1 || stats.Misses != 0 {
+		t.Errorf("SharedStats() of the second replica = %+v, want 1 hit", stats)
+	}
+
+	if _, err := replicas[1].InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: "AAAAPLPWBBB", CountryISO2: "PL"}); err != nil {
+		t.Fatal("InsertSwiftCode() error: ", err)
+	}
+	if n := lookup(replicas[1]); n != 2 {
+		t.Errorf("lookup on the writing replica = %d rows, want 2", n)
+	}
+	// The first replica drops its entry once the invalidation reaches it
+	deadline := time.Now().Add(5 * time.Second)
+	for lookup(replicas[0]) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("lookup on the other replica still returns the rows before the write")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// countingStore counts the lookups reaching the database and holds them until release is closed
+type countingStore struct {
+	Store
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *countingStore) GetCodeDetails(ctx context.Context, arg sqlcout.GetCodeDetailsParams) ([]sqlcout.GetCodeDetailsRow, error) {
+	s.calls.Add(1)
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return nil, mapError(ctx, ctx.Err())
+	}
+	return s.Store.GetCodeDetails(ctx, arg)
+}
+
+func (s *countingStore) GetCodeDetailsByCountryCode(ctx context.Context, countryIso2 string) ([]sqlcout.GetCodeDetailsByCountryCodeRow, error) {
+	s.calls.Add(1)
+	<-s.release
+	return s.Store.GetCodeDetailsByCountryCode(ctx, countryIso2)
+}
+
+func TestCachedStoreCoalescing(t *testing.T) {
+	ctx := context.Background()
+	_, queries := setupSQLite(t)
+	queries.InsertCountry(ctx, sqlcout.InsertCountryParams{CountryISO2: "PL", CountryName: "POLAND"})
+	for _, code := range []string{"AAAAPLPWXXX", "AAAAPLPWBBB", "CCCCPLPWXXX"} {
+		queries.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: code, CountryISO2: "PL"})
+	}
+
+	const REQUESTS = 100
+	tt := []struct {
+		name      string
+		lookup    func(s Store, i int) (int, error)
+		wantRows  int
+		wantCalls int32
+	}{
+		{"same code", func(s Store, i int) (int, error) {
+			details, err := s.GetCodeDetails(ctx, sqlcout.GetCodeDetailsParams{SwiftCode: "AAAAPLPWXXX"})
+			return len(details), err
+		}, 2, 1},
+		{"same country", func(s Store, i int) (int, error) {
+			codes, err := s.GetCodeDetailsByCountryCode(ctx, "PL")
+			return len(codes), err
+		}, 3, 1},
+		// Lookups of different codes aren't coalesced
+		{"two codes", func(s Store, i int) (int, error) {
+			code := []string{"AAAAPLPWBBB", "CCCCPLPWXXX"}[i%2]
+			details, err := s.GetCodeDetails(ctx, sqlcout.GetCodeDetailsParams{SwiftCode: code})
+			return len(details), err
+		}, 1, 2},
+	}
+	for i := 0; i < len(tt); i++ {
+		counting := &countingStore{Store: queries, release: make(chan struct{})}
+		cached := NewCached(counting, 100, time.Minute)
+		var wg sync.WaitGroup
+		rows := make([]int, REQUESTS)
+		errs := make([]error, REQUESTS)
+		for j := 0; j < REQUESTS; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rows[j], errs[j] = tt[i].lookup(cached, j)
+			}()
+		}
+		// Let every request join the lookups in flight before they return
+		time.Sleep(50 * time.Millisecond)
+		close(counting.release)
+		wg.Wait()
+		for j := 0; j < REQUESTS; j++ {
+			if rows[j] != tt[i].wantRows || errs[j] != nil {
+				t.Fatalf("%s: request %d = %d rows, %v, want %d rows", tt[i].name, j, rows[j], errs[j], tt[i].wantRows)
+			}
+		}
+		if calls := counting.calls.Load(); calls != tt[i].wantCalls {
+			t.Errorf("%s: %d concurrent requests ran %d queries, want %d", tt[i].name, REQUESTS, calls, tt[i].wantCalls)
+		}
+	}
+}
+
+func TestCachedStoreCoalescingCancel(t *testing.T) {
+	ctx := context.Background()
+	_, queries := setupSQLite(t)
+	queries.InsertCountry(ctx, sqlcout.InsertCountryParams{CountryISO2: "PL", CountryName: "POLAND"})
+	queries.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: "AAAAPLPWXXX", CountryISO2: "PL"})
+	counting := &countingStore{Store: queries, release: make(chan struct{})}
+	cached := NewCached(counting, 100, time.Minute)
+	arg := sqlcout.GetCodeDetailsParams{SwiftCode: "AAAAPLPWXXX"}
+
+	// The request starting the query goes away, the one waiting for it queries again
+	first, cancel := context.WithCancel(ctx)
+	firstErr := make(chan error)
+	go func() {
+		_, err := cached.GetCodeDetails(first, arg)
+		firstErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	secondErr := make(chan error)
+	go func() {
+		_, err := cached.GetCodeDetails(ctx, arg)
+		secondErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled lookup error = %v, want context.Canceled", err)
+	}
+	close(counting.release)
+	if err := <-secondErr; err != nil {
+		t.Errorf("waiting lookup error = %v, want its own query to answer it", err)
+	}
+	if calls := counting.calls.Load(); calls != 2 {
+		t.Errorf("lookups ran %d queries, want 2", calls)
+	}
+}
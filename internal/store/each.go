@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+
+	"swiftcodes/internal/config"
+	"swiftcodes/sqlcout"
+)
+
+const eachSwiftCodeQuery = `SELECT swift_codes.swift_code, swift_codes.address, swift_codes.bank_name, swift_codes.country_iso2, countries.country_name, swift_codes.actor, swift_codes.address_override, swift_codes.bank_name_override
+FROM swift_codes JOIN countries ON swift_codes.country_iso2 = countries.country_iso2`
+
+// EachSwiftCode isn't generated, as sqlc reads every row of a :many query into a slice before returning.
+// The rows are ordered by code like ListSwiftCodes. Iteration stops at the first error returned by f.
+func (s mappedStore) EachSwiftCode(ctx context.Context, country string, f func(sqlcout.ListSwiftCodesRow) error) error {
+	query := eachSwiftCodeQuery
+	var args []interface{}
+	if country != "" {
+		if s.driver == config.DRIVER_POSTGRES {
+			query += "\nWHERE swift_codes.country_iso2 = $1"
+		} else {
+			query += "\nWHERE swift_codes.country_iso2 = ?"
+		}
+		args = append(args, country)
+	}
+	rows, err := s.db.QueryContext(ctx, query+"\nORDER BY swift_codes.swift_code", args...)
+	if err != nil {
+		return mapError(ctx, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i sqlcout.ListSwiftCodesRow
+		if err := rows.Scan(
+			&i.SwiftCode,
+			&i.Address,
+			&i.BankName,
+			&i.CountryISO2,
+			&i.CountryName,
+			&i.Actor,
+			&i.AddressOverride,
+			&i.BankNameOverride,
+		); err != nil {
+			return mapError(ctx, err)
+		}
+		if err := f(i); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return mapError(ctx, err)
+	}
+	return mapError(ctx, rows.Err())
+}
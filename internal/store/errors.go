@@ -0,0 +1,313 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"swiftcodes/sqlcout"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Domain errors returned by every Store, independent of the driver in use.
+// The driver error they were mapped from stays available through errors.Unwrap.
+var (
+	ErrDuplicateCode  = errors.New("swift code already exists")
+	ErrUnknownCountry = errors.New("country does not exist")
+	ErrNotFound       = errors.New("not found")
+	ErrUnavailable    = errors.New("database unavailable")
+	ErrTimeout        = errors.New("database query timed out")
+)
+
+// MySQL server error numbers
+const (
+	mysqlDuplicateEntry     = 1062
+	mysqlNoReferencedRow    = 1452
+	mysqlTooManyConnections = 1040
+	mysqlServerShutdown     = 1053
+)
+
+// PostgreSQL SQLSTATE codes and classes
+const (
+	postgresUniqueViolation     = "23505"
+	postgresForeignKeyViolation = "23503"
+	postgresTooManyConnections  = "53300"
+	postgresAdminShutdown       = "57P01"
+	postgresCannotConnectNow    = "57P03"
+	postgresConnectionException = "08"
+)
+
+// mapError translates a driver error into the matching domain error, wrapping both.
+// Errors caused by the deadline of ctx become ErrTimeout, however the driver reports them.
+func mapError(ctx context.Context, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case isDuplicateKey(err):
+		return fmt.Errorf("%w: %w", ErrDuplicateCode, err)
+	case isForeignKeyViolation(err):
+		return fmt.Errorf("%w: %w", ErrUnknownCountry, err)
+	case isUnavailable(err):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
+
+// isDuplicateKey reports whether err is a primary or unique key violation
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+	var postgresErr *pgconn.PgError
+	if errors.As(err, &postgresErr) {
+		return postgresErr.Code == postgresUniqueViolation
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY ||
+			sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	}
+	return false
+}
+
+// isForeignKeyViolation reports whether err is caused by a reference to a missing row
+func isForeignKeyViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlNoReferencedRow
+	}
+	var postgresErr *pgconn.PgError
+	if errors.As(err, &postgresErr) {
+		return postgresErr.Code == postgresForeignKeyViolation
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+	}
+	return false
+}
+
+// isUnavailable reports whether err means the database can't serve requests right now,
+// so that retrying later may succeed
+func isUnavailable(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlTooManyConnections || mysqlErr.Number == mysqlServerShutdown
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	var postgresErr *pgconn.PgError
+	if errors.As(err, &postgresErr) {
+		switch postgresErr.Code {
+		case postgresTooManyConnections, postgresAdminShutdown, postgresCannotConnectNow:
+			return true
+		}
+		return strings.HasPrefix(postgresErr.Code, postgresConnectionException)
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return false
+}
+
+// mappedStore wraps the Store of a backend and translates its errors into domain errors.
+// Lookups that find nothing return ErrNotFound instead of empty results.
+type mappedStore struct {
+	backend Queries
+	db      sqlcout.DBTX
+	driver  string
+}
+
+func (s mappedStore) GetCountry(ctx context.Context, countryIso2 string) (sqlcout.Country, error) {
+	country, err := s.backend.GetCountry(ctx, countryIso2)
+	return country, mapError(ctx, err)
+}
+
+func (s mappedStore) GetCodeDetailsByCountryCode(ctx context.Context, countryIso2 string) ([]sqlcout.GetCodeDetailsByCountryCodeRow, error) {
+	codes, err := s.backend.GetCodeDetailsByCountryCode(ctx, countryIso2)
+	return codes, mapError(ctx, err)
+}
+
+func (s mappedStore) GetCodeDetails(ctx context.Context, arg sqlcout.GetCodeDetailsParams) ([]sqlcout.GetCodeDetailsRow, error) {
+	details, err := s.backend.GetCodeDetails(ctx, arg)
+	if err == nil && len(details) == 0 {
+		return nil, ErrNotFound
+	}
+	return details, mapError(ctx, err)
+}
+
+func (s mappedStore) InsertSwiftCode(ctx context.Context, arg sqlcout.InsertSwiftCodeParams) (sql.Result, error) {
+	result, err := s.backend.InsertSwiftCode(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) InsertCountry(ctx context.Context, arg sqlcout.InsertCountryParams) (sql.Result, error) {
+	result, err := s.backend.InsertCountry(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) DeleteSwiftCode(ctx context.Context, swiftCode string) (sql.Result, error) {
+	result, err := s.backend.DeleteSwiftCode(ctx, swiftCode)
+	if err != nil {
+		return nil, mapError(ctx, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, mapError(ctx, err)
+	}
+	if rows == 0 {
+		return nil, ErrNotFound
+	}
+	return result, nil
+}
+
+func (s mappedStore) ListSwiftCodes(ctx context.Context) ([]sqlcout.ListSwiftCodesRow, error) {
+	codes, err := s.backend.ListSwiftCodes(ctx)
+	return codes, mapError(ctx, err)
+}
+
+// UpdateSwiftCode doesn't report missing codes, as MySQL counts only the rows whose values changed
+func (s mappedStore) UpdateSwiftCode(ctx context.Context, arg sqlcout.UpdateSwiftCodeParams) (sql.Result, error) {
+	result, err := s.backend.UpdateSwiftCode(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+// EditSwiftCode doesn't report missing codes for the same reason as UpdateSwiftCode
+func (s mappedStore) EditSwiftCode(ctx context.Context, arg sqlcout.EditSwiftCodeParams) (sql.Result, error) {
+	result, err := s.backend.EditSwiftCode(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) InsertImport(ctx context.Context, arg sqlcout.InsertImportParams) (sql.Result, error) {
+	result, err := s.backend.InsertImport(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) ListImports(ctx context.Context) ([]sqlcout.ListImportsRow, error) {
+	imports, err := s.backend.ListImports(ctx)
+	return imports, mapError(ctx, err)
+}
+
+func (s mappedStore) GetSwiftCodeSource(ctx context.Context, swiftCode string) (sqlcout.GetSwiftCodeSourceRow, error) {
+	source, err := s.backend.GetSwiftCodeSource(ctx, swiftCode)
+	return source, mapError(ctx, err)
+}
+
+func (s mappedStore) ListSwiftCodesByImport(ctx context.Context, importID sql.NullString) ([]string, error) {
+	codes, err := s.backend.ListSwiftCodesByImport(ctx, importID)
+	return codes, mapError(ctx, err)
+}
+
+func (s mappedStore) DeleteSwiftCodesByImport(ctx context.Context, importID sql.NullString) (sql.Result, error) {
+	result, err := s.backend.DeleteSwiftCodesByImport(ctx, importID)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) DeleteImport(ctx context.Context, id string) (sql.Result, error) {
+	result, err := s.backend.DeleteImport(ctx, id)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) InsertJob(ctx context.Context, arg sqlcout.InsertJobParams) (sql.Result, error) {
+	result, err := s.backend.InsertJob(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) GetJob(ctx context.Context, id string) (sqlcout.Job, error) {
+	job, err := s.backend.GetJob(ctx, id)
+	return job, mapError(ctx, err)
+}
+
+func (s mappedStore) ListJobs(ctx context.Context, limit int32) ([]sqlcout.Job, error) {
+	jobs, err := s.backend.ListJobs(ctx, limit)
+	return jobs, mapError(ctx, err)
+}
+
+func (s mappedStore) ListDueJobs(ctx context.Context, arg sqlcout.ListDueJobsParams) ([]sqlcout.ListDueJobsRow, error) {
+	jobs, err := s.backend.ListDueJobs(ctx, arg)
+	return jobs, mapError(ctx, err)
+}
+
+func (s mappedStore) ClaimJob(ctx context.Context, arg sqlcout.ClaimJobParams) (sql.Result, error) {
+	result, err := s.backend.ClaimJob(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) HeartbeatJob(ctx context.Context, arg sqlcout.HeartbeatJobParams) (sql.Result, error) {
+	result, err := s.backend.HeartbeatJob(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) FinishJob(ctx context.Context, arg sqlcout.FinishJobParams) (sql.Result, error) {
+	result, err := s.backend.FinishJob(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) RetryJob(ctx context.Context, arg sqlcout.RetryJobParams) (sql.Result, error) {
+	result, err := s.backend.RetryJob(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) ReleaseJob(ctx context.Context, arg sqlcout.ReleaseJobParams) (sql.Result, error) {
+	result, err := s.backend.ReleaseJob(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) CancelJob(ctx context.Context, arg sqlcout.CancelJobParams) (sql.Result, error) {
+	result, err := s.backend.CancelJob(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) RequeueStaleJobs(ctx context.Context, arg sqlcout.RequeueStaleJobsParams) (sql.Result, error) {
+	result, err := s.backend.RequeueStaleJobs(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) FailStaleJobs(ctx context.Context, arg sqlcout.FailStaleJobsParams) (sql.Result, error) {
+	result, err := s.backend.FailStaleJobs(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) RecordSwiftCodeChange(ctx context.Context, arg sqlcout.RecordSwiftCodeChangeParams) (sql.Result, error) {
+	result, err := s.backend.RecordSwiftCodeChange(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) ListSwiftCodeChanges(ctx context.Context, importID string) ([]sqlcout.SwiftCodeChange, error) {
+	changes, err := s.backend.ListSwiftCodeChanges(ctx, importID)
+	return changes, mapError(ctx, err)
+}
+
+func (s mappedStore) RestoreSwiftCode(ctx context.Context, arg sqlcout.RestoreSwiftCodeParams) (sql.Result, error) {
+	result, err := s.backend.RestoreSwiftCode(ctx, arg)
+	return result, mapError(ctx, err)
+}
+
+func (s mappedStore) DeleteSwiftCodeChanges(ctx context.Context, importID string) (sql.Result, error) {
+	result, err := s.backend.DeleteSwiftCodeChanges(ctx, importID)
+	return result, mapError(ctx, err)
+}
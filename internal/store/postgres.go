@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"swiftcodes/sqlcout"
+	"swiftcodes/sqlcout/postgres"
+)
+
+// postgresStore adapts the queries generated for PostgreSQL to the Store interface
+type postgresStore struct {
+	queries *postgres.Queries
+}
+
+func newPostgresStore(db postgres.DBTX) *postgresStore {
+	return &postgresStore{postgres.New(db)}
+}
+
+func (s *postgresStore) GetCountry(ctx context.Context, countryIso2 string) (sqlcout.Country, error) {
+	country, err := s.queries.GetCountry(ctx, countryIso2)
+	return sqlcout.Country(country), err
+}
+
+func (s *postgresStore) GetCodeDetailsByCountryCode(ctx context.Context, countryIso2 string) ([]sqlcout.GetCodeDetailsByCountryCodeRow, error) {
+	codes, err := s.queries.GetCodeDetailsByCountryCode(ctx, countryIso2)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.GetCodeDetailsByCountryCodeRow
+	for _, code := range codes {
+		items = append(items, sqlcout.GetCodeDetailsByCountryCodeRow(code))
+	}
+	return items, nil
+}
+
+func (s *postgresStore) GetCodeDetails(ctx context.Context, arg sqlcout.GetCodeDetailsParams) ([]sqlcout.GetCodeDetailsRow, error) {
+	details, err := s.queries.GetCodeDetails(ctx, arg.SwiftCode)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.GetCodeDetailsRow
+	for _, row := range details {
+		items = append(items, sqlcout.GetCodeDetailsRow(row))
+	}
+	return items, nil
+}
+
+func (s *postgresStore) InsertSwiftCode(ctx context.Context, arg sqlcout.InsertSwiftCodeParams) (sql.Result, error) {
+	return s.queries.InsertSwiftCode(ctx, postgres.InsertSwiftCodeParams(arg))
+}
+
+func (s *postgresStore) InsertCountry(ctx context.Context, arg sqlcout.InsertCountryParams) (sql.Result, error) {
+	return s.queries.InsertCountry(ctx, postgres.InsertCountryParams(arg))
+}
+
+func (s *postgresStore) DeleteSwiftCode(ctx context.Context, swiftCode string) (sql.Result, error) {
+	return s.queries.DeleteSwiftCode(ctx, swiftCode)
+}
+
+func (s *postgresStore) ListSwiftCodes(ctx context.Context) ([]sqlcout.ListSwiftCodesRow, error) {
+	codes, err := s.queries.ListSwiftCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.ListSwiftCodesRow
+	for _, code := range codes {
+		items = append(items, sqlcout.ListSwiftCodesRow(code))
+	}
+	return items, nil
+}
+
+func (s *postgresStore) UpdateSwiftCode(ctx context.Context, arg sqlcout.UpdateSwiftCodeParams) (sql.Result, error) {
+	return s.queries.UpdateSwiftCode(ctx, postgres.UpdateSwiftCodeParams(arg))
+}
+
+func (s *postgresStore) EditSwiftCode(ctx context.Context, arg sqlcout.EditSwiftCodeParams) (sql.Result, error) {
+	return s.queries.EditSwiftCode(ctx, postgres.EditSwiftCodeParams(arg))
+}
+
+func (s *postgresStore) InsertImport(ctx context.Context, arg sqlcout.InsertImportParams) (sql.Result, error) {
+	return s.queries.InsertImport(ctx, postgres.InsertImportParams(arg))
+}
+
+func (s *postgresStore) ListImports(ctx context.Context) ([]sqlcout.ListImportsRow, error) {
+	imports, err := s.queries.ListImports(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.ListImportsRow
+	for _, i := range imports {
+		items = append(items, sqlcout.ListImportsRow(i))
+	}
+	return items, nil
+}
+
+func (s *postgresStore) GetSwiftCodeSource(ctx context.Context, swiftCode string) (sqlcout.GetSwiftCodeSourceRow, error) {
+	source, err := s.queries.GetSwiftCodeSource(ctx, swiftCode)
+	return sqlcout.GetSwiftCodeSourceRow(source), err
+}
+
+func (s *postgresStore) ListSwiftCodesByImport(ctx context.Context, importID sql.NullString) ([]string, error) {
+	return s.queries.ListSwiftCodesByImport(ctx, importID)
+}
+
+func (s *postgresStore) DeleteSwiftCodesByImport(ctx context.Context, importID sql.NullString) (sql.Result, error) {
+	return s.queries.DeleteSwiftCodesByImport(ctx, importID)
+}
+
+func (s *postgresStore) DeleteImport(ctx context.Context, id string) (sql.Result, error) {
+	return s.queries.DeleteImport(ctx, id)
+}
+
+func (s *postgresStore) InsertJob(ctx context.Context, arg sqlcout.InsertJobParams) (sql.Result, error) {
+	return s.queries.InsertJob(ctx, postgres.InsertJobParams(arg))
+}
+
+func (s *postgresStore) GetJob(ctx context.Context, id string) (sqlcout.Job, error) {
+	job, err := s.queries.GetJob(ctx, id)
+	return sqlcout.Job(job), err
+}
+
+func (s *postgresStore) ListJobs(ctx context.Context, limit int32) ([]sqlcout.Job, error) {
+	jobs, err := s.queries.ListJobs(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.Job
+	for _, job := range jobs {
+		items = append(items, sqlcout.Job(job))
+	}
+	return items, nil
+}
+
+func (s *postgresStore) ListDueJobs(ctx context.Context, arg sqlcout.ListDueJobsParams) ([]sqlcout.ListDueJobsRow, error) {
+	jobs, err := s.queries.ListDueJobs(ctx, postgres.ListDueJobsParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.ListDueJobsRow
+	for _, job := range jobs {
+		items = append(items, sqlcout.ListDueJobsRow(job))
+	}
+	return items, nil
+}
+
+func (s *postgresStore) ClaimJob(ctx context.Context, arg sqlcout.ClaimJobParams) (sql.Result, error) {
+	return s.queries.ClaimJob(ctx, postgres.ClaimJobParams(arg))
+}
+
+func (s *postgresStore) HeartbeatJob(ctx context.Context, arg sqlcout.HeartbeatJobParams) (sql.Result, error) {
+	return s.queries.HeartbeatJob(ctx, postgres.HeartbeatJobParams(arg))
+}
+
+func (s *postgresStore) FinishJob(ctx context.Context, arg sqlcout.FinishJobParams) (sql.Result, error) {
+	return s.queries.FinishJob(ctx, postgres.FinishJobParams(arg))
+}
+
+func (s *postgresStore) RetryJob(ctx context.Context, arg sqlcout.RetryJobParams) (sql.Result, error) {
+	return s.queries.RetryJob(ctx, postgres.RetryJobParams(arg))
+}
+
+func (s *postgresStore) ReleaseJob(ctx context.Context, arg sqlcout.ReleaseJobParams) (sql.Result, error) {
+	return s.queries.ReleaseJob(ctx, postgres.ReleaseJobParams(arg))
+}
+
+func (s *postgresStore) CancelJob(ctx context.Context, arg sqlcout.CancelJobParams) (sql.Result, error) {
+	return s.queries.CancelJob(ctx, postgres.CancelJobParams(arg))
+}
+
+func (s *postgresStore) RequeueStaleJobs(ctx context.Context, arg sqlcout.RequeueStaleJobsParams) (sql.Result, error) {
+	return s.queries.RequeueStaleJobs(ctx, postgres.RequeueStaleJobsParams(arg))
+}
+
+func (s *postgresStore) FailStaleJobs(ctx context.Context, arg sqlcout.FailStaleJobsParams) (sql.Result, error) {
+	return s.queries.FailStaleJobs(ctx, postgres.FailStaleJobsParams(arg))
+}
+
+func (s *postgresStore) RecordSwiftCodeChange(ctx context.Context, arg sqlcout.RecordSwiftCodeChangeParams) (sql.Result, error) {
+	return s.queries.RecordSwiftCodeChange(ctx, postgres.RecordSwiftCodeChangeParams(arg))
+}
+
+func (s *postgresStore) ListSwiftCodeChanges(ctx context.Context, importID string) ([]sqlcout.SwiftCodeChange, error) {
+	changes, err := s.queries.ListSwiftCodeChanges(ctx, importID)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.SwiftCodeChange
+	for _, change := range changes {
+		items = append(items, sqlcout.SwiftCodeChange(change))
+	}
+	return items, nil
+}
+
+func (s *postgresStore) RestoreSwiftCode(ctx context.Context, arg sqlcout.RestoreSwiftCodeParams) (sql.Result, error) {
+	return s.queries.RestoreSwiftCode(ctx, postgres.RestoreSwiftCodeParams(arg))
+}
+
+func (s *postgresStore) DeleteSwiftCodeChanges(ctx context.Context, importID string) (sql.Result, error) {
+	return s.queries.DeleteSwiftCodeChanges(ctx, importID)
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"swiftcodes/sqlcout"
+	"swiftcodes/sqlcout/sqlite"
+)
+
+// sqliteStore adapts the queries generated for SQLite to the Store interface
+type sqliteStore struct {
+	queries *sqlite.Queries
+}
+
+func newSQLiteStore(db sqlite.DBTX) *sqliteStore {
+	return &sqliteStore{sqlite.New(db)}
+}
+
+func (s *sqliteStore) GetCountry(ctx context.Context, countryIso2 string) (sqlcout.Country, error) {
+	country, err := s.queries.GetCountry(ctx, countryIso2)
+	return sqlcout.Country(country), err
+}
+
+func (s *sqliteStore) GetCodeDetailsByCountryCode(ctx context.Context, countryIso2 string) ([]sqlcout.GetCodeDetailsByCountryCodeRow, error) {
+	codes, err := s.queries.GetCodeDetailsByCountryCode(ctx, countryIso2)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.GetCodeDetailsByCountryCodeRow
+	for _, code := range codes {
+		items = append(items, sqlcout.GetCodeDetailsByCountryCodeRow(code))
+	}
+	return items, nil
+}
+
+func (s *sqliteStore) GetCodeDetails(ctx context.Context, arg sqlcout.GetCodeDetailsParams) ([]sqlcout.GetCodeDetailsRow, error) {
+	details, err := s.queries.GetCodeDetails(ctx, arg.SwiftCode)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.GetCodeDetailsRow
+	for _, row := range details {
+		items = append(items, sqlcout.GetCodeDetailsRow(row))
+	}
+	return items, nil
+}
+
+func (s *sqliteStore) InsertSwiftCode(ctx context.Context, arg sqlcout.InsertSwiftCodeParams) (sql.Result, error) {
+	return s.queries.InsertSwiftCode(ctx, sqlite.InsertSwiftCodeParams(arg))
+}
+
+func (s *sqliteStore) InsertCountry(ctx context.Context, arg sqlcout.InsertCountryParams) (sql.Result, error) {
+	return s.queries.InsertCountry(ctx, sqlite.InsertCountryParams(arg))
+}
+
+func (s *sqliteStore) DeleteSwiftCode(ctx context.Context, swiftCode string) (sql.Result, error) {
+	return s.queries.DeleteSwiftCode(ctx, swiftCode)
+}
+
+func (s *sqliteStore) ListSwiftCodes(ctx context.Context) ([]sqlcout.ListSwiftCodesRow, error) {
+	codes, err := s.queries.ListSwiftCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.ListSwiftCodesRow
+	for _, code := range codes {
+		items = append(items, sqlcout.ListSwiftCodesRow(code))
+	}
+	return items, nil
+}
+
+func (s *sqliteStore) UpdateSwiftCode(ctx context.Context, arg sqlcout.UpdateSwiftCodeParams) (sql.Result, error) {
+	return s.queries.UpdateSwiftCode(ctx, sqlite.UpdateSwiftCodeParams(arg))
+}
+
+func (s *sqliteStore) EditSwiftCode(ctx context.Context, arg sqlcout.EditSwiftCodeParams) (sql.Result, error) {
+	return s.queries.EditSwiftCode(ctx, sqlite.EditSwiftCodeParams(arg))
+}
+
+func (s *sqliteStore) InsertImport(ctx context.Context, arg sqlcout.InsertImportParams) (sql.Result, error) {
+	return s.queries.InsertImport(ctx, sqlite.InsertImportParams(arg))
+}
+
+func (s *sqliteStore) ListImports(ctx context.Context) ([]sqlcout.ListImportsRow, error) {
+	imports, err := s.queries.ListImports(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.ListImportsRow
+	for _, i := range imports {
+		items = append(items, sqlcout.ListImportsRow(i))
+	}
+	return items, nil
+}
+
+func (s *sqliteStore) GetSwiftCodeSource(ctx context.Context, swiftCode string) (sqlcout.GetSwiftCodeSourceRow, error) {
+	source, err := s.queries.GetSwiftCodeSource(ctx, swiftCode)
+	return sqlcout.GetSwiftCodeSourceRow(source), err
+}
+
+func (s *sqliteStore) ListSwiftCodesByImport(ctx context.Context, importID sql.NullString) ([]string, error) {
+	return s.queries.ListSwiftCodesByImport(ctx, importID)
+}
+
+func (s *sqliteStore) DeleteSwiftCodesByImport(ctx context.Context, importID sql.NullString) (sql.Result, error) {
+	return s.queries.DeleteSwiftCodesByImport(ctx, importID)
+}
+
+func (s *sqliteStore) DeleteImport(ctx context.Context, id string) (sql.Result, error) {
+	return s.queries.DeleteImport(ctx, id)
+}
+
+func (s *sqliteStore) InsertJob(ctx context.Context, arg sqlcout.InsertJobParams) (sql.Result, error) {
+	return s.queries.InsertJob(ctx, sqlite.InsertJobParams(arg))
+}
+
+func (s *sqliteStore) GetJob(ctx context.Context, id string) (sqlcout.Job, error) {
+	job, err := s.queries.GetJob(ctx, id)
+	return sqlcout.Job(job), err
+}
+
+func (s *sqliteStore) ListJobs(ctx context.Context, limit int32) ([]sqlcout.Job, error) {
+	jobs, err := s.queries.ListJobs(ctx, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.Job
+	for _, job := range jobs {
+		items = append(items, sqlcout.Job(job))
+	}
+	return items, nil
+}
+
+func (s *sqliteStore) ListDueJobs(ctx context.Context, arg sqlcout.ListDueJobsParams) ([]sqlcout.ListDueJobsRow, error) {
+	jobs, err := s.queries.ListDueJobs(ctx, sqlite.ListDueJobsParams{RunAfter: arg.RunAfter, Limit: int64(arg.Limit)})
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.ListDueJobsRow
+	for _, job := range jobs {
+		items = append(items, sqlcout.ListDueJobsRow(job))
+	}
+	return items, nil
+}
+
+func (s *sqliteStore) ClaimJob(ctx context.Context, arg sqlcout.ClaimJobParams) (sql.Result, error) {
+	return s.queries.ClaimJob(ctx, sqlite.ClaimJobParams(arg))
+}
+
+func (s *sqliteStore) HeartbeatJob(ctx context.Context, arg sqlcout.HeartbeatJobParams) (sql.Result, error) {
+	return s.queries.HeartbeatJob(ctx, sqlite.HeartbeatJobParams(arg))
+}
+
+func (s *sqliteStore) FinishJob(ctx context.Context, arg sqlcout.FinishJobParams) (sql.Result, error) {
+	return s.queries.FinishJob(ctx, sqlite.FinishJobParams(arg))
+}
+
+func (s *sqliteStore) RetryJob(ctx context.Context, arg sqlcout.RetryJobParams) (sql.Result, error) {
+	return s.queries.RetryJob(ctx, sqlite.RetryJobParams(arg))
+}
+
+func (s *sqliteStore) ReleaseJob(ctx context.Context, arg sqlcout.ReleaseJobParams) (sql.Result, error) {
+	return s.queries.ReleaseJob(ctx, sqlite.ReleaseJobParams(arg))
+}
+
+func (s *sqliteStore) CancelJob(ctx context.Context, arg sqlcout.CancelJobParams) (sql.Result, error) {
+	return s.queries.CancelJob(ctx, sqlite.CancelJobParams(arg))
+}
+
+func (s *sqliteStore) RequeueStaleJobs(ctx context.Context, arg sqlcout.RequeueStaleJobsParams) (sql.Result, error) {
+	return s.queries.RequeueStaleJobs(ctx, sqlite.RequeueStaleJobsParams(arg))
+}
+
+func (s *sqliteStore) FailStaleJobs(ctx context.Context, arg sqlcout.FailStaleJobsParams) (sql.Result, error) {
+	return s.queries.FailStaleJobs(ctx, sqlite.FailStaleJobsParams(arg))
+}
+
+func (s *sqliteStore) RecordSwiftCodeChange(ctx context.Context, arg sqlcout.RecordSwiftCodeChangeParams) (sql.Result, error) {
+	return s.queries.RecordSwiftCodeChange(ctx, sqlite.RecordSwiftCodeChangeParams(arg))
+}
+
+func (s *sqliteStore) ListSwiftCodeChanges(ctx context.Context, importID string) ([]sqlcout.SwiftCodeChange, error) {
+	changes, err := s.queries.ListSwiftCodeChanges(ctx, importID)
+	if err != nil {
+		return nil, err
+	}
+	var items []sqlcout.SwiftCodeChange
+	for _, change := range changes {
+		items = append(items, sqlcout.SwiftCodeChange(change))
+	}
+	return items, nil
+}
+
+func (s *sqliteStore) RestoreSwiftCode(ctx context.Context, arg sqlcout.RestoreSwiftCodeParams) (sql.Result, error) {
+	return s.queries.RestoreSwiftCode(ctx, sqlite.RestoreSwiftCodeParams(arg))
+}
+
+func (s *sqliteStore) DeleteSwiftCodeChanges(ctx context.Context, importID string) (sql.Result, error) {
+	return s.queries.DeleteSwiftCodeChanges(ctx, importID)
+}
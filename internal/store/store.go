@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"swiftcodes/internal/config"
+	"swiftcodes/sqlcout"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// sqlDrivers maps the supported drivers to the names they are registered under in database/sql
+var sqlDrivers = map[string]string{
+	config.DRIVER_MYSQL:    "mysql",
+	config.DRIVER_SQLITE:   "sqlite",
+	config.DRIVER_POSTGRES: "pgx",
+}
+
+// Store is the set of queries used by the API, implemented by every database backend.
+// Results are always returned as the sqlcout (MySQL) types, errors as the domain errors in errors.go.
+type Store interface {
+	Queries
+	// InsertSwiftCodes inserts codes with multi-row INSERT statements and returns how many rows were inserted
+	InsertSwiftCodes(ctx context.Context, codes []sqlcout.InsertSwiftCodeParams) (int64, error)
+	// EachSwiftCode calls f with the codes of ListSwiftCodes one row at a time, only those of country unless it is empty
+	EachSwiftCode(ctx context.Context, country string, f func(sqlcout.ListSwiftCodesRow) error) error
+}
+
+// Queries are the queries generated by sqlc for every driver
+type Queries interface {
+	GetCountry(ctx context.Context, countryIso2 string) (sqlcout.Country, error)
+	GetCodeDetailsByCountryCode(ctx context.Context, countryIso2 string) ([]sqlcout.GetCodeDetailsByCountryCodeRow, error)
+	GetCodeDetails(ctx context.Context, arg sqlcout.GetCodeDetailsParams) ([]sqlcout.GetCodeDetailsRow, error)
+	InsertSwiftCode(ctx context.Context, arg sqlcout.InsertSwiftCodeParams) (sql.Result, error)
+	InsertCountry(ctx context.Context, arg sqlcout.InsertCountryParams) (sql.Result, error)
+	DeleteSwiftCode(ctx context.Context, swiftCode string) (sql.Result, error)
+	ListSwiftCodes(ctx context.Context) ([]sqlcout.ListSwiftCodesRow, error)
+	UpdateSwiftCode(ctx context.Context, arg sqlcout.UpdateSwiftCodeParams) (sql.Result, error)
+	EditSwiftCode(ctx context.Context, arg sqlcout.EditSwiftCodeParams) (sql.Result, error)
+	InsertImport(ctx context.Context, arg sqlcout.InsertImportParams) (sql.Result, error)
+	ListImports(ctx context.Context) ([]sqlcout.ListImportsRow, error)
+	GetSwiftCodeSource(ctx context.Context, swiftCode string) (sqlcout.GetSwiftCodeSourceRow, error)
+	ListSwiftCodesByImport(ctx context.Context, importID sql.NullString) ([]string, error)
+	DeleteSwiftCodesByImport(ctx context.Context, importID sql.NullString) (sql.Result, error)
+	DeleteImport(ctx context.Context, id string) (sql.Result, error)
+	InsertJob(ctx context.Context, arg sqlcout.InsertJobParams) (sql.Result, error)
+	GetJob(ctx context.Context, id string) (sqlcout.Job, error)
+	ListJobs(ctx context.Context, limit int32) ([]sqlcout.Job, error)
+	ListDueJobs(ctx context.Context, arg sqlcout.ListDueJobsParams) ([]sqlcout.ListDueJobsRow, error)
+	ClaimJob(ctx context.Context, arg sqlcout.ClaimJobParams) (sql.Result, error)
+	HeartbeatJob(ctx context.Context, arg sqlcout.HeartbeatJobParams) (sql.Result, error)
+	FinishJob(ctx context.Context, arg sqlcout.FinishJobParams) (sql.Result, error)
+	RetryJob(ctx context.Context, arg sqlcout.RetryJobParams) (sql.Result, error)
+	ReleaseJob(ctx context.Context, arg sqlcout.ReleaseJobParams) (sql.Result, error)
+	CancelJob(ctx context.Context, arg sqlcout.CancelJobParams) (sql.Result, error)
+	RequeueStaleJobs(ctx context.Context, arg sqlcout.RequeueStaleJobsParams) (sql.Result, error)
+	FailStaleJobs(ctx context.Context, arg sqlcout.FailStaleJobsParams) (sql.Result, error)
+	RecordSwiftCodeChange(ctx context.Context, arg sqlcout.RecordSwiftCodeChangeParams) (sql.Result, error)
+	ListSwiftCodeChanges(ctx context.Context, importID string) ([]sqlcout.SwiftCodeChange, error)
+	RestoreSwiftCode(ctx context.Context, arg sqlcout.RestoreSwiftCodeParams) (sql.Result, error)
+	DeleteSwiftCodeChanges(ctx context.Context, importID string) (sql.Result, error)
+}
+
+// Open connects to the database and checks the connection
+func Open(db config.DB) (*sql.DB, error) {
+	return OpenDSN(db.Driver, db.DSN())
+}
+
+// OpenDSN connects to the database at dsn, for when the DSN needs settings beyond those of config.DB
+func OpenDSN(driver string, dsn string) (*sql.DB, error) {
+	sqlDriver, ok := sqlDrivers[driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+	db, err := sql.Open(sqlDriver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// New returns the Store of the given driver backed by db, which is either a *sql.DB or a *sql.Tx
+func New(driver string, db sqlcout.DBTX) Store {
+	switch driver {
+	case config.DRIVER_SQLITE:
+		return mappedStore{newSQLiteStore(db), db, driver}
+	case config.DRIVER_POSTGRES:
+		return mappedStore{newPostgresStore(db), db, driver}
+	}
+	return mappedStore{sqlcout.New(db), db, driver}
+}
+
+// InTx runs f with a Store whose queries all run in one transaction, which is committed if f returns nil
+// and rolled back otherwise
+func InTx(ctx context.Context, db *sql.DB, driver string, f func(queries Store) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return mapError(ctx, err)
+	}
+	defer tx.Rollback()
+	if err := f(New(driver, tx)); err != nil {
+		return err
+	}
+	return mapError(ctx, tx.Commit())
+}
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"swiftcodes/internal/config"
+	"swiftcodes/internal/migrate"
+	"swiftcodes/sqlcout"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func setupSQLite(tb testing.TB) (*sql.DB, Store) {
+	db, err := Open(config.DB{Driver: config.DRIVER_SQLITE, Name: filepath.Join(tb.TempDir(), "test.db")})
+	if err != nil {
+		tb.Fatalf("Open() error: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if _, err := migrate.Up(context.Background(), db, config.DRIVER_SQLITE); err != nil {
+		tb.Fatalf("error creating tables: %v", err)
+	}
+	return db, New(config.DRIVER_SQLITE, db)
+}
+
+func TestMapError(t *testing.T) {
+	tt := []struct {
+		err  error
+		want error
+	}{
+		{nil, nil},
+		{sql.ErrNoRows, ErrNotFound},
+		{&mysql.MySQLError{Number: 1062}, ErrDuplicateCode},
+		{&mysql.MySQLError{Number: 1452}, ErrUnknownCountry},
+		{&mysql.MySQLError{Number: 1040}, ErrUnavailable},
+		{mysql.ErrInvalidConn, ErrUnavailable},
+		{&pgconn.PgError{Code: "23505"}, ErrDuplicateCode},
+		{&pgconn.PgError{Code: "23503"}, ErrUnknownCountry},
+		{&pgconn.PgError{Code: "08006"}, ErrUnavailable},
+		{fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505"}), ErrDuplicateCode},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrUnavailable},
+		{context.DeadlineExceeded, ErrTimeout},
+		{fmt.Errorf("timeout: %w", context.DeadlineExceeded), ErrTimeout},
+	}
+	for i := 0; i < len(tt); i++ {
+		out := mapError(context.Background(), tt[i].err)
+		if tt[i].want == nil && out != nil {
+			t.Errorf(`mapError("%v") = %v, want nil`, tt[i].err, out)
+		} else if !errors.Is(out, tt[i].want) {
+			t.Errorf(`mapError("%v") = %v, want %v`, tt[i].err, out, tt[i].want)
+		} else if tt[i].err != nil && !errors.Is(out, tt[i].err) {
+			t.Errorf(`mapError("%v") = %v, does not wrap the driver error`, tt[i].err, out)
+		}
+	}
+
+	// Drivers may report an expired deadline with an error of their own
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	interrupted := errors.New("interrupted (9)")
+	if out := mapError(ctx, interrupted); !errors.Is(out, ErrTimeout) || !errors.Is(out, interrupted) {
+		t.Errorf(`mapError("%v") after deadline = %v, want %v`, interrupted, out, ErrTimeout)
+	}
+
+	// Errors that are not recognised are passed through untouched
+	unknown := []error{
+		errors.New("Error 1062: Duplicate entry"),
+		&mysql.MySQLError{Number: 1045},
+		&pgconn.PgError{Code: "42P01"},
+	}
+	for _, err := range unknown {
+		if out := mapError(context.Background(), err); out != err {
+			t.Errorf(`mapError("%v") = %v, want it unchanged`, err, out)
+		}
+	}
+}
+
+func TestSQLiteErrors(t *testing.T) {
+	ctx := context.Background()
+	_, queries := setupSQLite(t)
+	if _, err := queries.InsertCountry(ctx, sqlcout.InsertCountryParams{CountryISO2: "PL", CountryName: "POLAND"}); err != nil {
+		t.Fatalf("InsertCountry() error: %v", err)
+	}
+	if _, err := queries.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: "AAAAPLPWXXX", CountryISO2: "PL"}); err != nil {
+		t.Fatalf("InsertSwiftCode() error: %v", err)
+	}
+
+	tt := []struct {
+		code sqlcout.InsertSwiftCodeParams
+		want error
+	}{
+		{sqlcout.InsertSwiftCodeParams{SwiftCode: "AAAAPLPWXXX", CountryISO2: "PL"}, ErrDuplicateCode},
+		{sqlcout.InsertSwiftCodeParams{SwiftCode: "aaaaplpwxxx", CountryISO2: "PL"}, ErrDuplicateCode},
+		{sqlcout.InsertSwiftCodeParams{SwiftCode: "BBBBWTWTXXX", CountryISO2: "WT"}, ErrUnknownCountry},
+	}
+	for i := 0; i < len(tt); i++ {
+		_, err := queries.InsertSwiftCode(ctx, tt[i].code)
+		if !errors.Is(err, tt[i].want) {
+			t.Errorf(`InsertSwiftCode("%v") = %v, want %v`, tt[i].code, err, tt[i].want)
+		}
+	}
+
+	if _, err := queries.GetCountry(ctx, "WT"); !errors.Is(err, ErrNotFound) {
+		t.Errorf(`GetCountry("WT") = %v, want %v`, err, ErrNotFound)
+	}
+	if _, err := queries.DeleteSwiftCode(ctx, "BBBBWTWTXXX"); !errors.Is(err, ErrNotFound) {
+		t.Errorf(`DeleteSwiftCode("BBBBWTWTXXX") = %v, want %v`, err, ErrNotFound)
+	}
+	if _, err := queries.DeleteSwiftCode(ctx, "AAAAPLPWXXX"); err != nil {
+		t.Errorf(`DeleteSwiftCode("AAAAPLPWXXX") = %v, want nil`, err)
+	}
+}
+
+func TestSQLiteGetCodeDetails(t *testing.T) {
+	ctx := context.Background()
+	_, queries := setupSQLite(t)
+	queries.InsertCountry(ctx, sqlcout.InsertCountryParams{CountryISO2: "PL", CountryName: "POLAND"})
+	for _, code := range []string{"AAAAPLPWBBB", "AAAAPLPWXXX", "AAAAPLPWAAA", "CCCCPLPWXXX"} {
+		if _, err := queries.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: code, CountryISO2: "PL"}); err != nil {
+			t.Fatalf("InsertSwiftCode() error: %v", err)
+		}
+	}
+
+	tt := []struct {
+		swiftcode string
+		want      []string
+	}{
+		{"AAAAPLPWXXX", []string{"AAAAPLPWXXX", "AAAAPLPWBBB", "AAAAPLPWAAA"}},
+		{"aaaaplpwxxx", []string{"AAAAPLPWXXX", "AAAAPLPWBBB", "AAAAPLPWAAA"}},
+		{"AAAAPLPWBBB", []string{"AAAAPLPWBBB"}},
+		{"CCCCPLPWXXX", []string{"CCCCPLPWXXX"}},
+	}
+	for i := 0; i < len(tt); i++ {
+		details, err := queries.GetCodeDetails(ctx, sqlcout.GetCodeDetailsParams{SwiftCode: tt[i].swiftcode})
+		if err != nil {
+			t.Fatalf(`GetCodeDetails("%s") error: %v`, tt[i].swiftcode, err)
+		}
+		if len(details) != len(tt[i].want) {
+			t.Errorf(`GetCodeDetails("%s") returned %d rows, want %d`, tt[i].swiftcode, len(details), len(tt[i].want))
+			continue
+		}
+		// The queried code must come first, the response is built around it
+		if details[0].SwiftCode != tt[i].want[0] {
+			t.Errorf(`GetCodeDetails("%s")[0] = %s, want %s`, tt[i].swiftcode, details[0].SwiftCode, tt[i].want[0])
+		}
+		if details[0].CountryName.String != "POLAND" {
+			t.Errorf(`GetCodeDetails("%s")[0].CountryName = %v, want POLAND`, tt[i].swiftcode, details[0].CountryName)
+		}
+	}
+	if _, err := queries.GetCodeDetails(ctx, sqlcout.GetCodeDetailsParams{SwiftCode: "DDDDPLPWXXX"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf(`GetCodeDetails("DDDDPLPWXXX") = %v, want %v`, err, ErrNotFound)
+	}
+}
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	db, queries := setupSQLite(t)
+	country := sqlcout.InsertCountryParams{CountryISO2: "PL", CountryName: "POLAND"}
+
+	failure := errors.New("failure")
+	err := InTx(ctx, db, config.DRIVER_SQLITE, func(tx Store) error {
+		if _, err := tx.InsertCountry(ctx, country); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("InTx() = %v, want %v", err, failure)
+	}
+	if _, err := queries.GetCountry(ctx, "PL"); !errors.Is(err, ErrNotFound) {
+		t.Errorf(`GetCountry("PL") after rollback = %v, want %v`, err, ErrNotFound)
+	}
+
+	err = InTx(ctx, db, config.DRIVER_SQLITE, func(tx Store) error {
+		_, err := tx.InsertCountry(ctx, country)
+		return err
+	})
+	if err != nil {
+		t.Errorf("InTx() = %v, want nil", err)
+	}
+	if _, err := queries.GetCountry(ctx, "PL"); err != nil {
+		t.Errorf(`GetCountry("PL") after commit = %v, want nil`, err)
+	}
+}
+
+func TestInsertSwiftCodesQuery(t *testing.T) {
+	tt := []struct {
+		driver string
+		rows   int
+		want   string
+	}{
+		{config.DRIVER_MYSQL, 1, "INSERT INTO swift_codes (swift_code, address, bank_name, country_iso2, import_id, actor) VALUES (?, ?, ?, ?, ?, ?)"},
+		{config.DRIVER_SQLITE, 2, "INSERT INTO swift_codes (swift_code, address, bank_name, country_iso2, import_id, actor) VALUES (?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?)"},
+		{config.DRIVER_POSTGRES, 2, "INSERT INTO swift_codes (swift_code, address, bank_name, country_iso2, import_id, actor) VALUES ($1, $2, $3, $4, $5, $6), ($7, $8, $9, $10, $11, $12)"},
+	}
+	for i := 0; i < len(tt); i++ {
+		if got := insertSwiftCodesQuery(tt[i].driver, tt[i].rows); got != tt[i].want {
+			t.Errorf(`insertSwiftCodesQuery("%v", %v) = %v, want %v`, tt[i].driver, tt[i].rows, got, tt[i].want)
+		}
+	}
+}
+
+func TestGetCodeDetails(t *testing.T) {
+	ctx := context.Background()
+	_, queries := setupSQLite(t)
+	queries.InsertCountry(ctx, sqlcout.InsertCountryParams{CountryISO2: "PL", CountryName: "POLAND"})
+	for _, code := range []string{"AAAAPLPWXXX", "AAAAPLPWBBB", "aaaaplpwccc", "AAAAPLPXDDD", "BBBBPLPWXXX", "ccccplpwxxx", "CCCCPLPWBBB"} {
+		queries.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{SwiftCode: code, CountryISO2: "PL"})
+	}
+	tt := []struct {
+		swiftCode string
+		want      int
+	}{
+		// A headquarters lists the branches of its bank, whatever their case, but not those of others
+		{"AAAAPLPWXXX", 3},
+		{"aaaaplpwxxx", 3},
+		{"AAAAPLPWBBB", 1},
+		{"BBBBPLPWXXX", 1},
+		// A headquarters stored in lowercase isn't listed as a branch of itself
+		{"CCCCPLPWXXX", 2},
+		{"ccccplpwxxx", 2},
+	}
+	for i := 0; i < len(tt); i++ {
+		if details, err := queries.GetCodeDetails(ctx, sqlcout.GetCodeDetailsParams{SwiftCode: tt[i].swiftCode}); len(details) != tt[i].want || err != nil {
+			t.Errorf(`GetCodeDetails("%v") = %d rows, %v, want %d rows`, tt[i].swiftCode, len(details), err, tt[i].want)
+		}
+	}
+}
+
+// substrGetCodeDetails is the headquarters lookup before the bank8 and is_headquarter columns, which
+// computes substr of every code
+const substrGetCodeDetails = `SELECT swift_code, address, bank_name, swift_codes.country_iso2, countries.country_name
+FROM swift_codes LEFT JOIN countries ON swift_codes.country_iso2 = countries.country_iso2
+WHERE swift_codes.swift_code = ?1
+UNION ALL
+SELECT swift_code, address, bank_name, swift_codes.country_iso2, countries.country_name
+FROM swift_codes LEFT JOIN countries ON swift_codes.country_iso2 = countries.country_iso2
+WHERE substr(?1, -3) = 'XXX' COLLATE NOCASE
+AND substr(swift_code, 1, 8) COLLATE NOCASE = substr(?1, 1, 8)
+AND NOT substr(swift_code, -3) = 'XXX' COLLATE NOCASE`
+
+const (
+	BENCHMARK_ROWS     = 100000
+	BENCHMARK_BRANCHES = 4
+)
+
+// BenchmarkGetCodeDetails looks up headquarters among BENCHMARK_ROWS codes, each bank having
+// BENCHMARK_BRANCHES branches, with the indexed columns and with the substr query they replaced
+func BenchmarkGetCodeDetails(b *testing.B) {
+	ctx := context.Background()
+	db, queries := setupSQLite(b)
+	queries.InsertCountry(ctx, sqlcout.InsertCountryParams{CountryISO2: "PL", CountryName: "POLAND"})
+	banks := BENCHMARK_ROWS / (BENCHMARK_BRANCHES + 1)
+	codes := make([]sqlcout.InsertSwiftCodeParams, 0, BENCHMARK_ROWS)
+	for i := 0; i < banks; i++ {
+		bank := fmt.Sprintf("%c%c%c%cPL%02d", 'A'+i/17576%26, 'A'+i/676%26, 'A'+i/26%26, 'A'+i%26, i%100)
+		codes = append(codes, sqlcout.InsertSwiftCodeParams{SwiftCode: bank + "XXX", CountryISO2: "PL"})
+		for j := 0; j < BENCHMARK_BRANCHES; j++ {
+			codes = append(codes, sqlcout.InsertSwiftCodeParams{SwiftCode: fmt.Sprintf("%sB%02d", bank, j), CountryISO2: "PL"})
+		}
+	}
+	if _, err := queries.InsertSwiftCodes(ctx, codes); err != nil {
+		b.Fatal(err)
+	}
+	headquarters := func(i int) string {
+		return codes[i%banks*(BENCHMARK_BRANCHES+1)].SwiftCode
+	}
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			details, err := queries.GetCodeDetails(ctx, sqlcout.GetCodeDetailsParams{SwiftCode: headquarters(i)})
+			if err != nil || len(details) != BENCHMARK_BRANCHES+1 {
+				b.Fatalf("GetCodeDetails() = %d rows, %v", len(details), err)
+			}
+		}
+	})
+	b.Run("substr", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rows, err := db.QueryContext(ctx, substrGetCodeDetails, headquarters(i))
+			if err != nil {
+				b.Fatal(err)
+			}
+			n := 0
+			for rows.Next() {
+				n++
+			}
+			rows.Close()
+			if n != BENCHMARK_BRANCHES+1 {
+				b.Fatalf("substr query = %d rows", n)
+			}
+		}
+	})
+}
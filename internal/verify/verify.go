@@ -0,0 +1,223 @@
+// Package verify checks import files against detached ed25519 signatures made with trusted keys.
+//
+// A file is signed either directly, by a signature in <file>.sig, or through a SHA-256 manifest listing
+// its digest, whose own signature is in <manifest>.sig. Manifests use the sha256sum format, a hex digest
+// and a file name per line. Signatures and keys are base64 encoded.
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SIGNATURE_SUFFIX is appended to the name of a file or manifest to find its detached signature
+const SIGNATURE_SUFFIX = ".sig"
+
+var (
+	ErrUnsigned  = errors.New("no signature")
+	ErrUntrusted = errors.New("signature doesn't verify with any trusted key")
+	ErrNotListed = errors.New("file isn't listed in the manifest")
+	ErrDigest    = errors.New("digest doesn't match the manifest")
+	ErrMalformed = errors.New("signature isn't a base64 ed25519 signature")
+)
+
+// Keys are the trusted public keys by signer name
+type Keys map[string]ed25519.PublicKey
+
+// Result describes a verified file
+type Result struct {
+	FileName string
+	// SHA256 is the hex digest of the file
+	SHA256 string
+	// Signer is the name of the trusted key that signed the file or its manifest
+	Signer string
+}
+
+// ParseKeys reads trusted keys, a signer name and a base64 public key per line. Blank lines and lines
+// starting with # are ignored.
+func ParseKeys(r io.Reader) (Keys, error) {
+	keys := make(Keys)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want a signer name and a key", line)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("line %d: key of %s isn't a base64 ed25519 public key", line, fields[0])
+		}
+		if _, ok := keys[fields[0]]; ok {
+			return nil, fmt.Errorf("line %d: signer %s listed twice", line, fields[0])
+		}
+		keys[fields[0]] = ed25519.PublicKey(key)
+	}
+	return keys, scanner.Err()
+}
+
+// LoadKeys reads the trusted keys file at path
+func LoadKeys(path string) (Keys, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keys, err := ParseKeys(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted keys file %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// Signer returns the name of the key that made signature of data
+func (keys Keys) Signer(data []byte, signature []byte) (string, error) {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ed25519.Verify(keys[name], data, signature) {
+			return name, nil
+		}
+	}
+	return "", ErrUntrusted
+}
+
+// File reads the file at path and verifies it with its signature, or with manifest and the manifest's
+// signature if manifest isn't empty. The content is returned so it is imported exactly as verified.
+func File(path string, manifest string, keys Keys) ([]byte, Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Result{}, err
+	}
+	result := Result{FileName: filepath.Base(path), SHA256: Digest(data)}
+	if manifest == "" {
+		if result.Signer, err = verifySignature(path, data, keys); err != nil {
+			return nil, Result{}, err
+		}
+		return data, result, nil
+	}
+
+	listing, err := os.ReadFile(manifest)
+	if err != nil {
+		return nil, Result{}, err
+	}
+	if result.Signer, err = verifySignature(manifest, listing, keys); err != nil {
+		return nil, Result{}, err
+	}
+	digests, err := ParseManifest(listing)
+	if err != nil {
+		return nil, Result{}, fmt.Errorf("invalid manifest %s: %w", manifest, err)
+	}
+	digest, ok := digests[result.FileName]
+	if !ok {
+		return nil, Result{}, fmt.Errorf("%w %s: %s", ErrNotListed, manifest, result.FileName)
+	}
+	if digest != result.SHA256 {
+		return nil, Result{}, fmt.Errorf("%w for %s: %s, listed %s", ErrDigest, result.FileName, result.SHA256, digest)
+	}
+	return data, result, nil
+}
+
+// verifySignature checks data, read from path, against the signature in path.sig
+func verifySignature(path string, data []byte, keys Keys) (string, error) {
+	encoded, err := os.ReadFile(path + SIGNATURE_SUFFIX)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w for %s, want %s%s", ErrUnsigned, path, path, SIGNATURE_SUFFIX)
+	}
+	if err != nil {
+		return "", err
+	}
+	signature, err := DecodeSignature(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%s%s: %w", path, SIGNATURE_SUFFIX, err)
+	}
+	signer, err := keys.Signer(data, signature)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// DecodeSignature decodes a signature in the format of the .sig files
+func DecodeSignature(encoded []byte) ([]byte, error) {
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, ErrMalformed
+	}
+	return signature, nil
+}
+
+// ParseManifest returns the digests of a manifest by file name
+func ParseManifest(manifest []byte) (map[string]string, error) {
+	digests := make(map[string]string)
+	for i, line := range strings.Split(strings.ReplaceAll(string(manifest), "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		digest, name, ok := strings.Cut(line, " ")
+		// sha256sum marks files read in binary mode with a * before the name
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		if _, err := hex.DecodeString(digest); !ok || err != nil || len(digest) != 2*sha256.Size || name == "" {
+			return nil, fmt.Errorf("line %d: want a SHA-256 hex digest and a file name", i+1)
+		}
+		digests[filepath.Base(name)] = strings.ToLower(digest)
+	}
+	return digests, nil
+}
+
+// Digest returns the SHA-256 hex digest of data
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Manifest lists the digests of files by name, in name order
+func Manifest(files map[string][]byte) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var manifest bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&manifest, "%s  %s\n", Digest(files[name]), name)
+	}
+	return manifest.Bytes()
+}
+
+// Sign returns the encoded detached signature of data, the content of a .sig file
+func Sign(key ed25519.PrivateKey, data []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)) + "\n")
+}
+
+// ParsePrivateKey decodes a base64 ed25519 private key or seed, as written by the keygen command
+func ParsePrivateKey(encoded []byte) (ed25519.PrivateKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, errors.New("private key isn't base64")
+	}
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	}
+	return nil, fmt.Errorf("private key has %d bytes, want %d or %d", len(key), ed25519.SeedSize, ed25519.PrivateKeySize)
+}
@@ -0,0 +1,112 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, content []byte) {
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("error writing %s: %v", path, err)
+	}
+}
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	return public, private
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	trustedPublic, trusted := newKey(t)
+	_, stranger := newKey(t)
+	keys := Keys{"vendor": trustedPublic}
+	data := []byte("SWIFT CODE\tNAME\nBIGBPLPWXXX\tBANK\n")
+	tampered := []byte("SWIFT CODE\tNAME\nBIGBPLPWXXX\tEVIL BANK\n")
+
+	signed := filepath.Join(dir, "signed.tsv")
+	writeFile(t, signed, data)
+	writeFile(t, signed+SIGNATURE_SUFFIX, Sign(trusted, data))
+	unsigned := filepath.Join(dir, "unsigned.tsv")
+	writeFile(t, unsigned, data)
+	untrusted := filepath.Join(dir, "untrusted.tsv")
+	writeFile(t, untrusted, data)
+	writeFile(t, untrusted+SIGNATURE_SUFFIX, Sign(stranger, data))
+	changed := filepath.Join(dir, "changed.tsv")
+	writeFile(t, changed, tampered)
+	writeFile(t, changed+SIGNATURE_SUFFIX, Sign(trusted, data))
+
+	manifest := filepath.Join(dir, "SHA256SUMS")
+	writeFile(t, manifest, Manifest(map[string][]byte{"unsigned.tsv": data, "changed.tsv": data}))
+	writeFile(t, manifest+SIGNATURE_SUFFIX, Sign(trusted, Manifest(map[string][]byte{"unsigned.tsv": data, "changed.tsv": data})))
+	forged := filepath.Join(dir, "FORGED")
+	writeFile(t, forged, Manifest(map[string][]byte{"changed.tsv": tampered}))
+	writeFile(t, forged+SIGNATURE_SUFFIX, Sign(trusted, Manifest(map[string][]byte{"changed.tsv": data})))
+
+	tt := []struct {
+		path       string
+		manifest   string
+		wantSigner string
+		wantErr    error
+	}{
+		{signed, "", "vendor", nil},
+		{unsigned, "", "", ErrUnsigned},
+		{untrusted, "", "", ErrUntrusted},
+		{changed, "", "", ErrUntrusted},
+		{unsigned, manifest, "vendor", nil},
+		{changed, manifest, "", ErrDigest},
+		{signed, manifest, "", ErrNotListed},
+		{changed, forged, "", ErrUntrusted},
+		{signed, unsigned, "", ErrUnsigned},
+	}
+	for i := 0; i < len(tt); i++ {
+		content, result, err := File(tt[i].path, tt[i].manifest, keys)
+		if !errors.Is(err, tt[i].wantErr) || result.Signer != tt[i].wantSigner {
+			t.Errorf(`File("%v", "%v") = %+v, %v, want signer %v, error %v`, tt[i].path, tt[i].manifest, result, err, tt[i].wantSigner, tt[i].wantErr)
+		}
+		if err == nil && (string(content) != string(data) || result.SHA256 != Digest(data) || result.FileName != filepath.Base(tt[i].path)) {
+			t.Errorf(`File("%v", "%v") = %q, %+v, want the verified content`, tt[i].path, tt[i].manifest, content, result)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	public, _ := newKey(t)
+	encoded := base64.StdEncoding.EncodeToString(public)
+	tt := []struct {
+		content  string
+		wantKeys int
+		wantErr  bool
+	}{
+		{"# trusted signers\n\nvendor " + encoded + "\n", 1, false},
+		{"vendor " + encoded + "\nbackup " + encoded + "\n", 2, false},
+		{"vendor\n", 0, true},
+		{"vendor c2hvcnQ=\n", 0, true},
+		{"vendor " + encoded + "\nvendor " + encoded + "\n", 0, true},
+	}
+	for i := 0; i < len(tt); i++ {
+		keys, err := ParseKeys(strings.NewReader(tt[i].content))
+		if len(keys) != tt[i].wantKeys || (err != nil) != tt[i].wantErr {
+			t.Errorf(`ParseKeys("%v") = %v keys, error %v, want %v keys, error %v`, tt[i].content, len(keys), err, tt[i].wantKeys, tt[i].wantErr)
+		}
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	digest := Digest([]byte("data"))
+	digests, err := ParseManifest([]byte(digest + "  dir/swiftcodes.tsv\r\n" + strings.ToUpper(digest) + " *bic.txt\n\n"))
+	if err != nil || digests["swiftcodes.tsv"] != digest || digests["bic.txt"] != digest {
+		t.Errorf("ParseManifest() = %v, %v, want both files with digest %s", digests, err, digest)
+	}
+	if _, err := ParseManifest([]byte("abc  swiftcodes.tsv\n")); err == nil {
+		t.Errorf("ParseManifest() of a short digest error = nil, want error")
+	}
+}
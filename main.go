@@ -3,66 +3,98 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"swiftcodes/internal/cache"
+	"swiftcodes/internal/config"
+	"swiftcodes/internal/importjob"
 	"swiftcodes/internal/initdb"
+	"swiftcodes/internal/jobs"
+	"swiftcodes/internal/migrate"
+	"swiftcodes/internal/seed"
+	"swiftcodes/internal/store"
+	"swiftcodes/internal/verify"
 	"swiftcodes/sqlcout"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/go-sql-driver/mysql"
 )
 
 const (
 	API_VERSION = "1"
 	API_NAME    = "swift-codes"
 	BASE_URI    = "/v" + API_VERSION + "/" + API_NAME
+	DATASET_URI = "/v" + API_VERSION + "/dataset"
+	EXPORT_URI  = BASE_URI + "/export"
 	COUNTRY     = "country"
+
+	// ACTOR_HEADER names who adds a code through the API, recorded as its source; the client IP otherwise
+	ACTOR_HEADER = "X-Actor"
+	// INCLUDE_PROVENANCE is the value of the include query parameter adding the source of a code to its details
+	INCLUDE_PROVENANCE = "provenance"
 )
 
 var (
-	DB_USER      = os.Getenv("SC_DB_USER")
-	DB_PASSWORD  = os.Getenv("SC_DB_PASSWORD")
-	DB_NAME      = os.Getenv("SC_DB_NAME")
-	DB_HOST      = os.Getenv("SC_DB_HOST")
-	DB_PORT      = os.Getenv("SC_DB_PORT")
-	API_HOST     = os.Getenv("SC_API_HOST")
-	API_PORT     = os.Getenv("SC_API_PORT")
-	DB_CONN_BASE = DB_USER + ":" + DB_PASSWORD + "@tcp(" + DB_HOST + ":" + DB_PORT + ")/"
-	ctx          context.Context
-	db           *sql.DB
-	queries      *sqlcout.Queries
+	db      *sql.DB
+	queries store.Store
+	// cached is queries, kept for the metrics of its caches
+	cached *store.CachedStore
+	// newExportWriter creates the writers of exports
+	newExportWriter = initdb.NewExportWriter
 )
 
-// Endpoint 1: Retrieve details of a single SWIFT code whether for a headquarters or branches
+// Endpoint 1: Retrieve details of a single SWIFT code whether for a headquarters or branches,
+// with ?include=provenance also where the code comes from
 func GetCodeDetailsHandler(c *gin.Context) {
 	swift_code, _ := c.Params.Get("swift_code")
-	details, err := queries.GetCodeDetails(ctx, sqlcout.GetCodeDetailsParams{SwiftCode: swift_code})
+	withProvenance := false
+	for _, include := range strings.Split(c.Query("include"), ",") {
+		switch include {
+		case "":
+		case INCLUDE_PROVENANCE:
+			withProvenance = true
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "400 unknown include " + include})
+			return
+		}
+	}
+	details, err := queries.GetCodeDetails(c.Request.Context(), sqlcout.GetCodeDetailsParams{SwiftCode: swift_code})
 	if err != nil {
-		log.Print("Failed in query GetCodeDetails: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "500 internal server error"})
+		AbortWithStoreError(c, "GetCodeDetails", err, swift_code, "")
 		return
 	}
-	if details == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "404 swift code " + swift_code + " not found"})
-		return
+	response := MakeDetailsResponse(details)
+	if withProvenance {
+		source, err := queries.GetSwiftCodeSource(c.Request.Context(), details[0].SwiftCode)
+		if err != nil {
+			AbortWithStoreError(c, "GetSwiftCodeSource", err, swift_code, "")
+			return
+		}
+		response.Provenance = MakeProvenanceResponse(source)
 	}
-	c.JSON(http.StatusOK, MakeDetailsResponse(details))
+	c.JSON(http.StatusOK, response)
 }
 
 // Endpoint 2: Return all SWIFT codes with details for a specific country (both headquarters and branches)
 func GetCodeDetailsByCountryCodeHandler(c *gin.Context) {
 	countryISO2, _ := c.Params.Get("country_iso2")
-	country, err := queries.GetCountry(ctx, countryISO2)
+	country, err := queries.GetCountry(c.Request.Context(), countryISO2)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "404 country with ISO2 code " + countryISO2 + " not found"})
+		AbortWithStoreError(c, "GetCountry", err, "", countryISO2)
 		return
 	}
-	details, err := queries.GetCodeDetailsByCountryCode(ctx, countryISO2)
+	details, err := queries.GetCodeDetailsByCountryCode(c.Request.Context(), countryISO2)
 	if err != nil {
-		log.Print("Failed in query GetCodeDetailsByCountryCode: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "500 internal server error"})
+		AbortWithStoreError(c, "GetCodeDetailsByCountryCode", err, "", countryISO2)
 		return
 	}
 	c.JSON(http.StatusOK, MakeDetailsByCountryCodeResponse(country, details))
@@ -71,31 +103,21 @@ func GetCodeDetailsByCountryCodeHandler(c *gin.Context) {
 // Endpoint 3: Adds new SWIFT code entries to the database for a specific country
 func PostSwiftCodeHandler(c *gin.Context) {
 	var newCode DetailsInputPayload
-	if err := c.BindJSON(&newCode); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "400 bad request structure"})
+	if !BindJSON(c, &newCode) {
 		return
 	}
 	if err := ValidateDetailsInputPayload(newCode); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "400 " + err.Error()})
 		return
 	}
-	if _, err := queries.InsertSwiftCode(ctx, sqlcout.InsertSwiftCodeParams{
+	if _, err := queries.InsertSwiftCode(c.Request.Context(), sqlcout.InsertSwiftCodeParams{
 		Address:     newCode.Address,
 		BankName:    newCode.BankName,
 		CountryISO2: newCode.CountryISO2,
 		SwiftCode:   newCode.SwiftCode,
+		Actor:       Actor(c),
 	}); err != nil {
-		msg := strings.Split(err.Error(), " ")
-		if len(msg) >= 2 {
-			if msg[1] == "1452" {
-				c.JSON(http.StatusConflict, gin.H{"error": "409 no country with ISO2 code " + newCode.CountryISO2})
-				return
-			} else if msg[1] == "1062" {
-				c.JSON(http.StatusConflict, gin.H{"error": "409 swift code " + newCode.SwiftCode + " already exists"})
-				return
-			}
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "500 internal server error"})
+		AbortWithStoreError(c, "InsertSwiftCode", err, newCode.SwiftCode, newCode.CountryISO2)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"message": "201 swift code " + newCode.SwiftCode + " created"})
@@ -104,57 +126,238 @@ func PostSwiftCodeHandler(c *gin.Context) {
 // Endpoint 4: Deletes swift-code data if swiftCode matches the one in the database
 func DeleteSwiftCodeHandler(c *gin.Context) {
 	swift_code, _ := c.Params.Get("swift_code")
-	result, err := queries.DeleteSwiftCode(ctx, swift_code)
+	if _, err := queries.DeleteSwiftCode(c.Request.Context(), swift_code); err != nil {
+		AbortWithStoreError(c, "DeleteSwiftCode", err, swift_code, "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "200 swift code " + swift_code + " deleted"})
+}
+
+// Endpoint 5: Describes the dataset embedded in the binary, which new databases are seeded with unless configured otherwise
+func GetDatasetHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, seed.Embedded())
+}
+
+// Endpoint 6: Corrects the address or bank name of a SWIFT code, flagging the fields as corrected so that
+// imports don't silently revert them
+func PatchSwiftCodeHandler(c *gin.Context) {
+	swift_code, _ := c.Params.Get("swift_code")
+	var edit DetailsEditPayload
+	if !BindJSON(c, &edit) {
+		return
+	}
+	if err := ValidateDetailsEditPayload(edit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "400 " + err.Error()})
+		return
+	}
+	if _, err := queries.EditSwiftCode(c.Request.Context(), sqlcout.EditSwiftCodeParams{
+		Address:   nullString(edit.Address),
+		BankName:  nullString(edit.BankName),
+		Actor:     Actor(c),
+		SwiftCode: swift_code,
+	}); err != nil {
+		AbortWithStoreError(c, "EditSwiftCode", err, swift_code, "")
+		return
+	}
+	// The update doesn't tell missing codes apart from unchanged ones on every database
+	if _, err := queries.GetSwiftCodeSource(c.Request.Context(), swift_code); err != nil {
+		AbortWithStoreError(c, "GetSwiftCodeSource", err, swift_code, "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "200 swift code " + swift_code + " updated"})
+}
+
+// Endpoint 7: Streams the stored SWIFT codes, or those of one country, as CSV, TSV, JSON Lines or XLSX
+func ExportHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", initdb.EXPORT_CSV)
+	if !slices.Contains(initdb.EXPORT_FORMATS, format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "400 unknown format " + format + ", want one of " + strings.Join(initdb.EXPORT_FORMATS, ", ")})
+		return
+	}
+	country := c.Query(COUNTRY)
+	if country != "" {
+		if _, err := queries.GetCountry(c.Request.Context(), country); err != nil {
+			AbortWithStoreError(c, "GetCountry", err, "", country)
+			return
+		}
+	}
+	c.Header("Content-Type", initdb.EXPORT_CONTENT_TYPES[format])
+	c.Header("Content-Disposition", `attachment; filename="`+API_NAME+"."+format+`"`)
+	writer, err := newExportWriter(c.Writer, format)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "500 internal server error"})
+		AbortWithStoreError(c, "NewExportWriter", err, "", country)
 		return
 	}
-	rows, err := result.RowsAffected()
+	err = queries.EachSwiftCode(c.Request.Context(), country, writer.Write)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "500 internal server error"})
+		writer.Discard()
+	} else if err = writer.Close(); err == nil {
 		return
 	}
-	if rows == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "404 swift code " + swift_code + " not found"})
+	// Once rows are sent the status can't change, the client gets a truncated file instead
+	if c.Writer.Written() {
+		log.Print("Failed to export swift codes: ", err)
+		c.Abort()
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "200 swift code " + swift_code + " deleted"})
+	c.Writer.Header().Del("Content-Type")
+	c.Writer.Header().Del("Content-Disposition")
+	AbortWithStoreError(c, "EachSwiftCode", err, "", country)
 }
 
-func SetupRouter(db_conn_base string, db_name string) (*gin.Engine, error) {
+// BindJSON decodes the request body into v, responding with 413 or 400 and returning false if it can't
+func BindJSON(c *gin.Context, v any) bool {
+	err := c.ShouldBindJSON(v)
+	if err == nil {
+		return true
+	}
+	if !abortWithTooLarge(c, err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "400 bad request structure"})
+	}
+	return false
+}
+
+// abortWithTooLarge responds with 413 and returns true if err is caused by a request body over its limit
+func abortWithTooLarge(c *gin.Context, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return false
+	}
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "413 request body larger than " + strconv.FormatInt(maxBytesErr.Limit, 10) + " bytes"})
+	return true
+}
+
+// Actor names who makes a request for the provenance of the codes it writes
+func Actor(c *gin.Context) string {
+	if actor := c.GetHeader(ACTOR_HEADER); actor != "" {
+		return actor
+	}
+	return c.ClientIP()
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+func SetupRouter(cfg config.Config) (*gin.Engine, error) {
 	// Create DB object and check connection
-	ctx = context.Background()
-	db, err := sql.Open("mysql", db_conn_base+db_name)
+	var err error
+	db, err = store.Open(cfg.DB)
 	if err != nil {
 		return nil, err
 	}
-	err = db.Ping()
-	if err != nil {
+	if err := migrate.Check(context.Background(), db, cfg.DB.Driver); err != nil {
+		db.Close()
 		return nil, err
 	}
-	queries = sqlcout.New(db)
+	cached = store.NewCached(store.New(cfg.DB.Driver, db), cfg.Cache.Size, cfg.Cache.TTL)
+	queries = cached
+	if cfg.Cache.Redis != "" {
+		shared, err := cache.NewRedis(cfg.Cache.Redis, cfg.Cache.TTL, cfg.Cache.RedisPrefix)
+		if err == nil {
+			if err = cached.Share(context.Background(), shared); err != nil {
+				shared.Close()
+			}
+		}
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("shared cache: %w", err)
+		}
+	}
+
+	// The runner is started by main, so tests only run jobs when they start it
+	runner = jobs.NewRunner(queries, jobs.Options{
+		Workers:     cfg.Jobs.Workers,
+		MaxAttempts: cfg.Jobs.MaxAttempts,
+		Backoff:     cfg.Jobs.RetryBackoff,
+	})
+	// Imports write without the cache, which is cleared once per attempt rather than once per code
+	runner.Handle(importjob.KIND, importjob.Handler(cached.Store, cached.InvalidateAll))
+	jobsDir = cfg.Jobs.Dir
+	trustedKeys = nil
+	if cfg.Import.TrustedKeys != "" {
+		if trustedKeys, err = verify.LoadKeys(cfg.Import.TrustedKeys); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
 
 	router := gin.Default()
 	router.SetTrustedProxies(nil)
 
-	// Link API endpoints
-	router.GET(BASE_URI+"/:swift_code", GetCodeDetailsHandler)
-	router.GET(BASE_URI+"/country/:country_iso2", GetCodeDetailsByCountryCodeHandler)
-	router.POST(BASE_URI, PostSwiftCodeHandler)
-	router.DELETE(BASE_URI+"/:swift_code", DeleteSwiftCodeHandler)
+	// Link API endpoints, each bounded by the query timeout of its route, or the export timeout for exports
+	api := cfg.API
+	public := router.Group("", LimitBody(api.MaxBodyBytes))
+	public.GET(BASE_URI+"/:swift_code", WithQueryTimeout(api.QueryTimeoutFor(config.ROUTE_GET_CODE)), GetCodeDetailsHandler)
+	public.GET(BASE_URI+"/country/:country_iso2", WithQueryTimeout(api.QueryTimeoutFor(config.ROUTE_GET_COUNTRY)), GetCodeDetailsByCountryCodeHandler)
+	public.POST(BASE_URI, WithQueryTimeout(api.QueryTimeoutFor(config.ROUTE_POST_CODE)), PostSwiftCodeHandler)
+	public.DELETE(BASE_URI+"/:swift_code", WithQueryTimeout(api.QueryTimeoutFor(config.ROUTE_DELETE_CODE)), DeleteSwiftCodeHandler)
+	public.PATCH(BASE_URI+"/:swift_code", WithQueryTimeout(api.QueryTimeoutFor(config.ROUTE_PATCH_CODE)), PatchSwiftCodeHandler)
+	public.GET(EXPORT_URI, WithWriteTimeout(api.ExportTimeout), WithQueryTimeout(api.ExportTimeout), ExportHandler)
+	public.GET(DATASET_URI, GetDatasetHandler)
+	public.GET(METRICS_URI, MetricsHandler)
+
+	// The admin API takes import files, so it has its own body limit
+	if api.AdminToken != "" {
+		admin := router.Group(IMPORTS_URI, LimitBody(api.MaxUploadBytes), RequireAdmin(api.AdminToken))
+		admin.POST("", PostImportHandler)
+		admin.GET("/:id", GetImportHandler)
+		admin.DELETE("/:id", DeleteImportHandler)
+		jobList := router.Group(JOBS_URI, LimitBody(api.MaxBodyBytes), RequireAdmin(api.AdminToken))
+		jobList.GET("", ListJobsHandler)
+		jobList.GET("/:id", GetJobHandler)
+	}
 
 	return router, nil
 }
 
 func main() {
-	if !initdb.DBExists(DB_NAME) {
-		db = initdb.SetupDB(DB_NAME, false)
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	if err := migrate.SetDir(cfg.DB.Migrations); err != nil {
+		log.Fatal("Invalid configuration: ", err)
 	}
 
-	router, err := SetupRouter(DB_CONN_BASE, DB_NAME)
+	if !initdb.DBExists(cfg.DB) {
+		initdb.SetupDB(cfg.DB, false).Close()
+	}
+
+	router, err := SetupRouter(cfg)
+	if err != nil {
+		log.Fatal("Error opening DB: ", err)
+	}
+	OnShutdown(func() {
+		if err := db.Close(); err != nil {
+			log.Print("Failed to close DB: ", err)
+		}
+	})
+	OnShutdown(func() {
+		if err := cached.Close(); err != nil {
+			log.Print("Failed to close the shared cache: ", err)
+		}
+	})
+	// Hooks run in reverse, so jobs stop before the DB and the shared cache are closed
+	runner.Start()
+	OnShutdown(runner.Close)
+
+	listener, err := net.Listen("tcp", cfg.API.Addr())
 	if err != nil {
-		log.Fatal("Error connecting to DB: ", err)
+		log.Fatal("Failed to listen on "+cfg.API.Addr()+": ", err)
 	}
+	log.Print("Listening and serving HTTP on ", listener.Addr())
 
-	router.Run(API_HOST + ":" + API_PORT)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := Serve(ctx, NewServer(cfg.API, router), listener, cfg.API.ShutdownTimeout); err != nil {
+		log.Fatal("Server stopped with error: ", err)
+	}
+	log.Print("Server stopped")
 }
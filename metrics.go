@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"swiftcodes/internal/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+const METRICS_URI = "/metrics"
+
+// metric is a value of every cache written in the Prometheus text format
+type metric struct {
+	name  string
+	kind  string
+	help  string
+	value func(stats cache.Stats) uint64
+}
+
+var cacheMetrics = []metric{
+	{"swiftcodes_cache_hits_total", "counter", "Lookups answered from the cache.", func(stats cache.Stats) uint64 { return stats.Hits }},
+	{"swiftcodes_cache_misses_total", "counter", "Lookups that queried the database.", func(stats cache.Stats) uint64 { return stats.Misses }},
+	{"swiftcodes_cache_evictions_total", "counter", "Entries dropped to make room for new ones.", func(stats cache.Stats) uint64 { return stats.Evictions }},
+	{"swiftcodes_cache_entries", "gauge", "Entries held by the cache.", func(stats cache.Stats) uint64 { return uint64(stats.Entries) }},
+}
+
+// sharedCacheMetrics are reported when the API replicas share a cache
+var sharedCacheMetrics = []metric{
+	{"swiftcodes_shared_cache_hits_total", "counter", "Lookups missing the process cache answered from the shared cache.", func(stats cache.Stats) uint64 { return stats.Hits }},
+	{"swiftcodes_shared_cache_misses_total", "counter", "Lookups missing the process cache that queried the database.", func(stats cache.Stats) uint64 { return stats.Misses }},
+}
+
+// Endpoint 8: Reports the hits and misses of the lookup caches in the Prometheus text format
+func MetricsHandler(c *gin.Context) {
+	var b strings.Builder
+	writeMetrics(&b, cacheMetrics, cached.Stats())
+	if shared := cached.SharedStats(); shared != nil {
+		writeMetrics(&b, sharedCacheMetrics, shared)
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
+
+func writeMetrics(b *strings.Builder, metrics []metric, stats map[string]cache.Stats) {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, m := range metrics {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, name := range names {
+			fmt.Fprintf(b, "%s{cache=%q} %d\n", m.name, name, m.value(stats[name]))
+		}
+	}
+}
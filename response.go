@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"strings"
+	"swiftcodes/internal/initdb"
 	"swiftcodes/sqlcout"
 )
 
@@ -19,6 +20,7 @@ type DetailsMainResponse struct {
 	IsHeadquarter bool                      `json:"isHeadquarter"`
 	SwiftCode     string                    `json:"swiftCode"`
 	Branches      []DetailsListItemResponse `json:"branches,omitempty"`
+	Provenance    *ProvenanceResponse       `json:"provenance,omitempty"`
 }
 
 type DetailsListItemResponse struct {
@@ -43,6 +45,7 @@ func MakeDetailsResponse(details []sqlcout.GetCodeDetailsRow) DetailsMainRespons
 		hq,
 		details[0].SwiftCode,
 		[]DetailsListItemResponse{},
+		nil,
 	}
 	for i := 1; i < len(details); i++ {
 		response.Branches = append(response.Branches, DetailsListItemResponse{
@@ -56,13 +59,56 @@ func MakeDetailsResponse(details []sqlcout.GetCodeDetailsRow) DetailsMainRespons
 	return response
 }
 
+// Sources of a stored code
+const (
+	SOURCE_IMPORT  = "import"
+	SOURCE_API     = "api"
+	SOURCE_UNKNOWN = "unknown"
+)
+
+// ProvenanceResponse tells where a stored code comes from: the import batch that last wrote it, or the
+// actor that added it through the API. Codes stored before provenance was tracked have an unknown source.
+// Overrides lists the fields corrected through the API since, by Actor.
+type ProvenanceResponse struct {
+	Source     string   `json:"source"`
+	ImportID   string   `json:"importID,omitempty"`
+	FileName   string   `json:"fileName,omitempty"`
+	Sha256     string   `json:"sha256,omitempty"`
+	Signer     string   `json:"signer,omitempty"`
+	ImportedAt string   `json:"importedAt,omitempty"`
+	Actor      string   `json:"actor,omitempty"`
+	Overrides  []string `json:"overrides,omitempty"`
+}
+
+func MakeProvenanceResponse(source sqlcout.GetSwiftCodeSourceRow) *ProvenanceResponse {
+	response := &ProvenanceResponse{Source: SOURCE_UNKNOWN, Actor: source.Actor}
+	switch {
+	case source.ImportID.Valid:
+		response.Source = SOURCE_IMPORT
+		response.ImportID = source.ImportID.String
+		response.FileName = source.FileName.String
+		response.Sha256 = source.Sha256.String
+		response.Signer = source.Signer.String
+		response.ImportedAt = source.ImportedAt.String
+	case source.Actor != "":
+		response.Source = SOURCE_API
+	}
+	if source.AddressOverride {
+		response.Overrides = append(response.Overrides, initdb.FIELD_ADDRESS)
+	}
+	if source.BankNameOverride {
+		response.Overrides = append(response.Overrides, initdb.FIELD_BANK_NAME)
+	}
+	return response
+}
+
 type DetailsByCountryCodeResponse struct {
 	CountryISO2 string                    `json:"countryISO2"`
 	CountryName string                    `json:"countryName"`
 	SwiftCodes  []DetailsListItemResponse `json:"swiftCodes"`
 }
 
-func MakeDetailsByCountryCodeResponse(country sqlcout.Country, details []sqlcout.SwiftCode) DetailsByCountryCodeResponse {
+func MakeDetailsByCountryCodeResponse(country sqlcout.Country, details []sqlcout.GetCodeDetailsByCountryCodeRow) DetailsByCountryCodeResponse {
 	response := DetailsByCountryCodeResponse{
 		country.CountryISO2,
 		country.CountryName,
@@ -101,3 +147,19 @@ func ValidateDetailsInputPayload(details DetailsInputPayload) error {
 	}
 	return nil
 }
+
+// DetailsEditPayload corrects the fields of a stored code that are present
+type DetailsEditPayload struct {
+	Address  *string `json:"address"`
+	BankName *string `json:"bankName"`
+}
+
+func ValidateDetailsEditPayload(edit DetailsEditPayload) error {
+	if edit.Address == nil && edit.BankName == nil {
+		return errors.New("address or bankName is required")
+	}
+	if edit.BankName != nil && *edit.BankName == "" {
+		return errors.New("bankName must not be empty")
+	}
+	return nil
+}
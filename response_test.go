@@ -99,7 +99,7 @@ func TestMakeDetailsResponse(t *testing.T) {
 func TestMakeDetailsByCountryCodeResponse(t *testing.T) {
 	tt := []struct {
 		country   sqlcout.Country
-		swiftcode []sqlcout.SwiftCode
+		swiftcode []sqlcout.GetCodeDetailsByCountryCodeRow
 		want      DetailsByCountryCodeResponse
 	}{
 		{
@@ -107,7 +107,7 @@ func TestMakeDetailsByCountryCodeResponse(t *testing.T) {
 				CountryISO2: "WT",
 				CountryName: "WATANIA",
 			},
-			[]sqlcout.SwiftCode{
+			[]sqlcout.GetCodeDetailsByCountryCodeRow{
 				{
 					SwiftCode:   "AXXX",
 					Address:     "",
@@ -147,7 +147,7 @@ func TestMakeDetailsByCountryCodeResponse(t *testing.T) {
 				CountryISO2: "WT",
 				CountryName: "WATANIA",
 			},
-			[]sqlcout.SwiftCode{},
+			[]sqlcout.GetCodeDetailsByCountryCodeRow{},
 			DetailsByCountryCodeResponse{
 				CountryISO2: "WT",
 				CountryName: "WATANIA",
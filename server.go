@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"swiftcodes/internal/config"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	shutdownHooks   []func()
+	shutdownHooksMu sync.Mutex
+)
+
+// LimitBody makes reading more than limit bytes of the request body fail with *http.MaxBytesError
+func LimitBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+func NewServer(api config.API, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              api.Addr(),
+		Handler:           handler,
+		ReadTimeout:       api.ReadTimeout,
+		ReadHeaderTimeout: api.ReadHeaderTimeout,
+		WriteTimeout:      api.WriteTimeout,
+		IdleTimeout:       api.IdleTimeout,
+		MaxHeaderBytes:    int(api.MaxHeaderBytes),
+	}
+}
+
+// OnShutdown registers f to run once the server stopped serving requests, e.g. to close the DB or flush buffers.
+// Hooks run in reverse order of registration.
+func OnShutdown(f func()) {
+	shutdownHooksMu.Lock()
+	defer shutdownHooksMu.Unlock()
+	shutdownHooks = append(shutdownHooks, f)
+}
+
+func runShutdownHooks() {
+	shutdownHooksMu.Lock()
+	hooks := shutdownHooks
+	shutdownHooks = nil
+	shutdownHooksMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// Serve accepts connections on listener until ctx is done, then stops accepting new ones and waits
+// up to shutdownTimeout for in-flight requests to finish before running the shutdown hooks
+func Serve(ctx context.Context, server *http.Server, listener net.Listener, shutdownTimeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		runShutdownHooks()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Print("Shutting down, waiting up to ", shutdownTimeout, " for in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Print("Shutdown deadline passed, closing remaining connections")
+		server.Close()
+	}
+	if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) {
+		err = errors.Join(err, serveErr)
+	}
+	runShutdownHooks()
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"swiftcodes/internal/config"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("TestServeDrainsInFlightRequests() listen error: %v", err)
+	}
+
+	var hooks []string
+	OnShutdown(func() { hooks = append(hooks, "first") })
+	OnShutdown(func() { hooks = append(hooks, "second") })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, NewServer(config.API{}, handler), listener, 5*time.Second)
+	}()
+
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+
+	<-started
+	cancel()
+	if out := <-response; out != "done" {
+		t.Errorf("TestServeDrainsInFlightRequests() in-flight response %q, want %q", out, "done")
+	}
+	if err := <-served; err != nil {
+		t.Errorf("TestServeDrainsInFlightRequests() Serve() = %v, want nil", err)
+	}
+	if strings.Join(hooks, ",") != "second,first" {
+		t.Errorf("TestServeDrainsInFlightRequests() shutdown hooks ran as %v, want [second first]", hooks)
+	}
+	if _, err := http.Get("http://" + listener.Addr().String()); err == nil {
+		t.Errorf("TestServeDrainsInFlightRequests() server still accepting connections after shutdown")
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	router := gin.New()
+	router.Use(LimitBody(32))
+	router.POST(BASE_URI, PostSwiftCodeHandler)
+
+	w := httptest.NewRecorder()
+	payload := `{"address":"` + strings.Repeat("A", 64) + `"}`
+	req, _ := http.NewRequest(http.MethodPost, BASE_URI, strings.NewReader(payload))
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("TestLimitBody() response code %v, want %v", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	responseCorrect, err := JSONEqual(`{"error":"413 request body larger than 32 bytes"}`, w.Body.String())
+	if err != nil || !responseCorrect {
+		t.Errorf("TestLimitBody() response %v", w.Body.String())
+	}
+}
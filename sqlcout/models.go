@@ -4,14 +4,60 @@
 
 package sqlcout
 
+import (
+	"database/sql"
+)
+
 type Country struct {
 	CountryISO2 string `json:"countryISO2"`
 	CountryName string `json:"countryName"`
 }
 
+type Import struct {
+	ID         string `json:"id"`
+	FileName   string `json:"fileName"`
+	Sha256     string `json:"sha256"`
+	Signer     string `json:"signer"`
+	ImportedAt string `json:"importedAt"`
+}
+
+type Job struct {
+	ID          string `json:"id"`
+	Kind        string `json:"kind"`
+	Payload     string `json:"payload"`
+	State       string `json:"state"`
+	Attempts    int64  `json:"attempts"`
+	MaxAttempts int64  `json:"maxAttempts"`
+	RunAfter    string `json:"runAfter"`
+	Progress    string `json:"progress"`
+	Error       string `json:"error"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+	FinishedAt  string `json:"finishedAt"`
+}
+
 type SwiftCode struct {
-	SwiftCode   string `json:"swiftCode"`
-	Address     string `json:"address"`
-	BankName    string `json:"bankName"`
-	CountryISO2 string `json:"countryISO2"`
+	SwiftCode        string         `json:"swiftCode"`
+	Address          string         `json:"address"`
+	BankName         string         `json:"bankName"`
+	CountryISO2      string         `json:"countryISO2"`
+	ImportID         sql.NullString `json:"importID"`
+	Actor            string         `json:"actor"`
+	AddressOverride  bool           `json:"addressOverride"`
+	BankNameOverride bool           `json:"bankNameOverride"`
+	Bank8            sql.NullString `json:"bank8"`
+	IsHeadquarter    sql.NullBool   `json:"isHeadquarter"`
+}
+
+type SwiftCodeChange struct {
+	ImportID         string         `json:"importID"`
+	SwiftCode        string         `json:"swiftCode"`
+	Address          string         `json:"address"`
+	BankName         string         `json:"bankName"`
+	CountryISO2      string         `json:"countryISO2"`
+	PreviousImportID sql.NullString `json:"previousImportID"`
+	Actor            string         `json:"actor"`
+	AddressOverride  bool           `json:"addressOverride"`
+	BankNameOverride bool           `json:"bankNameOverride"`
+	Removed          bool           `json:"removed"`
 }
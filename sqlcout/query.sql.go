@@ -23,12 +23,12 @@ const getCodeDetails = `-- name: GetCodeDetails :many
 SELECT swift_code, address, bank_name, swift_codes.country_iso2, countries.country_name
 FROM swift_codes LEFT JOIN countries ON swift_codes.country_iso2 = countries.country_iso2
 WHERE swift_codes.swift_code = ?
-UNION 
+UNION ALL
 SELECT swift_code, address, bank_name, swift_codes.country_iso2, countries.country_name
 FROM swift_codes LEFT JOIN countries ON swift_codes.country_iso2 = countries.country_iso2
 WHERE RIGHT(?, 3) = "XXX"
-AND LEFT(swift_code, 8) = LEFT(?, 8)
-AND NOT RIGHT(swift_code, 3) = "XXX"
+AND bank8 = LEFT(?, 8)
+AND is_headquarter = FALSE
 `
 
 type GetCodeDetailsParams struct {
@@ -78,15 +78,22 @@ FROM swift_codes
 WHERE country_iso2 = ?
 `
 
-func (q *Queries) GetCodeDetailsByCountryCode(ctx context.Context, countryIso2 string) ([]SwiftCode, error) {
+type GetCodeDetailsByCountryCodeRow struct {
+	SwiftCode   string `json:"swiftCode"`
+	Address     string `json:"address"`
+	BankName    string `json:"bankName"`
+	CountryISO2 string `json:"countryISO2"`
+}
+
+func (q *Queries) GetCodeDetailsByCountryCode(ctx context.Context, countryIso2 string) ([]GetCodeDetailsByCountryCodeRow, error) {
 	rows, err := q.db.QueryContext(ctx, getCodeDetailsByCountryCode, countryIso2)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []SwiftCode
+	var items []GetCodeDetailsByCountryCodeRow
 	for rows.Next() {
-		var i SwiftCode
+		var i GetCodeDetailsByCountryCodeRow
 		if err := rows.Scan(
 			&i.SwiftCode,
 			&i.Address,
@@ -134,15 +141,17 @@ func (q *Queries) InsertCountry(ctx context.Context, arg InsertCountryParams) (s
 }
 
 const insertSwiftCode = `-- name: InsertSwiftCode :execresult
-INSERT INTO swift_codes (swift_code, address, bank_name, country_iso2)
-VALUES (?, ?, ?, ?)
+INSERT INTO swift_codes (swift_code, address, bank_name, country_iso2, import_id, actor)
+VALUES (?, ?, ?, ?, ?, ?)
 `
 
 type InsertSwiftCodeParams struct {
-	SwiftCode   string `json:"swiftCode"`
-	Address     string `json:"address"`
-	BankName    string `json:"bankName"`
-	CountryISO2 string `json:"countryISO2"`
+	SwiftCode   string         `json:"swiftCode"`
+	Address     string         `json:"address"`
+	BankName    string         `json:"bankName"`
+	CountryISO2 string         `json:"countryISO2"`
+	ImportID    sql.NullString `json:"importID"`
+	Actor       string         `json:"actor"`
 }
 
 func (q *Queries) InsertSwiftCode(ctx context.Context, arg InsertSwiftCodeParams) (sql.Result, error) {
@@ -151,5 +160,686 @@ func (q *Queries) InsertSwiftCode(ctx context.Context, arg InsertSwiftCodeParams
 		arg.Address,
 		arg.BankName,
 		arg.CountryISO2,
+		arg.ImportID,
+		arg.Actor,
+	)
+}
+
+const listSwiftCodes = `-- name: ListSwiftCodes :many
+SELECT swift_codes.swift_code, swift_codes.address, swift_codes.bank_name, swift_codes.country_iso2, countries.country_name, swift_codes.actor, swift_codes.address_override, swift_codes.bank_name_override
+FROM swift_codes JOIN countries ON swift_codes.country_iso2 = countries.country_iso2
+ORDER BY swift_codes.swift_code
+`
+
+type ListSwiftCodesRow struct {
+	SwiftCode        string `json:"swiftCode"`
+	Address          string `json:"address"`
+	BankName         string `json:"bankName"`
+	CountryISO2      string `json:"countryISO2"`
+	CountryName      string `json:"countryName"`
+	Actor            string `json:"actor"`
+	AddressOverride  bool   `json:"addressOverride"`
+	BankNameOverride bool   `json:"bankNameOverride"`
+}
+
+func (q *Queries) ListSwiftCodes(ctx context.Context) ([]ListSwiftCodesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSwiftCodes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSwiftCodesRow
+	for rows.Next() {
+		var i ListSwiftCodesRow
+		if err := rows.Scan(
+			&i.SwiftCode,
+			&i.Address,
+			&i.BankName,
+			&i.CountryISO2,
+			&i.CountryName,
+			&i.Actor,
+			&i.AddressOverride,
+			&i.BankNameOverride,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateSwiftCode = `-- name: UpdateSwiftCode :execresult
+UPDATE swift_codes
+SET address = ?, bank_name = ?, country_iso2 = ?, import_id = ?, actor = ?, address_override = ?, bank_name_override = ?
+WHERE swift_code = ?
+`
+
+type UpdateSwiftCodeParams struct {
+	Address          string         `json:"address"`
+	BankName         string         `json:"bankName"`
+	CountryISO2      string         `json:"countryISO2"`
+	ImportID         sql.NullString `json:"importID"`
+	Actor            string         `json:"actor"`
+	AddressOverride  bool           `json:"addressOverride"`
+	BankNameOverride bool           `json:"bankNameOverride"`
+	SwiftCode        string         `json:"swiftCode"`
+}
+
+func (q *Queries) UpdateSwiftCode(ctx context.Context, arg UpdateSwiftCodeParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, updateSwiftCode,
+		arg.Address,
+		arg.BankName,
+		arg.CountryISO2,
+		arg.ImportID,
+		arg.Actor,
+		arg.AddressOverride,
+		arg.BankNameOverride,
+		arg.SwiftCode,
+	)
+}
+
+const insertImport = `-- name: InsertImport :execresult
+INSERT INTO imports (id, file_name, sha256, signer, imported_at)
+VALUES (?, ?, ?, ?, ?)
+`
+
+type InsertImportParams struct {
+	ID         string `json:"id"`
+	FileName   string `json:"fileName"`
+	Sha256     string `json:"sha256"`
+	Signer     string `json:"signer"`
+	ImportedAt string `json:"importedAt"`
+}
+
+func (q *Queries) InsertImport(ctx context.Context, arg InsertImportParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, insertImport,
+		arg.ID,
+		arg.FileName,
+		arg.Sha256,
+		arg.Signer,
+		arg.ImportedAt,
+	)
+}
+
+const listImports = `-- name: ListImports :many
+SELECT imports.id, imports.file_name, imports.sha256, imports.signer, imports.imported_at, COUNT(swift_codes.swift_code) AS records
+FROM imports LEFT JOIN swift_codes ON swift_codes.import_id = imports.id
+GROUP BY imports.id, imports.file_name, imports.sha256, imports.signer, imports.imported_at
+ORDER BY imports.imported_at, imports.id
+`
+
+type ListImportsRow struct {
+	ID         string `json:"id"`
+	FileName   string `json:"fileName"`
+	Sha256     string `json:"sha256"`
+	Signer     string `json:"signer"`
+	ImportedAt string `json:"importedAt"`
+	Records    int64  `json:"records"`
+}
+
+func (q *Queries) ListImports(ctx context.Context) ([]ListImportsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listImports)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListImportsRow
+	for rows.Next() {
+		var i ListImportsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FileName,
+			&i.Sha256,
+			&i.Signer,
+			&i.ImportedAt,
+			&i.Records,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSwiftCodeSource = `-- name: GetSwiftCodeSource :one
+SELECT swift_codes.import_id, swift_codes.actor, swift_codes.address_override, swift_codes.bank_name_override, imports.file_name, imports.sha256, imports.signer, imports.imported_at
+FROM swift_codes LEFT JOIN imports ON swift_codes.import_id = imports.id
+WHERE swift_codes.swift_code = ?
+`
+
+type GetSwiftCodeSourceRow struct {
+	ImportID         sql.NullString `json:"importID"`
+	Actor            string         `json:"actor"`
+	AddressOverride  bool           `json:"addressOverride"`
+	BankNameOverride bool           `json:"bankNameOverride"`
+	FileName         sql.NullString `json:"fileName"`
+	Sha256           sql.NullString `json:"sha256"`
+	Signer           sql.NullString `json:"signer"`
+	ImportedAt       sql.NullString `json:"importedAt"`
+}
+
+func (q *Queries) GetSwiftCodeSource(ctx context.Context, swiftCode string) (GetSwiftCodeSourceRow, error) {
+	row := q.db.QueryRowContext(ctx, getSwiftCodeSource, swiftCode)
+	var i GetSwiftCodeSourceRow
+	err := row.Scan(
+		&i.ImportID,
+		&i.Actor,
+		&i.AddressOverride,
+		&i.BankNameOverride,
+		&i.FileName,
+		&i.Sha256,
+		&i.Signer,
+		&i.ImportedAt,
+	)
+	return i, err
+}
+
+const listSwiftCodesByImport = `-- name: ListSwiftCodesByImport :many
+SELECT swift_code
+FROM swift_codes
+WHERE import_id = ?
+ORDER BY swift_code
+`
+
+func (q *Queries) ListSwiftCodesByImport(ctx context.Context, importID sql.NullString) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listSwiftCodesByImport, importID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var swift_code string
+		if err := rows.Scan(&swift_code); err != nil {
+			return nil, err
+		}
+		items = append(items, swift_code)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteSwiftCodesByImport = `-- name: DeleteSwiftCodesByImport :execresult
+DELETE FROM swift_codes
+WHERE import_id = ?
+`
+
+func (q *Queries) DeleteSwiftCodesByImport(ctx context.Context, importID sql.NullString) (sql.Result, error) {
+	return q.db.ExecContext(ctx, deleteSwiftCodesByImport, importID)
+}
+
+const editSwiftCode = `-- name: EditSwiftCode :execresult
+UPDATE swift_codes
+SET address = COALESCE(?, address), bank_name = COALESCE(?, bank_name),
+    address_override = address_override OR ? IS NOT NULL, bank_name_override = bank_name_override OR ? IS NOT NULL,
+    actor = ?
+WHERE swift_code = ?
+`
+
+type EditSwiftCodeParams struct {
+	Address   sql.NullString `json:"address"`
+	BankName  sql.NullString `json:"bankName"`
+	Actor     string         `json:"actor"`
+	SwiftCode string         `json:"swiftCode"`
+}
+
+func (q *Queries) EditSwiftCode(ctx context.Context, arg EditSwiftCodeParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, editSwiftCode,
+		arg.Address,
+		arg.BankName,
+		arg.Address,
+		arg.BankName,
+		arg.Actor,
+		arg.SwiftCode,
+	)
+}
+
+const insertJob = `-- name: InsertJob :execresult
+INSERT INTO jobs (id, kind, payload, state, attempts, max_attempts, run_after, progress, error, created_at, updated_at, finished_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type InsertJobParams struct {
+	ID          string `json:"id"`
+	Kind        string `json:"kind"`
+	Payload     string `json:"payload"`
+	State       string `json:"state"`
+	Attempts    int64  `json:"attempts"`
+	MaxAttempts int64  `json:"maxAttempts"`
+	RunAfter    string `json:"runAfter"`
+	Progress    string `json:"progress"`
+	Error       string `json:"error"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+	FinishedAt  string `json:"finishedAt"`
+}
+
+func (q *Queries) InsertJob(ctx context.Context, arg InsertJobParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, insertJob,
+		arg.ID,
+		arg.Kind,
+		arg.Payload,
+		arg.State,
+		arg.Attempts,
+		arg.MaxAttempts,
+		arg.RunAfter,
+		arg.Progress,
+		arg.Error,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.FinishedAt,
+	)
+}
+
+const getJob = `-- name: GetJob :one
+SELECT id, kind, payload, state, attempts, max_attempts, run_after, progress, error, created_at, updated_at, finished_at
+FROM jobs
+WHERE id = ?
+`
+
+func (q *Queries) GetJob(ctx context.Context, id string) (Job, error) {
+	row := q.db.QueryRowContext(ctx, getJob, id)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Payload,
+		&i.State,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAfter,
+		&i.Progress,
+		&i.Error,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const listJobs = `-- name: ListJobs :many
+SELECT id, kind, payload, state, attempts, max_attempts, run_after, progress, error, created_at, updated_at, finished_at
+FROM jobs
+ORDER BY created_at DESC, id DESC
+LIMIT ?
+`
+
+func (q *Queries) ListJobs(ctx context.Context, limit int32) ([]Job, error) {
+	rows, err := q.db.QueryContext(ctx, listJobs, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Payload,
+			&i.State,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.RunAfter,
+			&i.Progress,
+			&i.Error,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDueJobs = `-- name: ListDueJobs :many
+SELECT id, attempts
+FROM jobs
+WHERE state = 'queued' AND run_after <= ?
+ORDER BY run_after, created_at
+LIMIT ?
+`
+
+type ListDueJobsParams struct {
+	RunAfter string `json:"runAfter"`
+	Limit    int32  `json:"limit"`
+}
+
+type ListDueJobsRow struct {
+	ID       string `json:"id"`
+	Attempts int64  `json:"attempts"`
+}
+
+func (q *Queries) ListDueJobs(ctx context.Context, arg ListDueJobsParams) ([]ListDueJobsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listDueJobs, arg.RunAfter, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDueJobsRow
+	for rows.Next() {
+		var i ListDueJobsRow
+		if err := rows.Scan(&i.ID, &i.Attempts); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimJob = `-- name: ClaimJob :execresult
+UPDATE jobs
+SET state = 'running', attempts = attempts + 1, updated_at = ?
+WHERE id = ? AND state = 'queued' AND attempts = ?
+`
+
+type ClaimJobParams struct {
+	UpdatedAt string `json:"updatedAt"`
+	ID        string `json:"id"`
+	Attempts  int64  `json:"attempts"`
+}
+
+func (q *Queries) ClaimJob(ctx context.Context, arg ClaimJobParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, claimJob,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.Attempts,
+	)
+}
+
+const heartbeatJob = `-- name: HeartbeatJob :execresult
+UPDATE jobs
+SET progress = ?, updated_at = ?
+WHERE id = ? AND state = 'running' AND attempts = ?
+`
+
+type HeartbeatJobParams struct {
+	Progress  string `json:"progress"`
+	UpdatedAt string `json:"updatedAt"`
+	ID        string `json:"id"`
+	Attempts  int64  `json:"attempts"`
+}
+
+func (q *Queries) HeartbeatJob(ctx context.Context, arg HeartbeatJobParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, heartbeatJob,
+		arg.Progress,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.Attempts,
+	)
+}
+
+const finishJob = `-- name: FinishJob :execresult
+UPDATE jobs
+SET state = ?, progress = ?, error = ?, updated_at = ?, finished_at = ?
+WHERE id = ? AND state IN ('running', 'cancelled') AND attempts = ?
+`
+
+type FinishJobParams struct {
+	State      string `json:"state"`
+	Progress   string `json:"progress"`
+	Error      string `json:"error"`
+	UpdatedAt  string `json:"updatedAt"`
+	FinishedAt string `json:"finishedAt"`
+	ID         string `json:"id"`
+	Attempts   int64  `json:"attempts"`
+}
+
+func (q *Queries) FinishJob(ctx context.Context, arg FinishJobParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, finishJob,
+		arg.State,
+		arg.Progress,
+		arg.Error,
+		arg.UpdatedAt,
+		arg.FinishedAt,
+		arg.ID,
+		arg.Attempts,
+	)
+}
+
+const retryJob = `-- name: RetryJob :execresult
+UPDATE jobs
+SET state = 'queued', progress = ?, error = ?, run_after = ?, updated_at = ?
+WHERE id = ? AND state = 'running' AND attempts = ?
+`
+
+type RetryJobParams struct {
+	Progress  string `json:"progress"`
+	Error     string `json:"error"`
+	RunAfter  string `json:"runAfter"`
+	UpdatedAt string `json:"updatedAt"`
+	ID        string `json:"id"`
+	Attempts  int64  `json:"attempts"`
+}
+
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, retryJob,
+		arg.Progress,
+		arg.Error,
+		arg.RunAfter,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.Attempts,
+	)
+}
+
+const releaseJob = `-- name: ReleaseJob :execresult
+UPDATE jobs
+SET state = 'queued', attempts = attempts - 1, progress = ?, updated_at = ?
+WHERE id = ? AND state = 'running' AND attempts = ?
+`
+
+type ReleaseJobParams struct {
+	Progress  string `json:"progress"`
+	UpdatedAt string `json:"updatedAt"`
+	ID        string `json:"id"`
+	Attempts  int64  `json:"attempts"`
+}
+
+func (q *Queries) ReleaseJob(ctx context.Context, arg ReleaseJobParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, releaseJob,
+		arg.Progress,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.Attempts,
+	)
+}
+
+const cancelJob = `-- name: CancelJob :execresult
+UPDATE jobs
+SET state = 'cancelled', updated_at = ?, finished_at = ?
+WHERE id = ? AND state IN ('queued', 'running')
+`
+
+type CancelJobParams struct {
+	UpdatedAt  string `json:"updatedAt"`
+	FinishedAt string `json:"finishedAt"`
+	ID         string `json:"id"`
+}
+
+func (q *Queries) CancelJob(ctx context.Context, arg CancelJobParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, cancelJob,
+		arg.UpdatedAt,
+		arg.FinishedAt,
+		arg.ID,
+	)
+}
+
+const requeueStaleJobs = `-- name: RequeueStaleJobs :execresult
+UPDATE jobs
+SET state = 'queued', updated_at = ?
+WHERE state = 'running' AND updated_at < ? AND attempts < max_attempts
+`
+
+type RequeueStaleJobsParams struct {
+	Now         string `json:"now"`
+	StaleBefore string `json:"staleBefore"`
+}
+
+func (q *Queries) RequeueStaleJobs(ctx context.Context, arg RequeueStaleJobsParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, requeueStaleJobs,
+		arg.Now,
+		arg.StaleBefore,
+	)
+}
+
+const failStaleJobs = `-- name: FailStaleJobs :execresult
+UPDATE jobs
+SET state = 'failed', error = ?, updated_at = ?, finished_at = ?
+WHERE state = 'running' AND updated_at < ? AND attempts >= max_attempts
+`
+
+type FailStaleJobsParams struct {
+	Error       string `json:"error"`
+	Now         string `json:"now"`
+	StaleBefore string `json:"staleBefore"`
+}
+
+func (q *Queries) FailStaleJobs(ctx context.Context, arg FailStaleJobsParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, failStaleJobs,
+		arg.Error,
+		arg.Now,
+		arg.Now,
+		arg.StaleBefore,
+	)
+}
+
+const deleteImport = `-- name: DeleteImport :execresult
+DELETE FROM imports
+WHERE id = ?
+`
+
+func (q *Queries) DeleteImport(ctx context.Context, id string) (sql.Result, error) {
+	return q.db.ExecContext(ctx, deleteImport, id)
+}
+
+const recordSwiftCodeChange = `-- name: RecordSwiftCodeChange :execresult
+INSERT INTO swift_code_changes (import_id, swift_code, address, bank_name, country_iso2, previous_import_id, actor, address_override, bank_name_override, removed)
+SELECT ?, swift_code, address, bank_name, country_iso2, import_id, actor, address_override, bank_name_override, ?
+FROM swift_codes
+WHERE swift_code = ?
+`
+
+type RecordSwiftCodeChangeParams struct {
+	ImportID  string `json:"importID"`
+	Removed   bool   `json:"removed"`
+	SwiftCode string `json:"swiftCode"`
+}
+
+func (q *Queries) RecordSwiftCodeChange(ctx context.Context, arg RecordSwiftCodeChangeParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, recordSwiftCodeChange, arg.ImportID, arg.Removed, arg.SwiftCode)
+}
+
+const listSwiftCodeChanges = `-- name: ListSwiftCodeChanges :many
+SELECT import_id, swift_code, address, bank_name, country_iso2, previous_import_id, actor, address_override, bank_name_override, removed
+FROM swift_code_changes
+WHERE import_id = ?
+ORDER BY swift_code
+`
+
+func (q *Queries) ListSwiftCodeChanges(ctx context.Context, importID string) ([]SwiftCodeChange, error) {
+	rows, err := q.db.QueryContext(ctx, listSwiftCodeChanges, importID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SwiftCodeChange
+	for rows.Next() {
+		var i SwiftCodeChange
+		if err := rows.Scan(
+			&i.ImportID,
+			&i.SwiftCode,
+			&i.Address,
+			&i.BankName,
+			&i.CountryISO2,
+			&i.PreviousImportID,
+			&i.Actor,
+			&i.AddressOverride,
+			&i.BankNameOverride,
+			&i.Removed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreSwiftCode = `-- name: RestoreSwiftCode :execresult
+UPDATE swift_codes
+SET address = ?, bank_name = ?, country_iso2 = ?, import_id = ?,
+    actor = ?, address_override = ?, bank_name_override = ?
+WHERE swift_code = ? AND import_id = ?
+`
+
+type RestoreSwiftCodeParams struct {
+	Address          string         `json:"address"`
+	BankName         string         `json:"bankName"`
+	CountryISO2      string         `json:"countryISO2"`
+	PreviousImportID sql.NullString `json:"previousImportID"`
+	Actor            string         `json:"actor"`
+	AddressOverride  bool           `json:"addressOverride"`
+	BankNameOverride bool           `json:"bankNameOverride"`
+	SwiftCode        string         `json:"swiftCode"`
+	ImportID         sql.NullString `json:"importID"`
+}
+
+func (q *Queries) RestoreSwiftCode(ctx context.Context, arg RestoreSwiftCodeParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, restoreSwiftCode,
+		arg.Address,
+		arg.BankName,
+		arg.CountryISO2,
+		arg.PreviousImportID,
+		arg.Actor,
+		arg.AddressOverride,
+		arg.BankNameOverride,
+		arg.SwiftCode,
+		arg.ImportID,
 	)
 }
+
+const deleteSwiftCodeChanges = `-- name: DeleteSwiftCodeChanges :execresult
+DELETE FROM swift_code_changes
+WHERE import_id = ?
+`
+
+func (q *Queries) DeleteSwiftCodeChanges(ctx context.Context, importID string) (sql.Result, error) {
+	return q.db.ExecContext(ctx, deleteSwiftCodeChanges, importID)
+}
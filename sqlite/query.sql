@@ -0,0 +1,162 @@
+-- name: GetCountry :one
+SELECT country_iso2, country_name
+FROM countries
+WHERE country_iso2 = sqlc.arg(country_iso2);
+
+-- name: GetCodeDetailsByCountryCode :many
+SELECT swift_code, address, bank_name, swift_codes.country_iso2
+FROM swift_codes
+WHERE country_iso2 = sqlc.arg(country_iso2);
+
+-- name: GetCodeDetails :many
+SELECT swift_code, address, bank_name, swift_codes.country_iso2, countries.country_name
+FROM swift_codes LEFT JOIN countries ON swift_codes.country_iso2 = countries.country_iso2
+WHERE swift_codes.swift_code = sqlc.arg(swift_code)
+UNION ALL
+SELECT swift_code, address, bank_name, swift_codes.country_iso2, countries.country_name
+FROM swift_codes LEFT JOIN countries ON swift_codes.country_iso2 = countries.country_iso2
+WHERE substr(sqlc.arg(swift_code), -3) = 'XXX' COLLATE NOCASE
+AND bank8 = substr(sqlc.arg(swift_code), 1, 8)
+AND is_headquarter = FALSE;
+
+-- name: InsertSwiftCode :execresult
+INSERT INTO swift_codes (swift_code, address, bank_name, country_iso2, import_id, actor)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: InsertCountry :execresult
+INSERT INTO countries (country_iso2, country_name)
+VALUES (?, ?);
+
+-- name: DeleteSwiftCode :execresult
+DELETE FROM swift_codes
+WHERE swift_code = ?;
+
+-- name: ListSwiftCodes :many
+SELECT swift_codes.swift_code, swift_codes.address, swift_codes.bank_name, swift_codes.country_iso2, countries.country_name, swift_codes.actor, swift_codes.address_override, swift_codes.bank_name_override
+FROM swift_codes JOIN countries ON swift_codes.country_iso2 = countries.country_iso2
+ORDER BY swift_codes.swift_code;
+
+-- name: UpdateSwiftCode :execresult
+UPDATE swift_codes
+SET address = ?, bank_name = ?, country_iso2 = ?, import_id = ?, actor = ?, address_override = ?, bank_name_override = ?
+WHERE swift_code = ?;
+
+-- name: InsertImport :execresult
+INSERT INTO imports (id, file_name, sha256, signer, imported_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: ListImports :many
+SELECT imports.id, imports.file_name, imports.sha256, imports.signer, imports.imported_at, COUNT(swift_codes.swift_code) AS records
+FROM imports LEFT JOIN swift_codes ON swift_codes.import_id = imports.id
+GROUP BY imports.id, imports.file_name, imports.sha256, imports.signer, imports.imported_at
+ORDER BY imports.imported_at, imports.id;
+
+-- name: GetSwiftCodeSource :one
+SELECT swift_codes.import_id, swift_codes.actor, swift_codes.address_override, swift_codes.bank_name_override, imports.file_name, imports.sha256, imports.signer, imports.imported_at
+FROM swift_codes LEFT JOIN imports ON swift_codes.import_id = imports.id
+WHERE swift_codes.swift_code = ?;
+
+-- name: ListSwiftCodesByImport :many
+SELECT swift_code
+FROM swift_codes
+WHERE import_id = ?
+ORDER BY swift_code;
+
+-- name: DeleteSwiftCodesByImport :execresult
+DELETE FROM swift_codes
+WHERE import_id = ?;
+
+-- name: EditSwiftCode :execresult
+UPDATE swift_codes
+SET address = COALESCE(sqlc.narg(address), address), bank_name = COALESCE(sqlc.narg(bank_name), bank_name),
+    address_override = address_override OR sqlc.narg(address) IS NOT NULL, bank_name_override = bank_name_override OR sqlc.narg(bank_name) IS NOT NULL,
+    actor = sqlc.arg(actor)
+WHERE swift_code = sqlc.arg(swift_code);
+
+-- name: InsertJob :execresult
+INSERT INTO jobs (id, kind, payload, state, attempts, max_attempts, run_after, progress, error, created_at, updated_at, finished_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetJob :one
+SELECT id, kind, payload, state, attempts, max_attempts, run_after, progress, error, created_at, updated_at, finished_at
+FROM jobs
+WHERE id = ?;
+
+-- name: ListJobs :many
+SELECT id, kind, payload, state, attempts, max_attempts, run_after, progress, error, created_at, updated_at, finished_at
+FROM jobs
+ORDER BY created_at DESC, id DESC
+LIMIT ?;
+
+-- name: ListDueJobs :many
+SELECT id, attempts
+FROM jobs
+WHERE state = 'queued' AND run_after <= ?
+ORDER BY run_after, created_at
+LIMIT ?;
+
+-- name: ClaimJob :execresult
+UPDATE jobs
+SET state = 'running', attempts = attempts + 1, updated_at = ?
+WHERE id = ? AND state = 'queued' AND attempts = ?;
+
+-- name: HeartbeatJob :execresult
+UPDATE jobs
+SET progress = ?, updated_at = ?
+WHERE id = ? AND state = 'running' AND attempts = ?;
+
+-- name: FinishJob :execresult
+UPDATE jobs
+SET state = ?, progress = ?, error = ?, updated_at = ?, finished_at = ?
+WHERE id = ? AND state IN ('running', 'cancelled') AND attempts = ?;
+
+-- name: RetryJob :execresult
+UPDATE jobs
+SET state = 'queued', progress = ?, error = ?, run_after = ?, updated_at = ?
+WHERE id = ? AND state = 'running' AND attempts = ?;
+
+-- name: ReleaseJob :execresult
+UPDATE jobs
+SET state = 'queued', attempts = attempts - 1, progress = ?, updated_at = ?
+WHERE id = ? AND state = 'running' AND attempts = ?;
+
+-- name: CancelJob :execresult
+UPDATE jobs
+SET state = 'cancelled', updated_at = ?, finished_at = ?
+WHERE id = ? AND state IN ('queued', 'running');
+
+-- name: RequeueStaleJobs :execresult
+UPDATE jobs
+SET state = 'queued', updated_at = sqlc.arg(now)
+WHERE state = 'running' AND updated_at < sqlc.arg(stale_before) AND attempts < max_attempts;
+
+-- name: FailStaleJobs :execresult
+UPDATE jobs
+SET state = 'failed', error = sqlc.arg(error), updated_at = sqlc.arg(now), finished_at = sqlc.arg(now)
+WHERE state = 'running' AND updated_at < sqlc.arg(stale_before) AND attempts >= max_attempts;
+
+-- name: DeleteImport :execresult
+DELETE FROM imports
+WHERE id = ?;
+
+-- name: RecordSwiftCodeChange :execresult
+INSERT INTO swift_code_changes (import_id, swift_code, address, bank_name, country_iso2, previous_import_id, actor, address_override, bank_name_override, removed)
+SELECT sqlc.arg(import_id), swift_code, address, bank_name, country_iso2, import_id, actor, address_override, bank_name_override, sqlc.arg(removed)
+FROM swift_codes
+WHERE swift_code = sqlc.arg(swift_code);
+
+-- name: ListSwiftCodeChanges :many
+SELECT import_id, swift_code, address, bank_name, country_iso2, previous_import_id, actor, address_override, bank_name_override, removed
+FROM swift_code_changes
+WHERE import_id = ?
+ORDER BY swift_code;
+
+-- name: RestoreSwiftCode :execresult
+UPDATE swift_codes
+SET address = sqlc.arg(address), bank_name = sqlc.arg(bank_name), country_iso2 = sqlc.arg(country_iso2), import_id = sqlc.narg(previous_import_id),
+    actor = sqlc.arg(actor), address_override = sqlc.arg(address_override), bank_name_override = sqlc.arg(bank_name_override)
+WHERE swift_code = sqlc.arg(swift_code) AND import_id = sqlc.arg(import_id);
+
+-- name: DeleteSwiftCodeChanges :execresult
+DELETE FROM swift_code_changes
+WHERE import_id = ?;
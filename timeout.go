@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WithQueryTimeout derives a context with a deadline from the request context, so queries of the handler
+// are cancelled when the client disconnects or the timeout passes
+func WithQueryTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// WithWriteTimeout replaces the write deadline of the server with timeout from now, for responses that take
+// longer to stream than the server allows others
+func WithWriteTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Print("Failed to extend the write deadline: ", err)
+		}
+		c.Next()
+	}
+}